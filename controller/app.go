@@ -169,12 +169,14 @@ func (admin *APPController) AppAdd(c *gin.Context) {
 	}
 	tx := lib.GORMDefaultPool
 	info := &dao.App{
-		AppID:    params.AppID,
-		Name:     params.Name,
-		Secret:   params.Secret,
-		WhiteIPS: params.WhiteIPS,
-		Qps:      params.Qps,
-		Qpd:      params.Qpd,
+		AppID:        params.AppID,
+		Name:         params.Name,
+		Secret:       params.Secret,
+		WhiteIPS:     params.WhiteIPS,
+		Qps:          params.Qps,
+		Qpd:          params.Qpd,
+		CertSubjects: params.CertSubjects,
+		CertServices: params.CertServices,
 	}
 	if err := info.Save(c, tx); err != nil {
 		middleware.ResponseError(c, 2003, err)
@@ -216,6 +218,8 @@ func (admin *APPController) AppUpdate(c *gin.Context) {
 	info.WhiteIPS = params.WhiteIPS
 	info.Qps = params.Qps
 	info.Qpd = params.Qpd
+	info.CertSubjects = params.CertSubjects
+	info.CertServices = params.CertServices
 	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
 		middleware.ResponseError(c, 2003, err)
 		return
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/dto"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/gin-gonic/gin"
+	"math"
+	"time"
+)
+
+const DefaultCertExpireWarnDays = 30
+
+//CertRegister 证书路由注册
+func CertRegister(router *gin.RouterGroup) {
+	cert := CertController{}
+	router.GET("/cert_list", cert.CertList)
+	router.GET("/cert_detail", cert.CertDetail)
+	router.GET("/cert_delete", cert.CertDelete)
+	router.POST("/cert_add", cert.CertAdd)
+	router.POST("/cert_update", cert.CertUpdate)
+}
+
+type CertController struct {
+}
+
+// CertList godoc
+// @Summary 证书列表
+// @Description 证书列表，含剩余有效天数及过期状态
+// @Tags 证书管理
+// @ID /cert/cert_list
+// @Accept  json
+// @Produce  json
+// @Param info query string false "关键词"
+// @Param page_size query string true "每页多少条"
+// @Param page_no query string true "页码"
+// @Success 200 {object} middleware.Response{data=dto.CertListOutput} "success"
+// @Router /cert/cert_list [get]
+func (cert *CertController) CertList(c *gin.Context) {
+	params := &dto.CertListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Cert{}
+	list, total, err := info.CertList(c, lib.GORMDefaultPool, params)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+
+	now := time.Now()
+	warnDays := CertExpireWarnDays()
+	outputList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		expireDays := int(math.Floor(item.NotAfter.Sub(now).Hours() / 24))
+		expireStatus := 0
+		if !item.NotAfter.After(now) {
+			expireStatus = 2
+		} else if expireDays < warnDays {
+			expireStatus = 1
+		}
+		outputList = append(outputList, dto.CertListItemOutput{
+			ID:           item.ID,
+			Name:         item.Name,
+			Domains:      item.Domains,
+			Issuer:       item.Issuer,
+			IsDefault:    item.IsDefault,
+			NotBefore:    item.NotBefore,
+			NotAfter:     item.NotAfter,
+			ExpireDays:   expireDays,
+			ExpireStatus: expireStatus,
+			UpdatedAt:    item.UpdatedAt,
+		})
+	}
+	output := dto.CertListOutput{
+		List:  outputList,
+		Total: total,
+	}
+	middleware.ResponseSuccess(c, output)
+	return
+}
+
+// CertDetail godoc
+// @Summary 证书详情
+// @Description 证书详情，不返回私钥
+// @Tags 证书管理
+// @ID /cert/cert_detail
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=dao.Cert} "success"
+// @Router /cert/cert_detail [get]
+func (cert *CertController) CertDetail(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	detail, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, detail)
+	return
+}
+
+// CertDelete godoc
+// @Summary 证书删除
+// @Description 证书删除
+// @Tags 证书管理
+// @ID /cert/cert_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_delete [get]
+func (cert *CertController) CertDelete(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.IsDelete = 1
+	info.IsDefault = 0
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertAdd godoc
+// @Summary 证书上传
+// @Description 证书上传，域名及有效期从证书解析
+// @Tags 证书管理
+// @ID /cert/cert_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_add [post]
+func (cert *CertController) CertAdd(c *gin.Context) {
+	params := &dto.CertAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Cert{
+		Name:      params.Name,
+		CertPem:   params.CertPem,
+		KeyPem:    params.KeyPem,
+		IsDefault: params.IsDefault,
+	}
+	if _, err := info.Parse(); err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+
+	tx := lib.GORMDefaultPool.Begin()
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if info.IsDefault == 1 {
+		if err := info.ResetDefault(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2004, err)
+			return
+		}
+	}
+	tx.Commit()
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertUpdate godoc
+// @Summary 证书更新
+// @Description 证书更新，上传新证书及私钥即完成轮换，代理重新加载配置后生效无需重启
+// @Tags 证书管理
+// @ID /cert/cert_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_update [post]
+func (cert *CertController) CertUpdate(c *gin.Context) {
+	params := &dto.CertUpdateInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.Name = params.Name
+	info.IsDefault = params.IsDefault
+	//证书及私钥为空时只修改名称及默认标记
+	if params.CertPem != "" || params.KeyPem != "" {
+		info.CertPem = params.CertPem
+		info.KeyPem = params.KeyPem
+		if _, err := info.Parse(); err != nil {
+			middleware.ResponseError(c, 2003, err)
+			return
+		}
+	}
+
+	tx := lib.GORMDefaultPool.Begin()
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if info.IsDefault == 1 {
+		if err := info.ResetDefault(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2005, err)
+			return
+		}
+	}
+	tx.Commit()
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+//剩余有效天数小于该值的证书视为即将过期
+func CertExpireWarnDays() int {
+	warnDays := lib.GetIntConf("proxy.cert.expire_warn_days")
+	if warnDays <= 0 {
+		warnDays = DefaultCertExpireWarnDays
+	}
+	return warnDays
+}
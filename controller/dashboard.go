@@ -52,11 +52,25 @@ func (service *DashboardController) PanelGroupData(c *gin.Context) {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	cert := &dao.Cert{}
+	_, certNum, err := cert.CertList(c, tx, &dto.CertListInput{PageNo: 1, PageSize: 1})
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	//即将过期及已过期的证书数
+	certExpiringNum, err := cert.ExpiringCount(c, tx, time.Now().AddDate(0, 0, CertExpireWarnDays()))
+	if err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
 	out := &dto.PanelGroupDataOutput{
 		ServiceNum:      serviceNum,
 		AppNum:          appNum,
 		TodayRequestNum: counter.TotalCount,
 		CurrentQPS:      counter.QPS,
+		CertNum:         certNum,
+		CertExpiringNum: certExpiringNum,
 	}
 	middleware.ResponseSuccess(c, out)
 }
@@ -6,7 +6,9 @@ import (
 	"github.com/e421083458/go_gateway/dto"
 	"github.com/e421083458/go_gateway/middleware"
 	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/go_gateway/reverse_proxy"
 	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"strings"
@@ -28,6 +30,16 @@ func ServiceRegister(group *gin.RouterGroup) {
 	group.POST("/service_update_tcp", service.ServiceUpdateTcp)
 	group.POST("/service_add_grpc", service.ServiceAddGrpc)
 	group.POST("/service_update_grpc", service.ServiceUpdateGrpc)
+
+	group.GET("/service_group_list", service.ServiceGroupList)
+	group.POST("/service_group_add", service.ServiceGroupAdd)
+	group.POST("/service_group_update", service.ServiceGroupUpdate)
+	group.GET("/service_group_delete", service.ServiceGroupDelete)
+	group.GET("/service_group_stat", service.ServiceGroupStat)
+	group.GET("/service_cache_purge", service.ServiceCachePurge)
+	group.GET("/service_websocket_stat", service.ServiceWebsocketStat)
+	group.POST("/service_upstream_tls", service.ServiceUpstreamTLS)
+	group.POST("/service_client_auth", service.ServiceClientAuth)
 }
 
 // ServiceList godoc
@@ -71,29 +83,41 @@ func (service *ServiceController) ServiceList(c *gin.Context) {
 			middleware.ResponseError(c, 2003, err)
 			return
 		}
-		//1、http后缀接入 clusterIP+clusterPort+path
+		//1、http后缀及正则接入 clusterIP+clusterPort+path
 		//2、http域名接入 domain
 		//3、tcp、grpc接入 clusterIP+servicePort
+		//4、tcp按SNI接入 domain+servicePort
 		serviceAddr := "unknow"
 		clusterIP := lib.GetStringConf("base.cluster.cluster_ip")
 		clusterPort := lib.GetStringConf("base.cluster.cluster_port")
 		clusterSSLPort := lib.GetStringConf("base.cluster.cluster_ssl_port")
-		if serviceDetail.Info.LoadType == public.LoadTypeHTTP &&
-			serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL &&
-			serviceDetail.HTTPRule.NeedHttps == 1 {
-			serviceAddr = fmt.Sprintf("%s:%s%s", clusterIP, clusterSSLPort, serviceDetail.HTTPRule.Rule)
-		}
-		if serviceDetail.Info.LoadType == public.LoadTypeHTTP &&
-			serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL &&
-			serviceDetail.HTTPRule.NeedHttps == 0 {
-			serviceAddr = fmt.Sprintf("%s:%s%s", clusterIP, clusterPort, serviceDetail.HTTPRule.Rule)
-		}
-		if serviceDetail.Info.LoadType == public.LoadTypeHTTP &&
-			serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypeDomain {
-			serviceAddr = serviceDetail.HTTPRule.Rule
+		if serviceDetail.Info.LoadType == public.LoadTypeHTTP {
+			//多条接入规则逗号间隔展示
+			addrList := []string{}
+			for _, httpRule := range serviceDetail.GetHTTPRules() {
+				switch {
+				case httpRule.RuleType == public.HTTPRuleTypeDomain:
+					addrList = append(addrList, httpRule.Rule)
+				case serviceDetail.HTTPRule.NeedHttps == 1:
+					addrList = append(addrList, fmt.Sprintf("%s:%s%s", clusterIP, clusterSSLPort, httpRule.Rule))
+				default:
+					addrList = append(addrList, fmt.Sprintf("%s:%s%s", clusterIP, clusterPort, httpRule.Rule))
+				}
+			}
+			serviceAddr = strings.Join(addrList, ",")
 		}
 		if serviceDetail.Info.LoadType == public.LoadTypeTCP {
 			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.TCPRule.Port)
+			//开启tls并配置SNI域名时展示 域名+servicePort
+			if serviceDetail.TCPRule.TLSMode != dao.TcpTLSModeNone {
+				addrList := []string{}
+				for _, host := range serviceDetail.TCPRule.GetSNIHosts() {
+					addrList = append(addrList, fmt.Sprintf("%s:%d", host, serviceDetail.TCPRule.Port))
+				}
+				if len(addrList) > 0 {
+					serviceAddr = strings.Join(addrList, ",")
+				}
+			}
 		}
 		if serviceDetail.Info.LoadType == public.LoadTypeGRPC {
 			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.GRPCRule.Port)
@@ -196,7 +220,23 @@ func (service *ServiceController) ServiceDetail(c *gin.Context) {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
-	middleware.ResponseSuccess(c, serviceDetail)
+
+	//熔断状态由代理服务写入redis，key为service或节点地址，读取失败时不影响服务详情
+	breakerState, err := public.GetCircuitBreakerState(serviceDetail.Info.ServiceName)
+	if err != nil {
+		public.ComLogWarning(c, "_com_circuit_breaker_state", map[string]interface{}{
+			"service_name": serviceDetail.Info.ServiceName,
+			"err":          err.Error(),
+		})
+		breakerState = map[string]string{}
+	}
+	middleware.ResponseSuccess(c, &struct {
+		*dao.ServiceDetail
+		CircuitBreaker map[string]string `json:"circuit_breaker" description:"熔断状态"`
+	}{
+		ServiceDetail:  serviceDetail,
+		CircuitBreaker: breakerState,
+	})
 }
 
 // ServiceStat godoc
@@ -290,10 +330,12 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		return
 	}
 
-	httpUrl := &dao.HttpRule{RuleType: params.RuleType, Rule: params.Rule}
-	if _, err := httpUrl.Find(c, tx, httpUrl); err == nil {
+	httpUrl := &dao.HttpRule{RuleType: params.RuleType, Rule: params.Rule,
+		MatchMethod: params.MatchMethod, MatchHeader: params.MatchHeader, MatchQuery: params.MatchQuery}
+	accessRules := httpAccessRulesFromInput(params.AccessRules)
+	if err := checkHTTPAccessRules(c, tx, append([]*dao.HttpRule{httpUrl}, accessRules...)); err != nil {
 		tx.Rollback()
-		middleware.ResponseError(c, 2003, errors.New("服务接入前缀或域名已存在"))
+		middleware.ResponseError(c, 2003, err)
 		return
 	}
 
@@ -308,20 +350,58 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 	}
 	//serviceModel.ID
 	httpRule := &dao.HttpRule{
-		ServiceID:      serviceModel.ID,
-		RuleType:       params.RuleType,
-		Rule:           params.Rule,
-		NeedHttps:      params.NeedHttps,
-		NeedStripUri:   params.NeedStripUri,
-		NeedWebsocket:  params.NeedWebsocket,
-		UrlRewrite:     params.UrlRewrite,
-		HeaderTransfor: params.HeaderTransfor,
+		ServiceID:         serviceModel.ID,
+		RuleType:          params.RuleType,
+		Rule:              params.Rule,
+		NeedHttps:         params.NeedHttps,
+		NeedStripUri:      params.NeedStripUri,
+		NeedWebsocket:     params.NeedWebsocket,
+		UrlRewrite:        params.UrlRewrite,
+		HeaderTransfor:    params.HeaderTransfor,
+		Priority:          params.Priority,
+		MatchMethod:       params.MatchMethod,
+		MatchHeader:       params.MatchHeader,
+		MatchQuery:        params.MatchQuery,
+		MirrorTarget:      params.MirrorTarget,
+		MirrorPercent:     params.MirrorPercent,
+		CacheEnable:       params.CacheEnable,
+		CacheStore:        params.CacheStore,
+		CacheTTL:          params.CacheTTL,
+		CacheStale:        params.CacheStale,
+		CacheKey:          params.CacheKey,
+		RespStatusMap:     params.RespStatusMap,
+		RespJsonMask:      params.RespJsonMask,
+		RespBodyReplace:   params.RespBodyReplace,
+		CompressEnable:    params.CompressEnable,
+		CompressMinSize:   params.CompressMinSize,
+		CompressTypes:     params.CompressTypes,
+		DecompressRequest: params.DecompressRequest,
+
+		WebsocketIdleTimeout: params.WebsocketIdleTimeout,
+		WebsocketMaxLifetime: params.WebsocketMaxLifetime,
+		WebsocketMaxConn:     params.WebsocketMaxConn,
+		StreamRoutes:         params.StreamRoutes,
+		StreamIdleTimeout:    params.StreamIdleTimeout,
+	}
+	if _, err := reverse_proxy.NewResponseTransform(httpRule.RespStatusMap, httpRule.RespJsonMask,
+		httpRule.RespBodyReplace, 0); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
 	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
 		return
 	}
+	for _, accessRule := range accessRules {
+		accessRule.ServiceID = serviceModel.ID
+		if err := accessRule.Save(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2006, err)
+			return
+		}
+	}
 
 	accessControl := &dao.AccessControl{
 		ServiceID:         serviceModel.ID,
@@ -336,16 +416,75 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2007, err)
 		return
 	}
+	headerRules, err := httpHeaderRulesFromInput(params.HeaderRules)
+	if err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+	for _, headerRule := range headerRules {
+		headerRule.ServiceID = serviceModel.ID
+		if err := headerRule.Save(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2007, err)
+			return
+		}
+	}
+	cors := &dao.CorsPolicy{
+		ServiceID:        serviceModel.ID,
+		Enable:           params.CorsEnable,
+		AllowOrigins:     params.CorsAllowOrigins,
+		AllowMethods:     params.CorsAllowMethods,
+		AllowHeaders:     params.CorsAllowHeaders,
+		ExposeHeaders:    params.CorsExposeHeaders,
+		AllowCredentials: params.CorsAllowCredentials,
+		MaxAge:           params.CorsMaxAge,
+	}
+	if err := cors.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
 
 	loadbalance := &dao.LoadBalance{
 		ServiceID:              serviceModel.ID,
 		RoundType:              params.RoundType,
+		HashKeyType:            params.HashKeyType,
+		HashKeyName:            params.HashKeyName,
+		HashReplicas:           params.HashReplicas,
+		HashLoadFactor:         params.HashLoadFactor,
+		StickySession:          params.StickySession,
+		StickyCookie:           params.StickyCookie,
+		RetryNum:               params.RetryNum,
+		RetryOn:                params.RetryOn,
+		RetryNonIdempotent:     params.RetryNonIdempotent,
+		BreakerErrorPercent:    params.BreakerErrorPercent,
+		BreakerSlowPercent:     params.BreakerSlowPercent,
+		BreakerSlowTime:        params.BreakerSlowTime,
+		BreakerMinRequests:     params.BreakerMinRequests,
+		BreakerWindow:          params.BreakerWindow,
+		BreakerOpenTime:        params.BreakerOpenTime,
+		BreakerNode:            params.BreakerNode,
+		BreakerFallbackCode:    params.BreakerFallbackCode,
+		BreakerFallbackBody:    params.BreakerFallbackBody,
+		OutlierErrNum:          params.OutlierErrNum,
+		OutlierEjectTime:       params.OutlierEjectTime,
+		OutlierMaxEjectTime:    params.OutlierMaxEjectTime,
+		OutlierMaxPercent:      params.OutlierMaxPercent,
 		IpList:                 params.IpList,
 		WeightList:             params.WeightList,
 		UpstreamConnectTimeout: params.UpstreamConnectTimeout,
 		UpstreamHeaderTimeout:  params.UpstreamHeaderTimeout,
 		UpstreamIdleTimeout:    params.UpstreamIdleTimeout,
 		UpstreamMaxIdle:        params.UpstreamMaxIdle,
+		CheckMethod:            params.CheckMethod,
+		CheckTimeout:           params.CheckTimeout,
+		CheckInterval:          params.CheckInterval,
+		CheckRise:              params.CheckRise,
+		CheckFall:              params.CheckFall,
+		CheckPath:              params.CheckPath,
+		CheckStatus:            params.CheckStatus,
+		CheckBody:              params.CheckBody,
 	}
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -412,11 +551,61 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	httpRule.NeedWebsocket = params.NeedWebsocket
 	httpRule.UrlRewrite = params.UrlRewrite
 	httpRule.HeaderTransfor = params.HeaderTransfor
+	httpRule.Priority = params.Priority
+	httpRule.MatchMethod = params.MatchMethod
+	httpRule.MatchHeader = params.MatchHeader
+	httpRule.MatchQuery = params.MatchQuery
+	httpRule.MirrorTarget = params.MirrorTarget
+	httpRule.MirrorPercent = params.MirrorPercent
+	httpRule.CacheEnable = params.CacheEnable
+	httpRule.CacheStore = params.CacheStore
+	httpRule.CacheTTL = params.CacheTTL
+	httpRule.CacheStale = params.CacheStale
+	httpRule.CacheKey = params.CacheKey
+	httpRule.RespStatusMap = params.RespStatusMap
+	httpRule.RespJsonMask = params.RespJsonMask
+	httpRule.RespBodyReplace = params.RespBodyReplace
+	httpRule.CompressEnable = params.CompressEnable
+	httpRule.CompressMinSize = params.CompressMinSize
+	httpRule.CompressTypes = params.CompressTypes
+	httpRule.DecompressRequest = params.DecompressRequest
+	httpRule.WebsocketIdleTimeout = params.WebsocketIdleTimeout
+	httpRule.WebsocketMaxLifetime = params.WebsocketMaxLifetime
+	httpRule.WebsocketMaxConn = params.WebsocketMaxConn
+	httpRule.StreamRoutes = params.StreamRoutes
+	httpRule.StreamIdleTimeout = params.StreamIdleTimeout
+	accessRules := httpAccessRulesFromInput(params.AccessRules)
+	for _, accessRule := range accessRules {
+		accessRule.ServiceID = info.ID
+	}
+	if err := checkHTTPAccessRules(c, tx, append([]*dao.HttpRule{httpRule}, accessRules...)); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	if _, err := reverse_proxy.NewResponseTransform(httpRule.RespStatusMap, httpRule.RespJsonMask,
+		httpRule.RespBodyReplace, 0); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
 		return
 	}
+	if err := httpRule.DeleteAccessRules(c, tx, info.ID, httpRule.ID); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	for _, accessRule := range accessRules {
+		if err := accessRule.Save(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2006, err)
+			return
+		}
+	}
 
 	accessControl := serviceDetail.AccessControl
 	accessControl.OpenAuth = params.OpenAuth
@@ -429,15 +618,82 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2007, err)
 		return
 	}
+	headerRules, err := httpHeaderRulesFromInput(params.HeaderRules)
+	if err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+	headerRule := &dao.HeaderRule{}
+	if err := headerRule.DeleteByServiceID(c, tx, info.ID); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+	for _, headerRule := range headerRules {
+		headerRule.ServiceID = info.ID
+		if err := headerRule.Save(c, tx); err != nil {
+			tx.Rollback()
+			middleware.ResponseError(c, 2007, err)
+			return
+		}
+	}
+	cors := &dao.CorsPolicy{}
+	if serviceDetail.Cors != nil {
+		cors = serviceDetail.Cors
+	}
+	cors.ServiceID = info.ID
+	cors.Enable = params.CorsEnable
+	cors.AllowOrigins = params.CorsAllowOrigins
+	cors.AllowMethods = params.CorsAllowMethods
+	cors.AllowHeaders = params.CorsAllowHeaders
+	cors.ExposeHeaders = params.CorsExposeHeaders
+	cors.AllowCredentials = params.CorsAllowCredentials
+	cors.MaxAge = params.CorsMaxAge
+	if err := cors.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
 
 	loadbalance := serviceDetail.LoadBalance
 	loadbalance.RoundType = params.RoundType
+	loadbalance.HashKeyType = params.HashKeyType
+	loadbalance.HashKeyName = params.HashKeyName
+	loadbalance.HashReplicas = params.HashReplicas
+	loadbalance.HashLoadFactor = params.HashLoadFactor
+	loadbalance.StickySession = params.StickySession
+	loadbalance.StickyCookie = params.StickyCookie
+	loadbalance.RetryNum = params.RetryNum
+	loadbalance.RetryOn = params.RetryOn
+	loadbalance.RetryNonIdempotent = params.RetryNonIdempotent
+	loadbalance.BreakerErrorPercent = params.BreakerErrorPercent
+	loadbalance.BreakerSlowPercent = params.BreakerSlowPercent
+	loadbalance.BreakerSlowTime = params.BreakerSlowTime
+	loadbalance.BreakerMinRequests = params.BreakerMinRequests
+	loadbalance.BreakerWindow = params.BreakerWindow
+	loadbalance.BreakerOpenTime = params.BreakerOpenTime
+	loadbalance.BreakerNode = params.BreakerNode
+	loadbalance.BreakerFallbackCode = params.BreakerFallbackCode
+	loadbalance.BreakerFallbackBody = params.BreakerFallbackBody
+	loadbalance.OutlierErrNum = params.OutlierErrNum
+	loadbalance.OutlierEjectTime = params.OutlierEjectTime
+	loadbalance.OutlierMaxEjectTime = params.OutlierMaxEjectTime
+	loadbalance.OutlierMaxPercent = params.OutlierMaxPercent
 	loadbalance.IpList = params.IpList
 	loadbalance.WeightList = params.WeightList
 	loadbalance.UpstreamConnectTimeout = params.UpstreamConnectTimeout
 	loadbalance.UpstreamHeaderTimeout = params.UpstreamHeaderTimeout
 	loadbalance.UpstreamIdleTimeout = params.UpstreamIdleTimeout
 	loadbalance.UpstreamMaxIdle = params.UpstreamMaxIdle
+	loadbalance.CheckMethod = params.CheckMethod
+	loadbalance.CheckTimeout = params.CheckTimeout
+	loadbalance.CheckInterval = params.CheckInterval
+	loadbalance.CheckRise = params.CheckRise
+	loadbalance.CheckFall = params.CheckFall
+	loadbalance.CheckPath = params.CheckPath
+	loadbalance.CheckStatus = params.CheckStatus
+	loadbalance.CheckBody = params.CheckBody
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2008, err)
@@ -474,12 +730,14 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 		return
 	}
 
-	//验证端口是否被占用?
+	//验证端口是否被占用? 开启tls的服务可按SNI域名共用端口
 	tcpRuleSearch := &dao.TcpRule{
-		Port: params.Port,
+		Port:     params.Port,
+		TLSMode:  params.TLSMode,
+		SNIHosts: params.SNIHosts,
 	}
-	if _, err := tcpRuleSearch.Find(c, lib.GORMDefaultPool, tcpRuleSearch); err == nil {
-		middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
+	if err := tcpRuleSearch.CheckPortShare(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2003, err)
 		return
 	}
 	grpcRuleSearch := &dao.GrpcRule{
@@ -513,6 +771,28 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 		IpList:     params.IpList,
 		WeightList: params.WeightList,
 		ForbidList: params.ForbidList,
+
+		HashReplicas:   params.HashReplicas,
+		HashLoadFactor: params.HashLoadFactor,
+
+		BreakerErrorPercent: params.BreakerErrorPercent,
+		BreakerSlowPercent:  params.BreakerSlowPercent,
+		BreakerSlowTime:     params.BreakerSlowTime,
+		BreakerMinRequests:  params.BreakerMinRequests,
+		BreakerWindow:       params.BreakerWindow,
+		BreakerOpenTime:     params.BreakerOpenTime,
+		BreakerNode:         params.BreakerNode,
+		BreakerFallbackBody: params.BreakerFallbackBody,
+		OutlierErrNum:       params.OutlierErrNum,
+		OutlierEjectTime:    params.OutlierEjectTime,
+		OutlierMaxEjectTime: params.OutlierMaxEjectTime,
+		OutlierMaxPercent:   params.OutlierMaxPercent,
+
+		CheckMethod:   params.CheckMethod,
+		CheckTimeout:  params.CheckTimeout,
+		CheckInterval: params.CheckInterval,
+		CheckRise:     params.CheckRise,
+		CheckFall:     params.CheckFall,
 	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -523,6 +803,8 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 	httpRule := &dao.TcpRule{
 		ServiceID: info.ID,
 		Port:      params.Port,
+		TLSMode:   params.TLSMode,
+		SNIHosts:  params.SNIHosts,
 	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -597,9 +879,28 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	}
 	loadBalance.ServiceID = info.ID
 	loadBalance.RoundType = params.RoundType
+	loadBalance.HashReplicas = params.HashReplicas
+	loadBalance.HashLoadFactor = params.HashLoadFactor
+	loadBalance.BreakerErrorPercent = params.BreakerErrorPercent
+	loadBalance.BreakerSlowPercent = params.BreakerSlowPercent
+	loadBalance.BreakerSlowTime = params.BreakerSlowTime
+	loadBalance.BreakerMinRequests = params.BreakerMinRequests
+	loadBalance.BreakerWindow = params.BreakerWindow
+	loadBalance.BreakerOpenTime = params.BreakerOpenTime
+	loadBalance.BreakerNode = params.BreakerNode
+	loadBalance.BreakerFallbackBody = params.BreakerFallbackBody
+	loadBalance.OutlierErrNum = params.OutlierErrNum
+	loadBalance.OutlierEjectTime = params.OutlierEjectTime
+	loadBalance.OutlierMaxEjectTime = params.OutlierMaxEjectTime
+	loadBalance.OutlierMaxPercent = params.OutlierMaxPercent
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.CheckMethod = params.CheckMethod
+	loadBalance.CheckTimeout = params.CheckTimeout
+	loadBalance.CheckInterval = params.CheckInterval
+	loadBalance.CheckRise = params.CheckRise
+	loadBalance.CheckFall = params.CheckFall
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2004, err)
@@ -612,6 +913,13 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	}
 	tcpRule.ServiceID = info.ID
 	tcpRule.Port = params.Port
+	tcpRule.TLSMode = params.TLSMode
+	tcpRule.SNIHosts = params.SNIHosts
+	if err := tcpRule.CheckPortShare(c, lib.GORMDefaultPool); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
 	if err := tcpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2005, err)
@@ -706,6 +1014,30 @@ func (admin *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		IpList:     params.IpList,
 		WeightList: params.WeightList,
 		ForbidList: params.ForbidList,
+
+		HashKeyType:    params.HashKeyType,
+		HashKeyName:    params.HashKeyName,
+		HashReplicas:   params.HashReplicas,
+		HashLoadFactor: params.HashLoadFactor,
+
+		BreakerErrorPercent: params.BreakerErrorPercent,
+		BreakerSlowPercent:  params.BreakerSlowPercent,
+		BreakerSlowTime:     params.BreakerSlowTime,
+		BreakerMinRequests:  params.BreakerMinRequests,
+		BreakerWindow:       params.BreakerWindow,
+		BreakerOpenTime:     params.BreakerOpenTime,
+		BreakerNode:         params.BreakerNode,
+		BreakerFallbackBody: params.BreakerFallbackBody,
+		OutlierErrNum:       params.OutlierErrNum,
+		OutlierEjectTime:    params.OutlierEjectTime,
+		OutlierMaxEjectTime: params.OutlierMaxEjectTime,
+		OutlierMaxPercent:   params.OutlierMaxPercent,
+
+		CheckMethod:   params.CheckMethod,
+		CheckTimeout:  params.CheckTimeout,
+		CheckInterval: params.CheckInterval,
+		CheckRise:     params.CheckRise,
+		CheckFall:     params.CheckFall,
 	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -791,9 +1123,30 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	}
 	loadBalance.ServiceID = info.ID
 	loadBalance.RoundType = params.RoundType
+	loadBalance.HashKeyType = params.HashKeyType
+	loadBalance.HashKeyName = params.HashKeyName
+	loadBalance.HashReplicas = params.HashReplicas
+	loadBalance.HashLoadFactor = params.HashLoadFactor
+	loadBalance.BreakerErrorPercent = params.BreakerErrorPercent
+	loadBalance.BreakerSlowPercent = params.BreakerSlowPercent
+	loadBalance.BreakerSlowTime = params.BreakerSlowTime
+	loadBalance.BreakerMinRequests = params.BreakerMinRequests
+	loadBalance.BreakerWindow = params.BreakerWindow
+	loadBalance.BreakerOpenTime = params.BreakerOpenTime
+	loadBalance.BreakerNode = params.BreakerNode
+	loadBalance.BreakerFallbackBody = params.BreakerFallbackBody
+	loadBalance.OutlierErrNum = params.OutlierErrNum
+	loadBalance.OutlierEjectTime = params.OutlierEjectTime
+	loadBalance.OutlierMaxEjectTime = params.OutlierMaxEjectTime
+	loadBalance.OutlierMaxPercent = params.OutlierMaxPercent
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.CheckMethod = params.CheckMethod
+	loadBalance.CheckTimeout = params.CheckTimeout
+	loadBalance.CheckInterval = params.CheckInterval
+	loadBalance.CheckRise = params.CheckRise
+	loadBalance.CheckFall = params.CheckFall
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2005, err)
@@ -833,3 +1186,532 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	middleware.ResponseSuccess(c, "")
 	return
 }
+
+// ServiceGroupList godoc
+// @Summary 灰度分组列表
+// @Description 灰度分组列表
+// @Tags 服务管理
+// @ID /service/service_group_list
+// @Accept  json
+// @Produce  json
+// @Param service_id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=[]dao.UpstreamGroup} "success"
+// @Router /service/service_group_list [get]
+func (service *ServiceController) ServiceGroupList(c *gin.Context) {
+	params := &dto.ServiceGroupListInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	upstreamGroup := &dao.UpstreamGroup{}
+	list, err := upstreamGroup.ListByServiceID(c, tx, params.ServiceID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, list)
+}
+
+// ServiceGroupAdd godoc
+// @Summary 添加灰度分组
+// @Description 添加灰度分组
+// @Tags 服务管理
+// @ID /service/service_group_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceGroupAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_group_add [post]
+func (service *ServiceController) ServiceGroupAdd(c *gin.Context) {
+	params := &dto.ServiceGroupAddInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ServiceID, IsDelete: 0}
+	if _, err = serviceInfo.Find(c, tx, serviceInfo); err != nil {
+		middleware.ResponseError(c, 2002, errors.New("服务不存在"))
+		return
+	}
+	search := &dao.UpstreamGroup{ServiceID: params.ServiceID, GroupName: params.GroupName}
+	if existGroup, err := search.Find(c, tx, search); err == nil && existGroup.IsDelete == 0 {
+		middleware.ResponseError(c, 2003, errors.New("分组名已存在"))
+		return
+	}
+
+	upstreamGroup := &dao.UpstreamGroup{
+		ServiceID:     params.ServiceID,
+		GroupName:     params.GroupName,
+		RoundType:     params.RoundType,
+		IpList:        params.IpList,
+		WeightList:    params.WeightList,
+		MatchType:     params.MatchType,
+		MatchName:     params.MatchName,
+		MatchValue:    params.MatchValue,
+		TrafficWeight: params.TrafficWeight,
+	}
+	if err := checkUpstreamGroup(c, upstreamGroup); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if err := upstreamGroup.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceGroupUpdate godoc
+// @Summary 修改灰度分组
+// @Description 修改灰度分组
+// @Tags 服务管理
+// @ID /service/service_group_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceGroupUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_group_update [post]
+func (service *ServiceController) ServiceGroupUpdate(c *gin.Context) {
+	params := &dto.ServiceGroupUpdateInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	upstreamGroup := &dao.UpstreamGroup{ID: params.ID}
+	upstreamGroup, err = upstreamGroup.Find(c, tx, upstreamGroup)
+	if err != nil || upstreamGroup.IsDelete == 1 {
+		middleware.ResponseError(c, 2002, errors.New("分组不存在"))
+		return
+	}
+	upstreamGroup.RoundType = params.RoundType
+	upstreamGroup.IpList = params.IpList
+	upstreamGroup.WeightList = params.WeightList
+	upstreamGroup.MatchType = params.MatchType
+	upstreamGroup.MatchName = params.MatchName
+	upstreamGroup.MatchValue = params.MatchValue
+	upstreamGroup.TrafficWeight = params.TrafficWeight
+	if err := checkUpstreamGroup(c, upstreamGroup); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if err := upstreamGroup.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceGroupDelete godoc
+// @Summary 删除灰度分组
+// @Description 删除灰度分组
+// @Tags 服务管理
+// @ID /service/service_group_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "分组ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_group_delete [get]
+func (service *ServiceController) ServiceGroupDelete(c *gin.Context) {
+	params := &dto.ServiceGroupDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	upstreamGroup := &dao.UpstreamGroup{ID: params.ID}
+	upstreamGroup, err = upstreamGroup.Find(c, tx, upstreamGroup)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	upstreamGroup.IsDelete = 1
+	if err := upstreamGroup.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceGroupStat godoc
+// @Summary 灰度分组统计
+// @Description 灰度分组统计
+// @Tags 服务管理
+// @ID /service/service_group_stat
+// @Accept  json
+// @Produce  json
+// @Param id query string true "分组ID"
+// @Success 200 {object} middleware.Response{data=dto.ServiceStatOutput} "success"
+// @Router /service/service_group_stat [get]
+func (service *ServiceController) ServiceGroupStat(c *gin.Context) {
+	params := &dto.ServiceGroupDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	upstreamGroup := &dao.UpstreamGroup{ID: params.ID}
+	upstreamGroup, err = upstreamGroup.Find(c, tx, upstreamGroup)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: upstreamGroup.ServiceID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+
+	counter, err := public.FlowCounterHandler.GetCounter(public.FlowGroupPrefix + serviceInfo.ServiceName + "_" + upstreamGroup.GroupName)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	todayList := []int64{}
+	currentTime := time.Now()
+	for i := 0; i <= currentTime.Hour(); i++ {
+		dateTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := counter.GetHourData(dateTime)
+		todayList = append(todayList, hourData)
+	}
+
+	yesterdayList := []int64{}
+	yesterTime := currentTime.Add(-1 * time.Duration(time.Hour*24))
+	for i := 0; i <= 23; i++ {
+		dateTime := time.Date(yesterTime.Year(), yesterTime.Month(), yesterTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := counter.GetHourData(dateTime)
+		yesterdayList = append(yesterdayList, hourData)
+	}
+	middleware.ResponseSuccess(c, &dto.ServiceStatOutput{
+		Today:     todayList,
+		Yesterday: yesterdayList,
+	})
+}
+
+// ServiceCachePurge godoc
+// @Summary 清除响应缓存
+// @Description 清除响应缓存
+// @Tags 服务管理
+// @ID /service/service_cache_purge
+// @Accept  json
+// @Produce  json
+// @Param id query string true "服务ID"
+// @Param key_prefix query string false "缓存key前缀"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_cache_purge [get]
+func (service *ServiceController) ServiceCachePurge(c *gin.Context) {
+	params := &dto.ServiceCachePurgeInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if serviceInfo.LoadType != public.LoadTypeHTTP {
+		middleware.ResponseError(c, 2003, errors.New("只有http服务支持响应缓存"))
+		return
+	}
+	//代理进程定期同步清除记录，早于此刻写入的匹配缓存失效
+	if err := public.AddCachePurge(serviceInfo.ServiceName, params.KeyPrefix); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+//校验分组配置，按比例分流的分组流量之和不能超过100
+// ServiceWebsocketStat godoc
+// @Summary websocket统计
+// @Description websocket统计
+// @Tags 服务管理
+// @ID /service/service_websocket_stat
+// @Accept  json
+// @Produce  json
+// @Param id query string true "服务ID"
+// @Success 200 {object} middleware.Response{data=dto.ServiceWebsocketStatOutput} "success"
+// @Router /service/service_websocket_stat [get]
+func (service *ServiceController) ServiceWebsocketStat(c *gin.Context) {
+	params := &dto.ServiceDeleteInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if serviceInfo.LoadType != public.LoadTypeHTTP {
+		middleware.ResponseError(c, 2003, errors.New("只有http服务支持websocket"))
+		return
+	}
+	data, err := public.GetWebsocketStatData(serviceInfo.ServiceName)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	middleware.ResponseSuccess(c, &dto.ServiceWebsocketStatOutput{
+		ActiveConn:   data.ActiveConn,
+		TotalConn:    data.TotalConn,
+		RejectedConn: data.RejectedConn,
+		FramesIn:     data.FramesIn,
+		FramesOut:    data.FramesOut,
+		BytesIn:      data.BytesIn,
+		BytesOut:     data.BytesOut,
+	})
+}
+
+// ServiceUpstreamTLS godoc
+// @Summary 设置下游tls
+// @Description 设置下游tls，http、tcp及grpc服务通用
+// @Tags 服务管理
+// @ID /service/service_upstream_tls
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceUpstreamTLSInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_upstream_tls [post]
+func (service *ServiceController) ServiceUpstreamTLS(c *gin.Context) {
+	params := &dto.ServiceUpstreamTLSInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	upstreamTLS := &dao.UpstreamTLS{ServiceID: serviceInfo.ID}
+	upstreamTLS, err = upstreamTLS.Find(c, tx, upstreamTLS)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	upstreamTLS.ServiceID = serviceInfo.ID
+	upstreamTLS.Enable = params.Enable
+	upstreamTLS.CaCert = params.CaCert
+	//详情接口不返回私钥，证书不变且私钥为空时保留原私钥
+	if params.ClientKey != "" || params.ClientCert != upstreamTLS.ClientCert {
+		upstreamTLS.ClientKey = params.ClientKey
+	}
+	upstreamTLS.ClientCert = params.ClientCert
+	upstreamTLS.ServerName = params.ServerName
+	upstreamTLS.MinVersion = params.MinVersion
+	upstreamTLS.InsecureSkipVerify = params.InsecureSkipVerify
+	//证书解析失败时不保存，避免代理加载后无法连接下游
+	if _, err := upstreamTLS.TLSConfig(); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if err := upstreamTLS.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+// ServiceClientAuth godoc
+// @Summary 设置客户端证书认证
+// @Description 设置客户端证书认证，https及grpc服务可用，证书标识可在租户中映射
+// @Tags 服务管理
+// @ID /service/service_client_auth
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceClientAuthInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_client_auth [post]
+func (service *ServiceController) ServiceClientAuth(c *gin.Context) {
+	params := &dto.ServiceClientAuthInput{}
+	if err := params.BindValidParam(c); err != nil {
+		middleware.ResponseError(c, 2000, err)
+		return
+	}
+
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	serviceInfo := &dao.ServiceInfo{ID: params.ID}
+	serviceInfo, err = serviceInfo.Find(c, tx, serviceInfo)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if serviceInfo.LoadType == public.LoadTypeTCP && params.Mode != dao.ClientAuthModeNone {
+		middleware.ResponseError(c, 2003, errors.New("tcp服务不支持客户端证书认证"))
+		return
+	}
+	clientAuth := &dao.ClientAuth{ServiceID: serviceInfo.ID}
+	clientAuth, err = clientAuth.Find(c, tx, clientAuth)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	clientAuth.ServiceID = serviceInfo.ID
+	clientAuth.Mode = params.Mode
+	clientAuth.CaCert = params.CaCert
+	clientAuth.AllowedSubjects = params.AllowedSubjects
+	if clientAuth.Enabled() {
+		if _, err := clientAuth.CertPool(); err != nil {
+			middleware.ResponseError(c, 2005, err)
+			return
+		}
+	}
+	if err := clientAuth.Save(c, tx); err != nil {
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	middleware.ResponseSuccess(c, "")
+}
+
+func checkUpstreamGroup(c *gin.Context, upstreamGroup *dao.UpstreamGroup) error {
+	if len(strings.Split(upstreamGroup.IpList, ",")) != len(strings.Split(upstreamGroup.WeightList, ",")) {
+		return errors.New("IP列表与权重列表数量不一致")
+	}
+	switch upstreamGroup.MatchType {
+	case public.UpstreamMatchWeight:
+		if upstreamGroup.TrafficWeight == 0 {
+			return errors.New("按比例分流时流量百分比不能为0")
+		}
+	case public.UpstreamMatchHeader, public.UpstreamMatchCookie:
+		if upstreamGroup.MatchName == "" || upstreamGroup.MatchValue == "" {
+			return errors.New("header或cookie分流时匹配名及匹配值不能为空")
+		}
+	default:
+		if upstreamGroup.MatchValue == "" {
+			return errors.New("匹配值不能为空")
+		}
+	}
+	if upstreamGroup.MatchType != public.UpstreamMatchWeight {
+		return nil
+	}
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	list, err := upstreamGroup.ListByServiceID(c, tx, upstreamGroup.ServiceID)
+	if err != nil {
+		return err
+	}
+	total := upstreamGroup.TrafficWeight
+	for _, item := range list {
+		if item.ID != upstreamGroup.ID && item.MatchType == public.UpstreamMatchWeight {
+			total += item.TrafficWeight
+		}
+	}
+	if total > 100 {
+		return errors.New(fmt.Sprintf("按比例分流的分组流量之和不能超过100 current:%v", total))
+	}
+	return nil
+}
+
+func httpAccessRulesFromInput(inputs []dto.HTTPAccessRuleInput) []*dao.HttpRule {
+	accessRules := []*dao.HttpRule{}
+	for _, input := range inputs {
+		accessRules = append(accessRules, &dao.HttpRule{
+			RuleType:     input.RuleType,
+			Rule:         input.Rule,
+			NeedStripUri: input.NeedStripUri,
+			UrlRewrite:   input.UrlRewrite,
+			Priority:     input.Priority,
+			MatchMethod:  input.MatchMethod,
+			MatchHeader:  input.MatchHeader,
+			MatchQuery:   input.MatchQuery,
+		})
+	}
+	return accessRules
+}
+
+//rename需指定新的header名
+func httpHeaderRulesFromInput(inputs []dto.HTTPHeaderRuleInput) ([]*dao.HeaderRule, error) {
+	headerRules := []*dao.HeaderRule{}
+	for _, input := range inputs {
+		if input.Op == "rename" && input.HeaderValue == "" {
+			return nil, errors.New(fmt.Sprintf("header改名缺少新名称 header:%v", input.HeaderName))
+		}
+		headerRules = append(headerRules, &dao.HeaderRule{
+			Direction:   input.Direction,
+			Op:          input.Op,
+			HeaderName:  input.HeaderName,
+			HeaderValue: input.HeaderValue,
+		})
+	}
+	return headerRules, nil
+}
+
+//校验服务的全部接入规则：规则可生成路由，且与本服务及其他服务的规则不重复
+func checkHTTPAccessRules(c *gin.Context, tx *gorm.DB, httpRules []*dao.HttpRule) error {
+	ruleMap := map[string]bool{}
+	for _, httpRule := range httpRules {
+		if err := httpRule.CheckRoute(); err != nil {
+			return err
+		}
+		ruleKey := fmt.Sprintf("%d %s %s %s %s", httpRule.RuleType, httpRule.Rule, httpRule.MatchMethod, httpRule.MatchHeader, httpRule.MatchQuery)
+		if ruleMap[ruleKey] {
+			return errors.New(fmt.Sprintf("服务接入前缀或域名重复 rule:%v", httpRule.Rule))
+		}
+		ruleMap[ruleKey] = true
+		exists, err := httpRule.ExistsConflict(c, tx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errors.New(fmt.Sprintf("服务接入前缀或域名已存在 rule:%v", httpRule.Rule))
+		}
+	}
+	return nil
+}
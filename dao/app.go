@@ -7,21 +7,24 @@ import (
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"time"
 )
 
 type App struct {
-	ID        int64     `json:"id" gorm:"primary_key"`
-	AppID     string    `json:"app_id" gorm:"column:app_id" description:"租户id	"`
-	Name      string    `json:"name" gorm:"column:name" description:"租户名称	"`
-	Secret    string    `json:"secret" gorm:"column:secret" description:"密钥"`
-	WhiteIPS  string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配"`
-	Qpd       int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
-	Qps       int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
-	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
-	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
-	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+	ID           int64     `json:"id" gorm:"primary_key"`
+	AppID        string    `json:"app_id" gorm:"column:app_id" description:"租户id	"`
+	Name         string    `json:"name" gorm:"column:name" description:"租户名称	"`
+	Secret       string    `json:"secret" gorm:"column:secret" description:"密钥"`
+	WhiteIPS     string    `json:"white_ips" gorm:"column:white_ips" description:"ip白名单，支持前缀匹配"`
+	Qpd          int64     `json:"qpd" gorm:"column:qpd" description:"日请求量限制"`
+	Qps          int64     `json:"qps" gorm:"column:qps" description:"每秒请求量限制"`
+	CertSubjects string    `json:"cert_subjects" gorm:"column:cert_subjects" description:"客户端证书标识 CN或SAN, 多个逗号间隔, 双向tls认证时映射为该租户"`
+	CertServices string    `json:"cert_services" gorm:"column:cert_services" description:"证书标识生效的服务名, 多个逗号间隔, 为空不映射"`
+	CreatedAt    time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
+	UpdatedAt    time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete     int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
 }
 
 func (t *App) TableName() string {
@@ -66,6 +69,16 @@ func (t *App) APPList(c *gin.Context, tx *gorm.DB, params *dto.APPListInput) ([]
 	return list, count, nil
 }
 
+//证书标识是否在该服务生效
+func (t *App) CertBoundService(serviceName string) bool {
+	for _, item := range strings.Split(t.CertServices, ",") {
+		if item = strings.TrimSpace(item); item != "" && item == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
 var AppManagerHandler *AppManager
 
 func init() {
@@ -89,33 +102,93 @@ func NewAppManager() *AppManager {
 	}
 }
 
-
 func (s *AppManager) GetAppList() []*App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
 	return s.AppSlice
 }
 
+//按客户端证书标识查找租户，只匹配证书标识绑定了该服务的租户
+//各服务信任的CA不同，未绑定时其它服务CA签发的同名证书不能冒充租户
+func (s *AppManager) GetAppByCertIdentity(serviceName string, identities []string) *App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for _, appInfo := range s.AppSlice {
+		if !appInfo.CertBoundService(serviceName) {
+			continue
+		}
+		for _, subject := range strings.Split(appInfo.CertSubjects, ",") {
+			subject = strings.TrimSpace(subject)
+			if subject == "" {
+				continue
+			}
+			for _, identity := range identities {
+				if subject == identity {
+					return appInfo
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (s *AppManager) LoadOnce() error {
 	s.init.Do(func() {
-		appInfo := &App{}
-		c, _ := gin.CreateTestContext(httptest.NewRecorder())
-		tx, err := lib.GetGormPool("default")
-		if err != nil {
-			s.err = err
-			return
-		}
-		params := &dto.APPListInput{PageNo: 1, PageSize: 99999}
-		list, _, err := appInfo.APPList(c, tx, params)
+		appMap, appSlice, err := s.loadFromDB()
 		if err != nil {
 			s.err = err
 			return
 		}
 		s.Locker.Lock()
 		defer s.Locker.Unlock()
-		for _, listItem := range list {
-			tmpItem := listItem
-			s.AppMap[listItem.AppID] = &tmpItem
-			s.AppSlice = append(s.AppSlice, &tmpItem)
-		}
+		s.AppMap = appMap
+		s.AppSlice = appSlice
 	})
 	return s.err
 }
+
+//重新读取租户配置，与内存快照比对后整体替换，并清理变更租户的限流器
+func (s *AppManager) Reload() error {
+	appMap, appSlice, err := s.loadFromDB()
+	if err != nil {
+		return err
+	}
+	s.Locker.Lock()
+	changedList := []string{}
+	for appID, oldItem := range s.AppMap {
+		newItem, ok := appMap[appID]
+		if !ok || public.Obj2Json(oldItem) != public.Obj2Json(newItem) {
+			changedList = append(changedList, appID)
+		}
+	}
+	s.AppMap = appMap
+	s.AppSlice = appSlice
+	s.Locker.Unlock()
+
+	for _, appID := range changedList {
+		public.FlowLimiterHandler.RemoveByOwner(public.FlowAppPrefix + appID)
+	}
+	return nil
+}
+
+func (s *AppManager) loadFromDB() (map[string]*App, []*App, error) {
+	appInfo := &App{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, nil, err
+	}
+	params := &dto.APPListInput{PageNo: 1, PageSize: 99999}
+	list, _, err := appInfo.APPList(c, tx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	appMap := map[string]*App{}
+	appSlice := []*App{}
+	for _, listItem := range list {
+		tmpItem := listItem
+		appMap[listItem.AppID] = &tmpItem
+		appSlice = append(appSlice, &tmpItem)
+	}
+	return appMap, appSlice, nil
+}
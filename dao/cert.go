@@ -0,0 +1,249 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/e421083458/go_gateway/dto"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Cert struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"column:name" description:"证书名称"`
+	Domains   string    `json:"domains" gorm:"column:domains" description:"证书包含的域名, 从证书解析, 逗号间隔"`
+	Issuer    string    `json:"issuer" gorm:"column:issuer" description:"签发者, 从证书解析"`
+	CertPem   string    `json:"cert_pem" gorm:"column:cert_pem" description:"证书 PEM格式, 可包含中间证书"`
+	KeyPem    string    `json:"-" gorm:"column:key_pem" description:"私钥 PEM格式"`
+	IsDefault int       `json:"is_default" gorm:"column:is_default" description:"默认证书 1=是, 客户端未携带SNI或无匹配域名时使用"`
+	NotBefore time.Time `json:"not_before" gorm:"column:not_before" description:"生效时间"`
+	NotAfter  time.Time `json:"not_after" gorm:"column:not_after" description:"过期时间"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *Cert) TableName() string {
+	return "gateway_cert"
+}
+
+func (t *Cert) Find(c *gin.Context, tx *gorm.DB, search *Cert) (*Cert, error) {
+	model := &Cert{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *Cert) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Cert) CertList(c *gin.Context, tx *gorm.DB, params *dto.CertListInput) ([]Cert, int64, error) {
+	var list []Cert
+	var count int64
+	offset := (params.PageNo - 1) * params.PageSize
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("is_delete=?", 0)
+	if params.Info != "" {
+		query = query.Where(" (name like ? or domains like ?)", "%"+params.Info+"%", "%"+params.Info+"%")
+	}
+	err := query.Limit(params.PageSize).Offset(offset).Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	errCount := query.Count(&count).Error
+	if errCount != nil {
+		return nil, 0, err
+	}
+	return list, count, nil
+}
+
+//统计即将过期(含已过期)的证书数
+func (t *Cert) ExpiringCount(c *gin.Context, tx *gorm.DB, before time.Time) (int64, error) {
+	var count int64
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Where("is_delete=? and not_after<?", 0, before)
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+//取消其他证书的默认标记，保证只有一个默认证书
+func (t *Cert) ResetDefault(c *gin.Context, tx *gorm.DB) error {
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	return query.Table(t.TableName()).Where("is_default=? and id<>?", 1, t.ID).Update("is_default", 0).Error
+}
+
+//解析证书及私钥，并以证书内容回填域名、签发者及有效期
+func (t *Cert) Parse() (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(t.CertPem), []byte(t.KeyPem))
+	if err != nil {
+		return nil, errors.Wrap(err, "证书或私钥错误")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "证书解析错误")
+	}
+	cert.Leaf = leaf
+	domains := []string{}
+	for _, name := range leaf.DNSNames {
+		domains = append(domains, strings.ToLower(name))
+	}
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = append(domains, strings.ToLower(leaf.Subject.CommonName))
+	}
+	t.Domains = strings.Join(domains, ",")
+	t.Issuer = leaf.Issuer.CommonName
+	t.NotBefore = leaf.NotBefore
+	t.NotAfter = leaf.NotAfter
+	return &cert, nil
+}
+
+var CertManagerHandler *CertManager
+
+func init() {
+	CertManagerHandler = NewCertManager()
+}
+
+type parsedCert struct {
+	updatedAt time.Time
+	cert      *tls.Certificate
+}
+
+//https证书管理，按SNI选择证书，定时重新加载实现证书轮换
+type CertManager struct {
+	CertMap      map[string]*tls.Certificate
+	DefaultCert  *tls.Certificate
+	FallbackCert *tls.Certificate //证书库均未匹配时使用的本地证书
+	Locker       sync.RWMutex
+	parsedMap    map[int64]*parsedCert
+	init         sync.Once
+	err          error
+}
+
+func NewCertManager() *CertManager {
+	return &CertManager{
+		CertMap:   map[string]*tls.Certificate{},
+		Locker:    sync.RWMutex{},
+		parsedMap: map[int64]*parsedCert{},
+		init:      sync.Once{},
+	}
+}
+
+func (s *CertManager) LoadOnce() error {
+	s.init.Do(func() {
+		s.FallbackCert = loadFallbackCert()
+		s.err = s.Reload()
+	})
+	return s.err
+}
+
+//本地证书文件不存在时不启用
+func loadFallbackCert() *tls.Certificate {
+	//todo 以下路径只在编译机有效，如果是交叉编译情况下需要单独设置路径
+	certFile := lib.GetStringConf("proxy.https.cert_file")
+	keyFile := lib.GetStringConf("proxy.https.key_file")
+	if certFile == "" || keyFile == "" {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Printf(" [WARN] load https cert_file err:%v\n", err)
+		return nil
+	}
+	return &cert
+}
+
+//重新读取证书，未变更的证书复用解析结果，解析失败的证书跳过
+func (s *CertManager) Reload() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return err
+	}
+	certInfo := &Cert{}
+	list, _, err := certInfo.CertList(c, tx, &dto.CertListInput{PageNo: 1, PageSize: 99999})
+	if err != nil {
+		return err
+	}
+
+	s.Locker.RLock()
+	oldParsedMap := s.parsedMap
+	s.Locker.RUnlock()
+	certMap := map[string]*tls.Certificate{}
+	var defaultCert *tls.Certificate
+	parsedMap := map[int64]*parsedCert{}
+	for _, listItem := range list {
+		item := listItem
+		parsed, ok := oldParsedMap[item.ID]
+		if !ok || !parsed.updatedAt.Equal(item.UpdatedAt) {
+			cert, err := item.Parse()
+			if err != nil {
+				log.Printf(" [ERROR] cert_load id:%d name:%s err:%v\n", item.ID, item.Name, err)
+				continue
+			}
+			parsed = &parsedCert{updatedAt: item.UpdatedAt, cert: cert}
+		}
+		parsedMap[item.ID] = parsed
+		//同一域名存在多个证书时使用过期时间最晚的，便于先上传新证书再删除旧证书
+		for _, domain := range strings.Split(item.Domains, ",") {
+			if domain == "" {
+				continue
+			}
+			if exist, ok := certMap[domain]; !ok || parsed.cert.Leaf.NotAfter.After(exist.Leaf.NotAfter) {
+				certMap[domain] = parsed.cert
+			}
+		}
+		if item.IsDefault == 1 && (defaultCert == nil || parsed.cert.Leaf.NotAfter.After(defaultCert.Leaf.NotAfter)) {
+			defaultCert = parsed.cert
+		}
+	}
+
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.CertMap = certMap
+	s.DefaultCert = defaultCert
+	s.parsedMap = parsedMap
+	return nil
+}
+
+//先精确匹配域名，再匹配*.开头的通配域名(只匹配一级)，都未匹配时返回默认证书
+func (s *CertManager) GetCertificate(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if name != "" {
+		if cert, ok := s.CertMap[name]; ok {
+			return cert
+		}
+		if index := strings.Index(name, "."); index > 0 {
+			if cert, ok := s.CertMap["*"+name[index:]]; ok {
+				return cert
+			}
+		}
+	}
+	return s.DefaultCert
+}
+
+//供tls.Config.GetCertificate使用，握手时按SNI选择证书，证书轮换后的新连接即时生效
+func (s *CertManager) GetTLSCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := s.GetCertificate(hello.ServerName); cert != nil {
+		return cert, nil
+	}
+	if s.FallbackCert != nil {
+		return s.FallbackCert, nil
+	}
+	return nil, errors.New("no certificate for server name: " + hello.ServerName)
+}
@@ -0,0 +1,36 @@
+package dao
+
+import (
+	"log"
+	"time"
+)
+
+//重新加载服务、租户及证书配置
+func ReloadAll() error {
+	if err := ServiceManagerHandler.Reload(); err != nil {
+		return err
+	}
+	if err := AppManagerHandler.Reload(); err != nil {
+		return err
+	}
+	if err := CertManagerHandler.Reload(); err != nil {
+		return err
+	}
+	return nil
+}
+
+//定时重新加载配置，interval<=0时不开启
+func ReloadInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := ReloadAll(); err != nil {
+				log.Printf(" [ERROR] reload_interval err:%v\n", err)
+			}
+		}
+	}()
+}
@@ -6,18 +6,27 @@ import (
 	"github.com/e421083458/go_gateway/golang_common/lib"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"log"
 	"net/http/httptest"
-	"strings"
 	"sync"
 )
 
 type ServiceDetail struct {
 	Info          *ServiceInfo   `json:"info" description:"基本信息"`
 	HTTPRule      *HttpRule      `json:"http_rule" description:"http_rule"`
+	AccessRules   []*HttpRule    `json:"access_rules" description:"附加的http接入规则"`
 	TCPRule       *TcpRule       `json:"tcp_rule" description:"tcp_rule"`
 	GRPCRule      *GrpcRule      `json:"grpc_rule" description:"grpc_rule"`
 	LoadBalance   *LoadBalance   `json:"load_balance" description:"load_balance"`
 	AccessControl *AccessControl `json:"access_control" description:"access_control"`
+
+	UpstreamGroups []*UpstreamGroup          `json:"upstream_groups" description:"灰度分组"`
+	HeaderRules    []*HeaderRule             `json:"header_rules" description:"请求及响应header规则"`
+	Cors           *CorsPolicy               `json:"cors" description:"跨域策略"`
+	UpstreamTLS    *UpstreamTLS              `json:"upstream_tls" description:"下游tls配置"`
+	ClientAuth     *ClientAuth               `json:"client_auth" description:"客户端证书认证"`
+	groupName      string                    //当前所选灰度分组，仅在代理请求中使用
+	groupDetails   map[string]*ServiceDetail //按分组名预先生成的分组副本
 }
 
 var ServiceManagerHandler *ServiceManager
@@ -29,23 +38,43 @@ func init() {
 type ServiceManager struct {
 	ServiceMap   map[string]*ServiceDetail
 	ServiceSlice []*ServiceDetail
+	HTTPRouter   *HTTPRouter
 	Locker       sync.RWMutex
 	init         sync.Once
 	err          error
+	observers    []ServiceObserver
+}
+
+//服务变更监听者，reload完成后回调
+type ServiceObserver interface {
+	Update()
 }
 
 func NewServiceManager() *ServiceManager {
 	return &ServiceManager{
 		ServiceMap:   map[string]*ServiceDetail{},
 		ServiceSlice: []*ServiceDetail{},
+		HTTPRouter:   NewHTTPRouter(),
 		Locker:       sync.RWMutex{},
 		init:         sync.Once{},
 	}
 }
 
+func (s *ServiceManager) Attach(o ServiceObserver) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.observers = append(s.observers, o)
+}
+
+func (s *ServiceManager) GetServiceList() []*ServiceDetail {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	return s.ServiceSlice
+}
+
 func (s *ServiceManager) GetTcpServiceList() []*ServiceDetail {
 	list := []*ServiceDetail{}
-	for _, serverItem := range s.ServiceSlice {
+	for _, serverItem := range s.GetServiceList() {
 		tempItem := serverItem
 		if tempItem.Info.LoadType == public.LoadTypeTCP {
 			list = append(list, tempItem)
@@ -56,7 +85,7 @@ func (s *ServiceManager) GetTcpServiceList() []*ServiceDetail {
 
 func (s *ServiceManager) GetGrpcServiceList() []*ServiceDetail {
 	list := []*ServiceDetail{}
-	for _, serverItem := range s.ServiceSlice {
+	for _, serverItem := range s.GetServiceList() {
 		tempItem := serverItem
 		if tempItem.Info.LoadType == public.LoadTypeGRPC {
 			list = append(list, tempItem)
@@ -65,61 +94,125 @@ func (s *ServiceManager) GetGrpcServiceList() []*ServiceDetail {
 	return list
 }
 
-func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, error) {
-	//1、前缀匹配 /abc ==> serviceSlice.rule
-	//2、域名匹配 www.test.com ==> serviceSlice.rule
-	//host c.Request.Host
-	//path c.Request.URL.Path
-	host := c.Request.Host
-	host = host[0:strings.Index(host, ":")]
-	path := c.Request.URL.Path
-	for _, serviceItem := range s.ServiceSlice {
+//是否存在开启客户端证书认证的http服务，https握手时据此决定是否请求客户端证书
+func (s *ServiceManager) HasHTTPClientAuth() bool {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	for _, serverItem := range s.ServiceSlice {
+		if serverItem.Info.LoadType == public.LoadTypeHTTP && serverItem.ClientAuth.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+//匹配接入方式 基于请求的域名、路径、方法、header及query，见HTTPRouter
+//返回命中的服务及接入规则，strip_uri及url重写按命中的规则处理
+func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, *HttpRule, error) {
+	s.Locker.RLock()
+	router := s.HTTPRouter
+	s.Locker.RUnlock()
+	if serviceItem, rule := router.Match(c.Request); serviceItem != nil {
+		return serviceItem, rule, nil
+	}
+	return nil, nil, errors.New("not matched service")
+}
+
+//按服务列表生成http路由表，配置有误的规则跳过
+func buildHTTPRouter(serviceSlice []*ServiceDetail) *HTTPRouter {
+	router := NewHTTPRouter()
+	for _, serviceItem := range serviceSlice {
 		if serviceItem.Info.LoadType != public.LoadTypeHTTP {
 			continue
 		}
-		if serviceItem.HTTPRule.RuleType == public.HTTPRuleTypeDomain {
-			if serviceItem.HTTPRule.Rule == host {
-				return serviceItem, nil
-			}
-		}
-		if serviceItem.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL {
-			if strings.HasPrefix(path, serviceItem.HTTPRule.Rule) {
-				return serviceItem, nil
+		for _, rule := range serviceItem.GetHTTPRules() {
+			if err := router.Add(serviceItem, rule); err != nil {
+				log.Printf(" [ERROR] http_router_add %v rule:%v err:%v\n", serviceItem.Info.ServiceName, rule.Rule, err)
 			}
 		}
 	}
-	return nil, errors.New("not matched service")
+	return router
+}
+
+//全部http接入规则，首条为服务主规则
+func (s *ServiceDetail) GetHTTPRules() []*HttpRule {
+	return append([]*HttpRule{s.HTTPRule}, s.AccessRules...)
 }
 
 func (s *ServiceManager) LoadOnce() error {
 	s.init.Do(func() {
-		serviceInfo := &ServiceInfo{}
-		c, _ := gin.CreateTestContext(httptest.NewRecorder())
-		tx, err := lib.GetGormPool("default")
-		if err != nil {
-			s.err = err
-			return
-		}
-		params := &dto.ServiceListInput{PageNo: 1, PageSize: 99999}
-		list, _, err := serviceInfo.PageList(c, tx, params)
+		serviceMap, serviceSlice, err := s.loadFromDB()
 		if err != nil {
 			s.err = err
 			return
 		}
 		s.Locker.Lock()
 		defer s.Locker.Unlock()
-		for _, listItem := range list {
-			tmpItem := listItem
-			serviceDetail, err := tmpItem.ServiceDetail(c, tx, &tmpItem)
-			//fmt.Println("serviceDetail")
-			//fmt.Println(public.Obj2Json(serviceDetail))
-			if err != nil {
-				s.err = err
-				return
-			}
-			s.ServiceMap[listItem.ServiceName] = serviceDetail
-			s.ServiceSlice = append(s.ServiceSlice, serviceDetail)
-		}
+		s.ServiceMap = serviceMap
+		s.ServiceSlice = serviceSlice
+		s.HTTPRouter = buildHTTPRouter(serviceSlice)
 	})
 	return s.err
 }
+
+//重新读取服务配置，与内存快照比对后整体替换，并清理变更服务的缓存对象
+func (s *ServiceManager) Reload() error {
+	serviceMap, serviceSlice, err := s.loadFromDB()
+	if err != nil {
+		return err
+	}
+	router := buildHTTPRouter(serviceSlice)
+	s.Locker.Lock()
+	changedList := []string{}
+	for serviceName, oldItem := range s.ServiceMap {
+		newItem, ok := serviceMap[serviceName]
+		if !ok || public.Obj2Json(oldItem) != public.Obj2Json(newItem) {
+			changedList = append(changedList, serviceName)
+		}
+	}
+	s.ServiceMap = serviceMap
+	s.ServiceSlice = serviceSlice
+	s.HTTPRouter = router
+	observers := s.observers
+	s.Locker.Unlock()
+
+	for _, serviceName := range changedList {
+		UpstreamTLSHandler.Remove(serviceName)
+		ClientAuthHandler.Remove(serviceName)
+		LoadBalancerHandler.Remove(serviceName)
+		TransportorHandler.Remove(serviceName)
+		public.RetryBudgetHandler.Remove(serviceName)
+		public.FlowLimiterHandler.RemoveByOwner(public.FlowServicePrefix + serviceName)
+	}
+	for _, obs := range observers {
+		obs.Update()
+	}
+	return nil
+}
+
+func (s *ServiceManager) loadFromDB() (map[string]*ServiceDetail, []*ServiceDetail, error) {
+	serviceInfo := &ServiceInfo{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, nil, err
+	}
+	params := &dto.ServiceListInput{PageNo: 1, PageSize: 99999}
+	list, _, err := serviceInfo.PageList(c, tx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceMap := map[string]*ServiceDetail{}
+	serviceSlice := []*ServiceDetail{}
+	for _, listItem := range list {
+		tmpItem := listItem
+		serviceDetail, err := tmpItem.ServiceDetail(c, tx, &tmpItem)
+		if err != nil {
+			return nil, nil, err
+		}
+		serviceDetail.initGroupDetails()
+		serviceMap[listItem.ServiceName] = serviceDetail
+		serviceSlice = append(serviceSlice, serviceDetail)
+	}
+	return serviceMap, serviceSlice, nil
+}
@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"crypto/x509"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	ClientAuthModeNone     = 0
+	ClientAuthModeOptional = 1
+	ClientAuthModeRequired = 2
+)
+
+type ClientAuth struct {
+	ID              int64  `json:"id" gorm:"primary_key"`
+	ServiceID       int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Mode            int    `json:"mode" gorm:"column:mode" description:"客户端证书认证 0=关闭 1=可选 2=必须"`
+	CaCert          string `json:"ca_cert" gorm:"column:ca_cert" description:"信任的客户端CA证书 PEM格式, 可包含多个"`
+	AllowedSubjects string `json:"allowed_subjects" gorm:"column:allowed_subjects" description:"允许的证书标识 匹配CN及SAN, 支持*通配, 多个逗号间隔, 为空不限制且不映射租户"`
+}
+
+func (t *ClientAuth) TableName() string {
+	return "gateway_service_client_auth"
+}
+
+func (t *ClientAuth) Find(c *gin.Context, tx *gorm.DB, search *ClientAuth) (*ClientAuth, error) {
+	model := &ClientAuth{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *ClientAuth) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ClientAuth) Enabled() bool {
+	return t != nil && t.Mode != ClientAuthModeNone
+}
+
+//解析信任的客户端CA
+func (t *ClientAuth) CertPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(t.CaCert)) {
+		return nil, errors.New("客户端CA证书格式错误")
+	}
+	return pool, nil
+}
+
+//证书标识满足任意一个允许规则即通过，未配置规则时不限制
+func (t *ClientAuth) AllowIdentity(identities []string) bool {
+	if strings.TrimSpace(t.AllowedSubjects) == "" {
+		return true
+	}
+	return len(t.AllowedIdentities(identities)) > 0
+}
+
+//返回满足允许规则的证书标识，用于映射租户，未配置规则时返回空，不映射租户
+func (t *ClientAuth) AllowedIdentities(identities []string) []string {
+	allowed := []string{}
+	for _, pattern := range strings.Split(t.AllowedSubjects, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$")
+		if err != nil {
+			continue
+		}
+		for _, identity := range identities {
+			if re.MatchString(identity) && !public.InStringSlice(allowed, identity) {
+				allowed = append(allowed, identity)
+			}
+		}
+	}
+	return allowed
+}
+
+//证书标识：CN及SAN中的域名、邮箱、URI
+func CertIdentities(cert *x509.Certificate) []string {
+	identities := []string{}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return identities
+}
+
+var ClientAuthHandler *ClientAuthManager
+
+type ClientAuthManager struct {
+	CertPoolMap map[string]*x509.CertPool
+	Locker      sync.RWMutex
+}
+
+func NewClientAuthManager() *ClientAuthManager {
+	return &ClientAuthManager{
+		CertPoolMap: map[string]*x509.CertPool{},
+		Locker:      sync.RWMutex{},
+	}
+}
+
+func init() {
+	ClientAuthHandler = NewClientAuthManager()
+}
+
+//按服务缓存解析后的客户端CA，未启用客户端证书认证时返回nil
+func (m *ClientAuthManager) GetCertPool(service *ServiceDetail) (*x509.CertPool, error) {
+	if !service.ClientAuth.Enabled() {
+		return nil, nil
+	}
+	m.Locker.RLock()
+	pool, ok := m.CertPoolMap[service.Info.ServiceName]
+	m.Locker.RUnlock()
+	if ok {
+		return pool, nil
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if pool, ok := m.CertPoolMap[service.Info.ServiceName]; ok {
+		return pool, nil
+	}
+	pool, err := service.ClientAuth.CertPool()
+	if err != nil {
+		return nil, err
+	}
+	m.CertPoolMap[service.Info.ServiceName] = pool
+	return pool, nil
+}
+
+//校验客户端证书链并返回证书标识，certs为客户端发送的证书，首个为客户端证书
+func (m *ClientAuthManager) Verify(service *ServiceDetail, certs []*x509.Certificate) ([]string, error) {
+	pool, err := m.GetCertPool(service)
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil || len(certs) == 0 {
+		return nil, errors.New("client certificate not found")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, errors.Wrap(err, "client certificate verify")
+	}
+	identities := CertIdentities(certs[0])
+	if !service.ClientAuth.AllowIdentity(identities) {
+		return nil, errors.New("client certificate subject not allowed")
+	}
+	return identities, nil
+}
+
+func (m *ClientAuthManager) Remove(serviceName string) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	delete(m.CertPoolMap, serviceName)
+}
@@ -0,0 +1,65 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientAuthAllowedIdentities(t *testing.T) {
+	identities := []string{"client-a", "a.example.com", "ops@example.com", "spiffe://example.com/app"}
+	cases := []struct {
+		allowedSubjects string
+		allow           bool
+		allowed         string
+	}{
+		{"", true, ""},
+		{" , ", false, ""},
+		{"client-a", true, "client-a"},
+		{"client-b", false, ""},
+		{"*.example.com", true, "a.example.com"},
+		{"client-*, *@example.com", true, "client-a,ops@example.com"},
+		{"spiffe://example.com/*,client-a", true, "spiffe://example.com/app,client-a"},
+		{"client-a,client-*", true, "client-a"},
+		{"example.com", false, ""},
+	}
+	for _, c := range cases {
+		auth := &ClientAuth{Mode: ClientAuthModeRequired, AllowedSubjects: c.allowedSubjects}
+		if allow := auth.AllowIdentity(identities); allow != c.allow {
+			t.Errorf("%q: expect allow=%v, got %v", c.allowedSubjects, c.allow, allow)
+		}
+		if allowed := strings.Join(auth.AllowedIdentities(identities), ","); allowed != c.allowed {
+			t.Errorf("%q: expect allowed %q, got %q", c.allowedSubjects, c.allowed, allowed)
+		}
+	}
+}
+
+func TestGetAppByCertIdentity(t *testing.T) {
+	manager := NewAppManager()
+	manager.AppSlice = []*App{
+		{AppID: "app_a", CertSubjects: "client-a, a.example.com", CertServices: "service_a, service_b"},
+		{AppID: "app_b", CertSubjects: "client-b"},
+		{AppID: "app_c", CertSubjects: "client-c", CertServices: "service_c"},
+	}
+	cases := []struct {
+		serviceName string
+		identities  []string
+		appID       string
+	}{
+		{"service_a", []string{"client-a"}, "app_a"},
+		{"service_b", []string{"other", "a.example.com"}, "app_a"},
+		{"service_c", []string{"client-a"}, ""},
+		{"service_a", []string{"client-b"}, ""},
+		{"service_c", []string{"client-c"}, "app_c"},
+		{"service", []string{"client-c"}, ""},
+		{"service_a", []string{}, ""},
+	}
+	for _, c := range cases {
+		appID := ""
+		if appInfo := manager.GetAppByCertIdentity(c.serviceName, c.identities); appInfo != nil {
+			appID = appInfo.AppID
+		}
+		if appID != c.appID {
+			t.Errorf("%s %v: expect %q, got %q", c.serviceName, c.identities, c.appID, appID)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strings"
+)
+
+type CorsPolicy struct {
+	ID               int64  `json:"id" gorm:"primary_key"`
+	ServiceID        int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Enable           int    `json:"enable" gorm:"column:enable" description:"启用跨域 1=启用"`
+	AllowOrigins     string `json:"allow_origins" gorm:"column:allow_origins" description:"允许的来源 逗号间隔，支持*及*.example.com"`
+	AllowMethods     string `json:"allow_methods" gorm:"column:allow_methods" description:"允许的请求方法 逗号间隔，为空不限制"`
+	AllowHeaders     string `json:"allow_headers" gorm:"column:allow_headers" description:"允许的请求header 逗号间隔，为空或*不限制"`
+	ExposeHeaders    string `json:"expose_headers" gorm:"column:expose_headers" description:"允许浏览器读取的响应header 逗号间隔"`
+	AllowCredentials int    `json:"allow_credentials" gorm:"column:allow_credentials" description:"允许携带cookie 1=允许"`
+	MaxAge           int    `json:"max_age" gorm:"column:max_age" description:"预检结果缓存秒数"`
+}
+
+func (t *CorsPolicy) TableName() string {
+	return "gateway_service_cors"
+}
+
+func (t *CorsPolicy) Find(c *gin.Context, tx *gorm.DB, search *CorsPolicy) (*CorsPolicy, error) {
+	model := &CorsPolicy{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *CorsPolicy) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+//origin为scheme://host[:port]，通配来源如*.example.com、https://*.example.com只匹配子域名
+func (t *CorsPolicy) AllowOrigin(origin string) bool {
+	for _, item := range strings.Split(t.AllowOrigins, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "*":
+			return true
+		case strings.Contains(item, "*."):
+			if matchWildcardOrigin(item, origin) {
+				return true
+			}
+		case strings.EqualFold(item, origin):
+			return true
+		}
+	}
+	return false
+}
+
+func matchWildcardOrigin(pattern, origin string) bool {
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if pos := strings.Index(pattern, "://"); pos >= 0 {
+		if !strings.EqualFold(pattern[:pos], originURL.Scheme) {
+			return false
+		}
+		pattern = pattern[pos+3:]
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(originURL.Hostname()), strings.ToLower(pattern[1:]))
+}
+
+func (t *CorsPolicy) AllowMethod(method string) bool {
+	if t.AllowMethods == "" {
+		return true
+	}
+	for _, item := range strings.Split(t.AllowMethods, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), method) {
+			return true
+		}
+	}
+	return false
+}
+
+//requestHeaders为预检请求的Access-Control-Request-Headers
+func (t *CorsPolicy) AllowHeader(requestHeaders string) bool {
+	if t.AllowHeaders == "" || t.AllowHeaders == "*" {
+		return true
+	}
+	allowed := map[string]bool{}
+	for _, item := range strings.Split(t.AllowHeaders, ",") {
+		allowed[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+	for _, item := range strings.Split(requestHeaders, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" && !allowed[item] {
+			return false
+		}
+	}
+	return true
+}
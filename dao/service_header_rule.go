@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+)
+
+//请求及响应header规则，响应规则在ResponseTransform之后执行
+type HeaderRule struct {
+	ID          int64  `json:"id" gorm:"primary_key"`
+	ServiceID   int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Direction   int    `json:"direction" gorm:"column:direction" description:"作用方向 0=请求 1=响应"`
+	Op          string `json:"op" gorm:"column:op" description:"操作 add=增加 set=设置 del=删除 rename=改名"`
+	HeaderName  string `json:"header_name" gorm:"column:header_name" description:"header名"`
+	HeaderValue string `json:"header_value" gorm:"column:header_value" description:"header值，支持变量${client_ip}、${trace_id}、${app_id}等，rename时为新header名"`
+}
+
+func (t *HeaderRule) TableName() string {
+	return "gateway_service_header_rule"
+}
+
+func (t *HeaderRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+//按添加顺序返回服务的header规则
+func (t *HeaderRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]*HeaderRule, error) {
+	list := []*HeaderRule{}
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Where("service_id=?", serviceID)
+	if err := query.Order("id asc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (t *HeaderRule) DeleteByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) error {
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	return query.Where("service_id=?", serviceID).Delete(&HeaderRule{}).Error
+}
@@ -0,0 +1,271 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//http路由表：精确域名用map，通配域名按反转后缀、url前缀按路径分别存入基数树，正则路径单独按优先级保存
+//命中多条时优先级高者胜出，优先级相同时 精确域名 > 通配域名(后缀长者优先) > url前缀(前缀长者优先) > 正则路径
+type HTTPRouter struct {
+	hosts    map[string][]*httpRoute
+	wildcard *radixNode
+	prefix   *radixNode
+	regex    []*httpRoute
+}
+
+type httpRoute struct {
+	service  *ServiceDetail
+	rule     *HttpRule
+	priority int
+	methods  []string
+	headers  []*routeCond
+	queries  []*routeCond
+	path     *regexp.Regexp
+}
+
+//header及query匹配条件，value为空表示只要求存在
+type routeCond struct {
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+func (r *routeCond) match(values []string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	for _, value := range values {
+		if r.regex != nil && r.regex.MatchString(value) {
+			return true
+		}
+		if r.regex == nil && (r.value == "" || r.value == value) {
+			return true
+		}
+	}
+	return false
+}
+
+//格式: name value 或 name ~regex，多个逗号间隔
+func parseRouteConds(conf string) ([]*routeCond, error) {
+	conds := []*routeCond{}
+	if conf == "" {
+		return conds, nil
+	}
+	for _, item := range strings.Split(conf, ",") {
+		items := strings.SplitN(strings.TrimSpace(item), " ", 2)
+		cond := &routeCond{name: items[0]}
+		if len(items) == 2 {
+			cond.value = items[1]
+		}
+		if strings.HasPrefix(cond.value, "~") {
+			regex, err := regexp.Compile(cond.value[1:])
+			if err != nil {
+				return nil, errors.Wrap(err, item)
+			}
+			cond.regex = regex
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+func newHTTPRoute(service *ServiceDetail, rule *HttpRule) (*httpRoute, error) {
+	route := &httpRoute{service: service, rule: rule, priority: rule.Priority}
+	if rule.MatchMethod != "" {
+		for _, method := range strings.Split(rule.MatchMethod, ",") {
+			route.methods = append(route.methods, strings.ToUpper(strings.TrimSpace(method)))
+		}
+	}
+	var err error
+	if route.headers, err = parseRouteConds(rule.MatchHeader); err != nil {
+		return nil, err
+	}
+	if route.queries, err = parseRouteConds(rule.MatchQuery); err != nil {
+		return nil, err
+	}
+	if rule.RuleType == public.HTTPRuleTypeRegexURL {
+		if route.path, err = regexp.Compile(rule.Rule); err != nil {
+			return nil, err
+		}
+	}
+	return route, nil
+}
+
+//校验规则中的正则表达式等配置能否生成路由
+func (t *HttpRule) CheckRoute() error {
+	_, err := newHTTPRoute(nil, t)
+	return err
+}
+
+func (r *httpRoute) match(req *http.Request) bool {
+	if len(r.methods) > 0 && !public.InStringSlice(r.methods, req.Method) {
+		return false
+	}
+	for _, cond := range r.headers {
+		if !cond.match(req.Header.Values(cond.name)) {
+			return false
+		}
+	}
+	if len(r.queries) > 0 {
+		query := req.URL.Query()
+		for _, cond := range r.queries {
+			if !cond.match(query[cond.name]) {
+				return false
+			}
+		}
+	}
+	if r.path != nil && !r.path.MatchString(req.URL.Path) {
+		return false
+	}
+	return true
+}
+
+func NewHTTPRouter() *HTTPRouter {
+	return &HTTPRouter{
+		hosts:    map[string][]*httpRoute{},
+		wildcard: &radixNode{},
+		prefix:   &radixNode{},
+	}
+}
+
+func (r *HTTPRouter) Add(service *ServiceDetail, rule *HttpRule) error {
+	route, err := newHTTPRoute(service, rule)
+	if err != nil {
+		return err
+	}
+	switch rule.RuleType {
+	case public.HTTPRuleTypeDomain:
+		//域名不区分大小写
+		host := strings.ToLower(rule.Rule)
+		if strings.HasPrefix(host, "*.") {
+			r.wildcard.insert(reverseString(host[1:]), route)
+		} else {
+			r.hosts[host] = append(r.hosts[host], route)
+		}
+	case public.HTTPRuleTypePrefixURL:
+		r.prefix.insert(rule.Rule, route)
+	case public.HTTPRuleTypeRegexURL:
+		r.regex = append(r.regex, route)
+	}
+	return nil
+}
+
+//返回命中的服务及其接入规则
+func (r *HTTPRouter) Match(req *http.Request) (*ServiceDetail, *HttpRule) {
+	host := req.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+	//按优先顺序收集候选，稳定排序后保持同优先级下的匹配精确度顺序
+	candidates := []*httpRoute{}
+	candidates = append(candidates, r.hosts[host]...)
+	candidates = append(candidates, r.wildcard.walk(reverseString(host))...)
+	candidates = append(candidates, r.prefix.walk(req.URL.Path)...)
+	candidates = append(candidates, r.regex...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority > candidates[j].priority
+	})
+	for _, route := range candidates {
+		if route.match(req) {
+			return route.service, route.rule
+		}
+	}
+	return nil, nil
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+//按字节压缩的基数树
+type radixNode struct {
+	path     string
+	children []*radixNode
+	routes   []*httpRoute
+}
+
+func (n *radixNode) insert(key string, route *httpRoute) {
+	if key == "" {
+		n.routes = append(n.routes, route)
+		return
+	}
+	for {
+		//与子节点的公共前缀
+		var child *radixNode
+		common := 0
+		for _, item := range n.children {
+			common = commonPrefixLen(item.path, key)
+			if common > 0 {
+				child = item
+				break
+			}
+		}
+		if child == nil {
+			n.children = append(n.children, &radixNode{path: key, routes: []*httpRoute{route}})
+			return
+		}
+		if common < len(child.path) {
+			//拆分子节点
+			split := &radixNode{
+				path:     child.path[common:],
+				children: child.children,
+				routes:   child.routes,
+			}
+			child.path = child.path[:common]
+			child.children = []*radixNode{split}
+			child.routes = nil
+		}
+		key = key[common:]
+		if key == "" {
+			child.routes = append(child.routes, route)
+			return
+		}
+		n = child
+	}
+}
+
+//返回key的所有前缀上的路由，前缀长者在前
+func (n *radixNode) walk(key string) []*httpRoute {
+	matched := [][]*httpRoute{n.routes}
+	for {
+		var next *radixNode
+		for _, child := range n.children {
+			if strings.HasPrefix(key, child.path) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		if len(next.routes) > 0 {
+			matched = append(matched, next.routes)
+		}
+		key = key[len(next.path):]
+		n = next
+	}
+	routes := []*httpRoute{}
+	for i := len(matched) - 1; i >= 0; i-- {
+		routes = append(routes, matched[i]...)
+	}
+	return routes
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
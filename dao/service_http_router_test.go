@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRadixNode(t *testing.T) {
+	//插入顺序不同时拆分节点的结果应一致
+	orders := [][]string{
+		{"/", "/api", "/api/v2", "/apple", "/b"},
+		{"/api/v2", "/apple", "/api", "/b", "/"},
+	}
+	cases := []struct {
+		key    string
+		routes string
+	}{
+		{"/api/v2/users", "/api/v2,/api,/"},
+		{"/api/v1", "/api,/"},
+		{"/api", "/api,/"},
+		{"/apple/pie", "/apple,/"},
+		{"/ap", "/"},
+		{"/b", "/b,/"},
+		{"", ""},
+	}
+	for _, order := range orders {
+		root := &radixNode{}
+		for _, key := range order {
+			root.insert(key, &httpRoute{rule: &HttpRule{Rule: key}})
+		}
+		for _, c := range cases {
+			rules := []string{}
+			for _, route := range root.walk(c.key) {
+				rules = append(rules, route.rule.Rule)
+			}
+			if strings.Join(rules, ",") != c.routes {
+				t.Errorf("order %v key %q: expect %q, got %q", order, c.key, c.routes, strings.Join(rules, ","))
+			}
+		}
+	}
+}
+
+func TestHTTPRouterMatch(t *testing.T) {
+	rules := []*HttpRule{
+		{Rule: "/api", RuleType: public.HTTPRuleTypePrefixURL},
+		{Rule: "/api/v2", RuleType: public.HTTPRuleTypePrefixURL},
+		{Rule: "example.com", RuleType: public.HTTPRuleTypeDomain},
+		{Rule: "*.example.com", RuleType: public.HTTPRuleTypeDomain},
+		{Rule: "*.api.Example.com", RuleType: public.HTTPRuleTypeDomain},
+		{Rule: "/tie", RuleType: public.HTTPRuleTypePrefixURL, Priority: 5},
+		{Rule: "/tie/a", RuleType: public.HTTPRuleTypePrefixURL, Priority: 5},
+		{Rule: "^/tie/.*$", RuleType: public.HTTPRuleTypeRegexURL, Priority: 5},
+		{Rule: "/tie/a/b", RuleType: public.HTTPRuleTypePrefixURL, Priority: 1},
+		{Rule: "^/users/[0-9]+$", RuleType: public.HTTPRuleTypeRegexURL},
+		{Rule: "/cond", RuleType: public.HTTPRuleTypePrefixURL, Priority: 10, MatchMethod: "get, post"},
+		{Rule: "/cond", RuleType: public.HTTPRuleTypePrefixURL, Priority: 9, MatchHeader: "X-Version ~^v2"},
+		{Rule: "/cond", RuleType: public.HTTPRuleTypePrefixURL, Priority: 8, MatchQuery: "debug,env gray"},
+		{Rule: "/cond", RuleType: public.HTTPRuleTypePrefixURL, Priority: 7},
+	}
+	router := NewHTTPRouter()
+	for i, rule := range rules {
+		service := &ServiceDetail{Info: &ServiceInfo{ID: int64(i)}}
+		if err := router.Add(service, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		url    string
+		header map[string]string
+		expect int //命中的规则下标，-1为未命中
+	}{
+		{"longer prefix", "GET", "http://gateway/api/v2/users", nil, 1},
+		{"shorter prefix", "GET", "http://gateway/api/v1/users", nil, 0},
+		{"prefix not matched", "GET", "http://gateway/apix", nil, 0},
+		{"no route", "GET", "http://gateway/other", nil, -1},
+		{"exact domain", "GET", "http://example.com/other", nil, 2},
+		{"exact domain before prefix", "GET", "http://example.com/api", nil, 2},
+		{"exact domain with port", "GET", "http://example.com:8080/other", nil, 2},
+		{"domain case insensitive", "GET", "http://EXAMPLE.com/other", nil, 2},
+		{"wildcard domain", "GET", "http://www.example.com/other", nil, 3},
+		{"wildcard not match bare suffix", "GET", "http://wwwexample.com/other", nil, -1},
+		{"longer wildcard", "GET", "http://v1.api.example.com/other", nil, 4},
+		{"wildcard rule case insensitive", "GET", "http://V1.API.example.com/other", nil, 4},
+		{"wildcard base domain", "GET", "http://api.example.com/other", nil, 3},
+		{"equal priority longer prefix", "GET", "http://gateway/tie/a/b", nil, 6},
+		{"equal priority prefix before regex", "GET", "http://gateway/tie/c", nil, 5},
+		{"regex", "GET", "http://gateway/users/12", nil, 9},
+		{"regex not matched", "GET", "http://gateway/users/abc", nil, -1},
+		{"method matched", "POST", "http://gateway/cond", nil, 10},
+		{"header regex matched", "PUT", "http://gateway/cond", map[string]string{"X-Version": "v2.1"}, 11},
+		{"header regex not matched", "PUT", "http://gateway/cond", map[string]string{"X-Version": "v1"}, 13},
+		{"query matched", "PUT", "http://gateway/cond?debug=&env=gray", nil, 12},
+		{"query value not matched", "PUT", "http://gateway/cond?debug=1&env=prod", nil, 13},
+		{"query missing", "PUT", "http://gateway/cond?env=gray", nil, 13},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.url, nil)
+		for name, value := range c.header {
+			req.Header.Set(name, value)
+		}
+		service, rule := router.Match(req)
+		if c.expect < 0 {
+			if service != nil {
+				t.Errorf("%s: expect no route, got %v", c.name, rule.Rule)
+			}
+			continue
+		}
+		if service == nil || rule != rules[c.expect] {
+			got := "nil"
+			if rule != nil {
+				got = rule.Rule
+			}
+			t.Errorf("%s: expect %v, got %v", c.name, rules[c.expect].Rule, got)
+		}
+	}
+}
+
+func TestHTTPRouterAddInvalid(t *testing.T) {
+	rules := []*HttpRule{
+		{Rule: "^/users/([0-9]+$", RuleType: public.HTTPRuleTypeRegexURL},
+		{Rule: "/api", RuleType: public.HTTPRuleTypePrefixURL, MatchHeader: "X-Version ~v(2"},
+		{Rule: "/api", RuleType: public.HTTPRuleTypePrefixURL, MatchQuery: "env ~[gray"},
+	}
+	for _, rule := range rules {
+		if err := rule.CheckRoute(); err == nil {
+			t.Errorf("rule %+v: expect error", rule)
+		}
+	}
+}
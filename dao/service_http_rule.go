@@ -9,13 +9,43 @@ import (
 type HttpRule struct {
 	ID             int64  `json:"id" gorm:"primary_key"`
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
-	RuleType       int    `json:"rule_type" gorm:"column:rule_type" description:"匹配类型 domain=域名, url_prefix=url前缀"`
-	Rule           string `json:"rule" gorm:"column:rule" description:"type=domain表示域名，type=url_prefix时表示url前缀"`
+	RuleType       int    `json:"rule_type" gorm:"column:rule_type" description:"匹配类型 domain=域名, url_prefix=url前缀, url_regex=正则路径"`
+	Rule           string `json:"rule" gorm:"column:rule" description:"type=domain表示域名(支持*.开头的通配域名)，type=url_prefix时表示url前缀，type=url_regex时表示路径正则"`
 	NeedHttps      int    `json:"need_https" gorm:"column:need_https" description:"type=支持https 1=支持"`
 	NeedWebsocket  int    `json:"need_websocket" gorm:"column:need_websocket" description:"启用websocket 1=启用"`
 	NeedStripUri   int    `json:"need_strip_uri" gorm:"column:need_strip_uri" description:"启用strip_uri 1=启用"`
 	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写功能，每行一个	"`
 	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue	"`
+
+	Priority    int    `json:"priority" gorm:"column:priority" description:"匹配优先级，越大越优先"`
+	MatchMethod string `json:"match_method" gorm:"column:match_method" description:"匹配请求方法 如GET,POST, 为空不限制"`
+	MatchHeader string `json:"match_header" gorm:"column:match_header" description:"匹配header 格式: name value 或 name ~regex, 多个逗号间隔"`
+	MatchQuery  string `json:"match_query" gorm:"column:match_query" description:"匹配query参数 格式: name value 或 name ~regex, 多个逗号间隔"`
+
+	MirrorTarget  string `json:"mirror_target" gorm:"column:mirror_target" description:"流量镜像地址 格式: ip:port 或 http(s)://ip:port, 为空不镜像"`
+	MirrorPercent int    `json:"mirror_percent" gorm:"column:mirror_percent" description:"流量镜像比例, 百分比"`
+
+	CacheEnable int    `json:"cache_enable" gorm:"column:cache_enable" description:"启用响应缓存 1=启用"`
+	CacheStore  int    `json:"cache_store" gorm:"column:cache_store" description:"缓存存储 0=内存 1=redis"`
+	CacheTTL    int    `json:"cache_ttl" gorm:"column:cache_ttl" description:"上游未指定有效期时的缓存秒数, 0表示只缓存上游指定有效期的响应"`
+	CacheStale  int    `json:"cache_stale" gorm:"column:cache_stale" description:"过期后仍可返回旧内容并后台刷新的秒数"`
+	CacheKey    string `json:"cache_key" gorm:"column:cache_key" description:"缓存key组成 path,query,method,host,header:名称,cookie:名称 逗号间隔, 为空时为host,path,query,method"`
+
+	RespStatusMap   string `json:"resp_status_map" gorm:"column:resp_status_map" description:"响应状态码映射 格式: 原状态码 新状态码, 每行一条"`
+	RespJsonMask    string `json:"resp_json_mask" gorm:"column:resp_json_mask" description:"json响应脱敏字段 JSONPath格式如$.data.password, 每行一条"`
+	RespBodyReplace string `json:"resp_body_replace" gorm:"column:resp_body_replace" description:"响应体正则替换 格式: 正则 替换内容, 每行一条"`
+
+	CompressEnable    int    `json:"compress_enable" gorm:"column:compress_enable" description:"启用响应压缩 1=启用, 按Accept-Encoding使用br或gzip"`
+	CompressMinSize   int    `json:"compress_min_size" gorm:"column:compress_min_size" description:"压缩的最小响应大小, 单位byte, 0使用默认值"`
+	CompressTypes     string `json:"compress_types" gorm:"column:compress_types" description:"压缩的Content-Type 逗号间隔, 支持text/*, 为空使用默认值"`
+	DecompressRequest int    `json:"decompress_request" gorm:"column:decompress_request" description:"解压gzip或br编码的请求体后转发 1=启用"`
+
+	WebsocketIdleTimeout int `json:"websocket_idle_timeout" gorm:"column:websocket_idle_timeout" description:"websocket连接空闲超时, 单位s, 0使用默认值"`
+	WebsocketMaxLifetime int `json:"websocket_max_lifetime" gorm:"column:websocket_max_lifetime" description:"websocket连接最长存活时间, 单位s, 0使用默认值"`
+	WebsocketMaxConn     int `json:"websocket_max_conn" gorm:"column:websocket_max_conn" description:"单个代理进程的websocket连接数上限, 0不限制"`
+
+	StreamRoutes      string `json:"stream_routes" gorm:"column:stream_routes" description:"流式路由 格式: 路径前缀 或 路径前缀 写超时秒数(0不限制), 多个逗号间隔"`
+	StreamIdleTimeout int    `json:"stream_idle_timeout" gorm:"column:stream_idle_timeout" description:"流式响应空闲超时, 下游无数据(含心跳)超过该秒数断开, 0使用默认值"`
 }
 
 func (t *HttpRule) TableName() string {
@@ -51,3 +81,31 @@ func (t *HttpRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64)
 	}
 	return list, count, nil
 }
+
+func (t *HttpRule) ListRulesByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]*HttpRule, error) {
+	list := []*HttpRule{}
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Where("service_id=?", serviceID)
+	if err := query.Order("id asc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//删除服务除主规则外的接入规则
+func (t *HttpRule) DeleteAccessRules(c *gin.Context, tx *gorm.DB, serviceID, primaryID int64) error {
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	return query.Where("service_id=? and id<>?", serviceID, primaryID).Delete(&HttpRule{}).Error
+}
+
+//其他服务中是否存在接入路径及匹配条件完全相同的规则
+func (t *HttpRule) ExistsConflict(c *gin.Context, tx *gorm.DB) (bool, error) {
+	var count int64
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Where("rule_type=? and rule=? and match_method=? and match_header=? and match_query=? and service_id<>?",
+		t.RuleType, t.Rule, t.MatchMethod, t.MatchHeader, t.MatchQuery, t.ServiceID)
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -30,11 +30,17 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 		}
 		search = info
 	}
+	//一个服务可以有多条http接入规则，id最小的为主规则
 	httpRule := &HttpRule{ServiceID: search.ID}
-	httpRule, err := httpRule.Find(c, tx, httpRule)
-	if err != nil && err != gorm.ErrRecordNotFound {
+	httpRules, err := httpRule.ListRulesByServiceID(c, tx, search.ID)
+	if err != nil {
 		return nil, err
 	}
+	accessRules := []*HttpRule{}
+	if len(httpRules) > 0 {
+		httpRule = httpRules[0]
+		accessRules = httpRules[1:]
+	}
 	tcpRule := &TcpRule{ServiceID: search.ID}
 	tcpRule, err = tcpRule.Find(c, tx, tcpRule)
 	if err != nil && err != gorm.ErrRecordNotFound {
@@ -55,14 +61,45 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
+	upstreamGroup := &UpstreamGroup{}
+	upstreamGroups, err := upstreamGroup.ListByServiceID(c, tx, search.ID)
+	if err != nil {
+		return nil, err
+	}
+	headerRule := &HeaderRule{}
+	headerRules, err := headerRule.ListByServiceID(c, tx, search.ID)
+	if err != nil {
+		return nil, err
+	}
+	cors := &CorsPolicy{ServiceID: search.ID}
+	cors, err = cors.Find(c, tx, cors)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	upstreamTLS := &UpstreamTLS{ServiceID: search.ID}
+	upstreamTLS, err = upstreamTLS.Find(c, tx, upstreamTLS)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	clientAuth := &ClientAuth{ServiceID: search.ID}
+	clientAuth, err = clientAuth.Find(c, tx, clientAuth)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
 
 	detail := &ServiceDetail{
-		Info:          search,
-		HTTPRule:      httpRule,
-		TCPRule:       tcpRule,
-		GRPCRule:      grpcRule,
-		LoadBalance:   loadBalance,
-		AccessControl: accessControl,
+		Info:           search,
+		HTTPRule:       httpRule,
+		AccessRules:    accessRules,
+		TCPRule:        tcpRule,
+		GRPCRule:       grpcRule,
+		LoadBalance:    loadBalance,
+		AccessControl:  accessControl,
+		UpstreamGroups: upstreamGroups,
+		HeaderRules:    headerRules,
+		Cors:           cors,
+		UpstreamTLS:    upstreamTLS,
+		ClientAuth:     clientAuth,
 	}
 	return detail, nil
 }
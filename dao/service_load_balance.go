@@ -16,14 +16,45 @@ import (
 type LoadBalance struct {
 	ID            int64  `json:"id" gorm:"primary_key"`
 	ServiceID     int64  `json:"service_id" gorm:"column:service_id" description:"服务id	"`
-	CheckMethod   int    `json:"check_method" gorm:"column:check_method" description:"检查方法 tcpchk=检测端口是否握手成功	"`
+	CheckMethod   int    `json:"check_method" gorm:"column:check_method" description:"检查方法 0=tcpchk检测端口是否握手成功 1=http 2=grpc"`
 	CheckTimeout  int    `json:"check_timeout" gorm:"column:check_timeout" description:"check超时时间	"`
 	CheckInterval int    `json:"check_interval" gorm:"column:check_interval" description:"检查间隔, 单位s		"`
-	RoundType     int    `json:"round_type" gorm:"column:round_type" description:"轮询方式 round/weight_round/random/ip_hash"`
+	CheckRise     int    `json:"check_rise" gorm:"column:check_rise" description:"连续成功次数达到后恢复节点"`
+	CheckFall     int    `json:"check_fall" gorm:"column:check_fall" description:"连续失败次数达到后摘除节点"`
+	CheckPath     string `json:"check_path" gorm:"column:check_path" description:"http检查路径"`
+	CheckStatus   string `json:"check_status" gorm:"column:check_status" description:"http期望状态码范围 如200-399"`
+	CheckBody     string `json:"check_body" gorm:"column:check_body" description:"http响应体需包含的内容"`
+	RoundType     int    `json:"round_type" gorm:"column:round_type" description:"轮询方式 random/round/weight_round/ip_hash/least_conn/p2c/ewma"`
 	IpList        string `json:"ip_list" gorm:"column:ip_list" description:"ip列表"`
 	WeightList    string `json:"weight_list" gorm:"column:weight_list" description:"权重列表"`
 	ForbidList    string `json:"forbid_list" gorm:"column:forbid_list" description:"禁用ip列表"`
 
+	HashKeyType    int    `json:"hash_key_type" gorm:"column:hash_key_type" description:"一致性hash取值 0=url 1=client_ip 2=header 3=cookie 4=query 5=jwt_claim"`
+	HashKeyName    string `json:"hash_key_name" gorm:"column:hash_key_name" description:"一致性hash取值名称 如header名"`
+	HashReplicas   int    `json:"hash_replicas" gorm:"column:hash_replicas" description:"一致性hash虚拟节点数"`
+	HashLoadFactor int    `json:"hash_load_factor" gorm:"column:hash_load_factor" description:"一致性hash有界负载系数, 百分比 如125, 0=不限制"`
+	StickySession  int    `json:"sticky_session" gorm:"column:sticky_session" description:"是否开启cookie会话保持 1=开启"`
+	StickyCookie   string `json:"sticky_cookie" gorm:"column:sticky_cookie" description:"会话保持cookie名"`
+
+	RetryNum           int    `json:"retry_num" gorm:"column:retry_num" description:"换节点重试次数, 0=不重试"`
+	RetryOn            string `json:"retry_on" gorm:"column:retry_on" description:"重试条件 如connect_failure,timeout,502,503,504"`
+	RetryNonIdempotent int    `json:"retry_non_idempotent" gorm:"column:retry_non_idempotent" description:"是否重试非幂等请求 1=是"`
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" gorm:"column:breaker_error_percent" description:"熔断错误率阈值, 百分比, 0=不按错误率熔断"`
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" gorm:"column:breaker_slow_percent" description:"熔断慢调用比例阈值, 百分比, 0=不按慢调用熔断"`
+	BreakerSlowTime     int    `json:"breaker_slow_time" gorm:"column:breaker_slow_time" description:"慢调用耗时, 单位ms"`
+	BreakerMinRequests  int    `json:"breaker_min_requests" gorm:"column:breaker_min_requests" description:"熔断统计窗口内最少请求数"`
+	BreakerWindow       int    `json:"breaker_window" gorm:"column:breaker_window" description:"熔断统计窗口, 单位s"`
+	BreakerOpenTime     int    `json:"breaker_open_time" gorm:"column:breaker_open_time" description:"熔断时长, 单位s"`
+	BreakerNode         int    `json:"breaker_node" gorm:"column:breaker_node" description:"是否按节点熔断 1=是"`
+	BreakerFallbackCode int    `json:"breaker_fallback_code" gorm:"column:breaker_fallback_code" description:"熔断时返回的http状态码"`
+	BreakerFallbackBody string `json:"breaker_fallback_body" gorm:"column:breaker_fallback_body" description:"熔断时返回的内容"`
+
+	OutlierErrNum       int `json:"outlier_err_num" gorm:"column:outlier_err_num" description:"被动探活连续失败多少次摘除, 0=默认5"`
+	OutlierEjectTime    int `json:"outlier_eject_time" gorm:"column:outlier_eject_time" description:"摘除基础时长, 单位s, 0=默认30"`
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" gorm:"column:outlier_max_eject_time" description:"摘除最长时长, 单位s, 0=默认300"`
+	OutlierMaxPercent   int `json:"outlier_max_percent" gorm:"column:outlier_max_percent" description:"同时摘除节点数占比上限, 百分比, 0=默认50"`
+
 	UpstreamConnectTimeout int `json:"upstream_connect_timeout" gorm:"column:upstream_connect_timeout" description:"下游建立连接超时, 单位s"`
 	UpstreamHeaderTimeout  int `json:"upstream_header_timeout" gorm:"column:upstream_header_timeout" description:"下游获取header超时, 单位s	"`
 	UpstreamIdleTimeout    int `json:"upstream_idle_timeout" gorm:"column:upstream_idle_timeout" description:"下游链接最大空闲时间, 单位s	"`
@@ -55,6 +86,51 @@ func (t *LoadBalance) GetWeightListByModel() []string {
 	return strings.Split(t.WeightList, ",")
 }
 
+func (t *LoadBalance) GetCheckConfByModel() *load_balance.CheckConf {
+	return &load_balance.CheckConf{
+		Method:     t.CheckMethod,
+		Timeout:    time.Duration(t.CheckTimeout) * time.Second,
+		Interval:   time.Duration(t.CheckInterval) * time.Second,
+		Rise:       t.CheckRise,
+		Fall:       t.CheckFall,
+		HttpPath:   t.CheckPath,
+		HttpStatus: t.CheckStatus,
+		HttpBody:   t.CheckBody,
+	}
+}
+
+func (t *LoadBalance) GetHashConfByModel() *load_balance.HashConf {
+	return &load_balance.HashConf{
+		Replicas:   t.HashReplicas,
+		LoadFactor: float64(t.HashLoadFactor) / 100,
+	}
+}
+
+//未设置错误率及慢调用比例时不开启熔断
+func (t *LoadBalance) GetBreakerConfByModel() *load_balance.CircuitBreakerConf {
+	if t.BreakerErrorPercent == 0 && t.BreakerSlowPercent == 0 {
+		return nil
+	}
+	return &load_balance.CircuitBreakerConf{
+		ErrorPercent: t.BreakerErrorPercent,
+		SlowPercent:  t.BreakerSlowPercent,
+		SlowTime:     time.Duration(t.BreakerSlowTime) * time.Millisecond,
+		MinRequests:  t.BreakerMinRequests,
+		Window:       time.Duration(t.BreakerWindow) * time.Second,
+		OpenTime:     time.Duration(t.BreakerOpenTime) * time.Second,
+	}
+}
+
+//零值字段由load_balance使用默认值
+func (t *LoadBalance) GetOutlierConfByModel() *load_balance.OutlierConf {
+	return &load_balance.OutlierConf{
+		ErrNum:       t.OutlierErrNum,
+		EjectTime:    time.Duration(t.OutlierEjectTime) * time.Second,
+		MaxEjectTime: time.Duration(t.OutlierMaxEjectTime) * time.Second,
+		MaxPercent:   t.OutlierMaxPercent,
+	}
+}
+
 var LoadBalancerHandler *LoadBalancer
 
 type LoadBalancer struct {
@@ -65,7 +141,11 @@ type LoadBalancer struct {
 
 type LoadBalancerItem struct {
 	LoadBanlance load_balance.LoadBalance
+	LoadConf     load_balance.LoadBalanceConf
+	Feedback     load_balance.Feedback
+	Breaker      *load_balance.CircuitBreaker //服务级熔断，未开启时为空
 	ServiceName  string
+	GroupName    string //灰度分组，默认节点为空
 }
 
 func NewLoadBalancer() *LoadBalancer {
@@ -81,10 +161,57 @@ func init() {
 }
 
 func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.LoadBalance, error) {
-	for _, lbrItem := range lbr.LoadBanlanceSlice {
-		if lbrItem.ServiceName == service.Info.ServiceName {
-			return lbrItem.LoadBanlance, nil
-		}
+	lbItem, err := lbr.getLoadBalancerItem(service)
+	if err != nil {
+		return nil, err
+	}
+	return lbItem.LoadBanlance, nil
+}
+
+//代理请求结束时回报结果，被动探活及按连接数负载均衡使用
+func (lbr *LoadBalancer) GetFeedback(service *ServiceDetail) (load_balance.Feedback, error) {
+	lbItem, err := lbr.getLoadBalancerItem(service)
+	if err != nil {
+		return nil, err
+	}
+	return lbItem.Feedback, nil
+}
+
+//节点可用列表，会话保持判断固定节点是否可用
+func (lbr *LoadBalancer) GetLoadBalanceConf(service *ServiceDetail) (load_balance.LoadBalanceConf, error) {
+	lbItem, err := lbr.getLoadBalancerItem(service)
+	if err != nil {
+		return nil, err
+	}
+	return lbItem.LoadConf, nil
+}
+
+//服务级熔断器，未开启熔断时返回nil
+func (lbr *LoadBalancer) GetCircuitBreaker(service *ServiceDetail) (*load_balance.CircuitBreaker, error) {
+	lbItem, err := lbr.getLoadBalancerItem(service)
+	if err != nil {
+		return nil, err
+	}
+	return lbItem.Breaker, nil
+}
+
+func (lbr *LoadBalancer) getLoadBalancerItem(service *ServiceDetail) (*LoadBalancerItem, error) {
+	//灰度分组使用独立的负载均衡器
+	lbKey := service.Info.ServiceName
+	if service.GroupName() != "" {
+		lbKey = service.Info.ServiceName + "@" + service.GroupName()
+	}
+	lbr.Locker.RLock()
+	lbItem, ok := lbr.LoadBanlanceMap[lbKey]
+	lbr.Locker.RUnlock()
+	if ok {
+		return lbItem, nil
+	}
+
+	lbr.Locker.Lock()
+	defer lbr.Locker.Unlock()
+	if lbItem, ok := lbr.LoadBanlanceMap[lbKey]; ok {
+		return lbItem, nil
 	}
 	schema := "http://"
 	if service.HTTPRule.NeedHttps == 1 {
@@ -100,23 +227,77 @@ func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.L
 		ipConf[ipItem] = weightList[ipIndex]
 	}
 	//fmt.Println("ipConf", ipConf)
-	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
+	//https及grpc探活与代理使用相同的下游tls配置
+	tlsConf, err := UpstreamTLSHandler.GetTLSConfig(service)
+	if err != nil {
+		return nil, err
+	}
+	checkConf := service.LoadBalance.GetCheckConfByModel()
+	checkConf.TLSConfig = tlsConf
+	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf, checkConf)
 	if err != nil {
 		return nil, err
 	}
-	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(service.LoadBalance.RoundType), mConf)
+	mConf.SetOutlierConf(service.LoadBalance.GetOutlierConfByModel())
+	var breaker *load_balance.CircuitBreaker
+	if breakerConf := service.LoadBalance.GetBreakerConfByModel(); breakerConf != nil {
+		serviceName := service.Info.ServiceName
+		onStateChange := func(name string, state int) {
+			go public.SetCircuitBreakerState(serviceName, name, load_balance.BreakerStateMap[state])
+		}
+		breakerName := public.CircuitBreakerServiceField
+		if service.GroupName() != "" {
+			breakerName = public.CircuitBreakerGroupPrefix + service.GroupName()
+		} else {
+			go public.ResetCircuitBreakerState(serviceName)
+		}
+		if service.LoadBalance.BreakerNode == 1 {
+			mConf.SetNodeBreaker(breakerConf, onStateChange)
+		}
+		breaker = load_balance.NewCircuitBreaker(breakerName, breakerConf)
+		breaker.SetOnStateChange(onStateChange)
+	}
+	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(service.LoadBalance.RoundType), mConf, service.LoadBalance.GetHashConfByModel())
+	feedback := load_balance.FeedbackList{mConf}
+	if lbFeedback, ok := lb.(load_balance.Feedback); ok {
+		feedback = append(feedback, lbFeedback)
+	}
+	if breaker != nil {
+		feedback = append(feedback, breaker)
+	}
 
 	//save to map and slice
-	lbItem := &LoadBalancerItem{
+	lbItem = &LoadBalancerItem{
 		LoadBanlance: lb,
+		LoadConf:     mConf,
+		Feedback:     feedback,
+		Breaker:      breaker,
 		ServiceName:  service.Info.ServiceName,
+		GroupName:    service.GroupName(),
 	}
 	lbr.LoadBanlanceSlice = append(lbr.LoadBanlanceSlice, lbItem)
+	lbr.LoadBanlanceMap[lbKey] = lbItem
+	return lbItem, nil
+}
 
+//服务变更时删除负载均衡器(含灰度分组)，并停止其探活协程
+func (lbr *LoadBalancer) Remove(serviceName string) {
 	lbr.Locker.Lock()
 	defer lbr.Locker.Unlock()
-	lbr.LoadBanlanceMap[service.Info.ServiceName] = lbItem
-	return lb, nil
+	itemSlice := []*LoadBalancerItem{}
+	for _, item := range lbr.LoadBanlanceSlice {
+		if item.ServiceName != serviceName {
+			itemSlice = append(itemSlice, item)
+			continue
+		}
+		for key, mapItem := range lbr.LoadBanlanceMap {
+			if mapItem == item {
+				delete(lbr.LoadBanlanceMap, key)
+			}
+		}
+		item.LoadConf.CloseWatch()
+	}
+	lbr.LoadBanlanceSlice = itemSlice
 }
 
 var TransportorHandler *Transportor
@@ -145,47 +326,81 @@ func init() {
 }
 
 func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error) {
-	for _, transItem := range t.TransportSlice {
-		if transItem.ServiceName == service.Info.ServiceName {
-			return transItem.Trans, nil
-		}
+	t.Locker.RLock()
+	transItem, ok := t.TransportMap[service.Info.ServiceName]
+	t.Locker.RUnlock()
+	if ok {
+		return transItem.Trans, nil
+	}
+
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	if transItem, ok := t.TransportMap[service.Info.ServiceName]; ok {
+		return transItem.Trans, nil
 	}
 
 	//todo 优化点5
-	if service.LoadBalance.UpstreamConnectTimeout==0{
-		service.LoadBalance.UpstreamConnectTimeout = 30
+	//默认值不回写到service，避免reload时与数据库配置比对不一致
+	connectTimeout := service.LoadBalance.UpstreamConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = 30
+	}
+	maxIdle := service.LoadBalance.UpstreamMaxIdle
+	if maxIdle == 0 {
+		maxIdle = 100
 	}
-	if service.LoadBalance.UpstreamMaxIdle==0{
-		service.LoadBalance.UpstreamMaxIdle = 100
+	idleTimeout := service.LoadBalance.UpstreamIdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 90
 	}
-	if service.LoadBalance.UpstreamIdleTimeout==0{
-		service.LoadBalance.UpstreamIdleTimeout = 90
+	headerTimeout := service.LoadBalance.UpstreamHeaderTimeout
+	if headerTimeout == 0 {
+		headerTimeout = 30
 	}
-	if service.LoadBalance.UpstreamHeaderTimeout==0{
-		service.LoadBalance.UpstreamHeaderTimeout = 30
+	tlsConf, err := UpstreamTLSHandler.GetTLSConfig(service)
+	if err != nil {
+		return nil, err
 	}
 	trans := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout: time.Duration(service.LoadBalance.UpstreamConnectTimeout)*time.Second,
+			Timeout: time.Duration(connectTimeout)*time.Second,
 			KeepAlive: 30 * time.Second,
 			DualStack: true,
 		}).DialContext,
 		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          service.LoadBalance.UpstreamMaxIdle,
-		IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout)*time.Second,
+		MaxIdleConns:          maxIdle,
+		IdleConnTimeout:       time.Duration(idleTimeout)*time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout)*time.Second,
+		ResponseHeaderTimeout: time.Duration(headerTimeout)*time.Second,
+		TLSClientConfig:       tlsConf,
 	}
 
 	//save to map and slice
-	transItem := &TransportItem{
+	transItem = &TransportItem{
 		Trans:       trans,
 		ServiceName: service.Info.ServiceName,
 	}
 	t.TransportSlice = append(t.TransportSlice, transItem)
-	t.Locker.Lock()
-	defer t.Locker.Unlock()
 	t.TransportMap[service.Info.ServiceName] = transItem
 	return trans, nil
 }
+
+//服务变更时删除连接池，并关闭空闲连接
+func (t *Transportor) Remove(serviceName string) {
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	transItem, ok := t.TransportMap[serviceName]
+	if !ok {
+		return
+	}
+	delete(t.TransportMap, serviceName)
+	itemSlice := []*TransportItem{}
+	for _, item := range t.TransportSlice {
+		if item != transItem {
+			itemSlice = append(itemSlice, item)
+		}
+	}
+	t.TransportSlice = itemSlice
+	transItem.Trans.CloseIdleConnections()
+}
@@ -4,12 +4,22 @@ import (
 	"github.com/e421083458/go_gateway/public"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	TcpTLSModeNone        = 0
+	TcpTLSModeTerminate   = 1
+	TcpTLSModePassthrough = 2
 )
 
 type TcpRule struct {
-	ID        int64 `json:"id" gorm:"primary_key"`
-	ServiceID int64 `json:"service_id" gorm:"column:service_id" description:"服务id	"`
-	Port      int   `json:"port" gorm:"column:port" description:"端口	"`
+	ID        int64  `json:"id" gorm:"primary_key"`
+	ServiceID int64  `json:"service_id" gorm:"column:service_id" description:"服务id	"`
+	Port      int    `json:"port" gorm:"column:port" description:"端口	"`
+	TLSMode   int    `json:"tls_mode" gorm:"column:tls_mode" description:"tls接入 0=透传字节 1=tls卸载 2=按SNI透传"`
+	SNIHosts  string `json:"sni_hosts" gorm:"column:sni_hosts" description:"SNI域名 支持*.开头的通配, 多个逗号间隔, 为空时作为端口默认服务"`
 }
 
 func (t *TcpRule) TableName() string {
@@ -45,3 +55,57 @@ func (t *TcpRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64)
 	}
 	return list, count, nil
 }
+
+func (t *TcpRule) ListByPort(c *gin.Context, tx *gorm.DB, port int) ([]TcpRule, error) {
+	var list []TcpRule
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("port=?", port)
+	err := query.Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//SNI域名列表，统一小写
+func (t *TcpRule) GetSNIHosts() []string {
+	hosts := []string{}
+	for _, host := range strings.Split(t.SNIHosts, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+//同一端口只允许开启tls的服务共用，且SNI域名不能重复，未配置域名的默认服务只能有一个
+func (t *TcpRule) CheckPortShare(c *gin.Context, tx *gorm.DB) error {
+	list, err := t.ListByPort(c, tx, t.Port)
+	if err != nil {
+		return err
+	}
+	hostMap := map[string]bool{}
+	for _, host := range t.GetSNIHosts() {
+		hostMap[host] = true
+	}
+	for _, item := range list {
+		if item.ServiceID == t.ServiceID {
+			continue
+		}
+		if t.TLSMode == TcpTLSModeNone || item.TLSMode == TcpTLSModeNone {
+			return errors.New("服务端口被占用，请重新输入")
+		}
+		itemHosts := item.GetSNIHosts()
+		if len(itemHosts) == 0 && len(hostMap) == 0 {
+			return errors.New("端口已存在未配置SNI域名的服务")
+		}
+		for _, host := range itemHosts {
+			if hostMap[host] {
+				return errors.New("SNI域名已被该端口的其他服务使用: " + host)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+type UpstreamGroup struct {
+	ID            int64     `json:"id" gorm:"primary_key"`
+	ServiceID     int64     `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	GroupName     string    `json:"group_name" gorm:"column:group_name" description:"分组名称 如canary"`
+	RoundType     int       `json:"round_type" gorm:"column:round_type" description:"轮询方式 同load_balance"`
+	IpList        string    `json:"ip_list" gorm:"column:ip_list" description:"ip列表"`
+	WeightList    string    `json:"weight_list" gorm:"column:weight_list" description:"权重列表"`
+	MatchType     int       `json:"match_type" gorm:"column:match_type" description:"匹配方式 0=按比例 1=header 2=cookie 3=客户端ip段 4=jwt app_id"`
+	MatchName     string    `json:"match_name" gorm:"column:match_name" description:"header或cookie名"`
+	MatchValue    string    `json:"match_value" gorm:"column:match_value" description:"匹配值，逗号间隔，客户端ip段支持cidr"`
+	TrafficWeight int       `json:"traffic_weight" gorm:"column:traffic_weight" description:"按比例分流时的流量百分比"`
+	UpdatedAt     time.Time `json:"create_at" gorm:"column:create_at" description:"更新时间"`
+	CreatedAt     time.Time `json:"update_at" gorm:"column:update_at" description:"添加时间"`
+	IsDelete      int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+func (t *UpstreamGroup) TableName() string {
+	return "gateway_service_upstream_group"
+}
+
+func (t *UpstreamGroup) Find(c *gin.Context, tx *gorm.DB, search *UpstreamGroup) (*UpstreamGroup, error) {
+	model := &UpstreamGroup{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *UpstreamGroup) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *UpstreamGroup) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64) ([]*UpstreamGroup, error) {
+	list := []*UpstreamGroup{}
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Where("service_id=? and is_delete=0", serviceID)
+	if err := query.Order("id asc").Find(&list).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//匹配上游分组所需的请求信息
+type UpstreamMatchContext struct {
+	Header   func(name string) string
+	Cookie   func(name string) string
+	ClientIP string
+	AppID    string
+}
+
+func (t *UpstreamGroup) match(m *UpstreamMatchContext) bool {
+	value := ""
+	switch t.MatchType {
+	case public.UpstreamMatchHeader:
+		if m.Header != nil {
+			value = m.Header(t.MatchName)
+		}
+	case public.UpstreamMatchCookie:
+		if m.Cookie != nil {
+			value = m.Cookie(t.MatchName)
+		}
+	case public.UpstreamMatchAppID:
+		value = m.AppID
+	case public.UpstreamMatchClientIP:
+		return matchIPRange(m.ClientIP, t.MatchValue)
+	default:
+		return false
+	}
+	if value == "" {
+		return false
+	}
+	return public.InStringSlice(strings.Split(t.MatchValue, ","), value)
+}
+
+//rangeList为逗号间隔的ip或cidr
+func matchIPRange(clientIP, rangeList string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, item := range strings.Split(rangeList, ",") {
+		item = strings.TrimSpace(item)
+		if _, ipNet, err := net.ParseCIDR(item); err == nil {
+			if ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if item == clientIP {
+			return true
+		}
+	}
+	return false
+}
+
+//按分组规则选择上游：先匹配header/cookie/ip段/app_id规则，再按比例分流，均未命中时使用服务默认节点
+func (s *ServiceDetail) SelectUpstream(m *UpstreamMatchContext) *ServiceDetail {
+	if len(s.UpstreamGroups) == 0 {
+		return s
+	}
+	for _, group := range s.UpstreamGroups {
+		if group.MatchType != public.UpstreamMatchWeight && group.match(m) {
+			return s.ForGroup(group)
+		}
+	}
+	percent := rand.Intn(100)
+	for _, group := range s.UpstreamGroups {
+		if group.MatchType != public.UpstreamMatchWeight {
+			continue
+		}
+		if percent < group.TrafficWeight {
+			return s.ForGroup(group)
+		}
+		percent -= group.TrafficWeight
+	}
+	return s
+}
+
+//返回使用分组节点的服务副本，reload时已生成的直接复用
+func (s *ServiceDetail) ForGroup(group *UpstreamGroup) *ServiceDetail {
+	if detail, ok := s.groupDetails[group.GroupName]; ok {
+		return detail
+	}
+	return s.newGroupDetail(group)
+}
+
+//reload时为每个分组生成服务副本，代理请求中不再逐次分配
+func (s *ServiceDetail) initGroupDetails() {
+	groupDetails := map[string]*ServiceDetail{}
+	for _, group := range s.UpstreamGroups {
+		groupDetails[group.GroupName] = s.newGroupDetail(group)
+	}
+	s.groupDetails = groupDetails
+}
+
+//分组副本使用分组节点，其余负载均衡配置沿用服务配置
+func (s *ServiceDetail) newGroupDetail(group *UpstreamGroup) *ServiceDetail {
+	loadBalance := *s.LoadBalance
+	loadBalance.RoundType = group.RoundType
+	loadBalance.IpList = group.IpList
+	loadBalance.WeightList = group.WeightList
+	detail := *s
+	detail.LoadBalance = &loadBalance
+	detail.groupName = group.GroupName
+	detail.groupDetails = nil
+	return &detail
+}
+
+//所选上游分组，默认节点为空
+func (s *ServiceDetail) GroupName() string {
+	return s.groupName
+}
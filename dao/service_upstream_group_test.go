@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"testing"
+)
+
+func TestServiceDetailForGroup(t *testing.T) {
+	detail := &ServiceDetail{
+		Info:        &ServiceInfo{ServiceName: "group_test"},
+		LoadBalance: &LoadBalance{RoundType: 0, IpList: "127.0.0.1:2001", WeightList: "50"},
+		UpstreamGroups: []*UpstreamGroup{
+			{GroupName: "canary", RoundType: 1, IpList: "127.0.0.1:2002", WeightList: "50",
+				MatchType: public.UpstreamMatchHeader, MatchName: "X-Canary", MatchValue: "1"},
+		},
+	}
+	detail.initGroupDetails()
+	match := &UpstreamMatchContext{
+		Header: func(name string) string {
+			if name == "X-Canary" {
+				return "1"
+			}
+			return ""
+		},
+	}
+	//命中分组时复用reload时生成的副本
+	groupDetail := detail.SelectUpstream(match)
+	if groupDetail.GroupName() != "canary" || groupDetail != detail.SelectUpstream(match) {
+		t.Fatal("expect precomputed group detail")
+	}
+	if groupDetail.LoadBalance.IpList != "127.0.0.1:2002" || groupDetail.LoadBalance.RoundType != 1 ||
+		detail.LoadBalance.IpList != "127.0.0.1:2001" {
+		t.Fatalf("unexpected load balance %+v %+v", groupDetail.LoadBalance, detail.LoadBalance)
+	}
+	if groupDetail.groupDetails != nil {
+		t.Fatal("expect group detail without nested groups")
+	}
+	nomatch := &UpstreamMatchContext{Header: func(name string) string { return "" }}
+	if detail.SelectUpstream(nomatch) != detail {
+		t.Fatal("expect service detail without match")
+	}
+}
@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+type UpstreamTLS struct {
+	ID                 int64  `json:"id" gorm:"primary_key"`
+	ServiceID          int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Enable             int    `json:"enable" gorm:"column:enable" description:"启用下游tls配置 1=启用"`
+	CaCert             string `json:"ca_cert" gorm:"column:ca_cert" description:"信任的CA证书 PEM格式, 为空使用系统CA"`
+	ClientCert         string `json:"client_cert" gorm:"column:client_cert" description:"客户端证书 PEM格式, 下游要求双向认证时配置"`
+	ClientKey          string `json:"-" gorm:"column:client_key" description:"客户端私钥 PEM格式"`
+	ClientKeyHash      string `json:"client_key_hash" gorm:"-" description:"客户端私钥sha256摘要, 接口不返回私钥, reload按摘要比对私钥变更"`
+	ServerName         string `json:"server_name" gorm:"column:server_name" description:"SNI及证书校验使用的域名, 为空使用下游地址"`
+	MinVersion         string `json:"min_version" gorm:"column:min_version" description:"最低tls版本 1.0/1.1/1.2/1.3, 为空为1.2"`
+	InsecureSkipVerify int    `json:"insecure_skip_verify" gorm:"column:insecure_skip_verify" description:"跳过证书校验 1=跳过, 仅用于开发环境"`
+}
+
+func (t *UpstreamTLS) TableName() string {
+	return "gateway_service_upstream_tls"
+}
+
+func (t *UpstreamTLS) Find(c *gin.Context, tx *gorm.DB, search *UpstreamTLS) (*UpstreamTLS, error) {
+	model := &UpstreamTLS{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	if model.ClientKey != "" {
+		model.ClientKeyHash = fmt.Sprintf("%x", sha256.Sum256([]byte(model.ClientKey)))
+	}
+	return model, err
+}
+
+func (t *UpstreamTLS) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+var tlsVersionMap = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+//未启用时返回nil，使用默认tls配置
+func (t *UpstreamTLS) TLSConfig() (*tls.Config, error) {
+	if t == nil || t.Enable != 1 {
+		return nil, nil
+	}
+	minVersion, ok := tlsVersionMap[t.MinVersion]
+	if !ok {
+		return nil, errors.New("不支持的tls版本: " + t.MinVersion)
+	}
+	conf := &tls.Config{
+		ServerName:         t.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: t.InsecureSkipVerify == 1,
+	}
+	if t.CaCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.CaCert)) {
+			return nil, errors.New("CA证书格式错误")
+		}
+		conf.RootCAs = pool
+	}
+	if t.ClientCert != "" || t.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(t.ClientCert), []byte(t.ClientKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "客户端证书错误")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+var UpstreamTLSHandler *UpstreamTLSManager
+
+type UpstreamTLSManager struct {
+	TLSConfigMap map[string]*tls.Config
+	Locker       sync.RWMutex
+}
+
+func NewUpstreamTLSManager() *UpstreamTLSManager {
+	return &UpstreamTLSManager{
+		TLSConfigMap: map[string]*tls.Config{},
+		Locker:       sync.RWMutex{},
+	}
+}
+
+func init() {
+	UpstreamTLSHandler = NewUpstreamTLSManager()
+}
+
+//按服务缓存解析后的tls配置，http、tcp及grpc代理共用，未启用时返回nil
+func (m *UpstreamTLSManager) GetTLSConfig(service *ServiceDetail) (*tls.Config, error) {
+	m.Locker.RLock()
+	conf, ok := m.TLSConfigMap[service.Info.ServiceName]
+	m.Locker.RUnlock()
+	if ok {
+		return conf, nil
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if conf, ok := m.TLSConfigMap[service.Info.ServiceName]; ok {
+		return conf, nil
+	}
+	conf, err := service.UpstreamTLS.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	m.TLSConfigMap[service.Info.ServiceName] = conf
+	return conf, nil
+}
+
+func (m *UpstreamTLSManager) Remove(serviceName string) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	delete(m.TLSConfigMap, serviceName)
+}
@@ -50,12 +50,14 @@ type StatisticsOutput struct {
 }
 
 type APPAddHttpInput struct {
-	AppID    string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
-	Name     string `json:"name" form:"name" comment:"租户名称" validate:"required"`
-	Secret   string `json:"secret" form:"secret" comment:"密钥" validate:""`
-	WhiteIPS string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
-	Qpd      int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
-	Qps      int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+	AppID        string `json:"app_id" form:"app_id" comment:"租户id" validate:"required"`
+	Name         string `json:"name" form:"name" comment:"租户名称" validate:"required"`
+	Secret       string `json:"secret" form:"secret" comment:"密钥" validate:""`
+	WhiteIPS     string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
+	Qpd          int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
+	Qps          int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+	CertSubjects string `json:"cert_subjects" form:"cert_subjects" comment:"客户端证书标识" validate:""`
+	CertServices string `json:"cert_services" form:"cert_services" comment:"证书标识生效的服务名" validate:""`
 }
 
 func (params *APPAddHttpInput) GetValidParams(c *gin.Context) error {
@@ -63,13 +65,15 @@ func (params *APPAddHttpInput) GetValidParams(c *gin.Context) error {
 }
 
 type APPUpdateHttpInput struct {
-	ID       int64  `json:"id" form:"id" gorm:"column:id" comment:"主键ID" validate:"required"`
-	AppID    string `json:"app_id" form:"app_id" gorm:"column:app_id" comment:"租户id" validate:""`
-	Name     string `json:"name" form:"name" gorm:"column:name" comment:"租户名称" validate:"required"`
-	Secret   string `json:"secret" form:"secret" gorm:"column:secret" comment:"密钥" validate:"required"`
-	WhiteIPS string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配		"`
-	Qpd      int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
-	Qps      int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+	ID           int64  `json:"id" form:"id" gorm:"column:id" comment:"主键ID" validate:"required"`
+	AppID        string `json:"app_id" form:"app_id" gorm:"column:app_id" comment:"租户id" validate:""`
+	Name         string `json:"name" form:"name" gorm:"column:name" comment:"租户名称" validate:"required"`
+	Secret       string `json:"secret" form:"secret" gorm:"column:secret" comment:"密钥" validate:"required"`
+	WhiteIPS     string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配		"`
+	Qpd          int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
+	Qps          int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+	CertSubjects string `json:"cert_subjects" form:"cert_subjects" gorm:"column:cert_subjects" comment:"客户端证书标识"`
+	CertServices string `json:"cert_services" form:"cert_services" gorm:"column:cert_services" comment:"证书标识生效的服务名"`
 }
 
 func (params *APPUpdateHttpInput) GetValidParams(c *gin.Context) error {
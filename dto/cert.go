@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type CertListInput struct {
+	Info     string `json:"info" form:"info" comment:"查找信息" validate:""`
+	PageSize int    `json:"page_size" form:"page_size" comment:"页数" validate:"required,min=1,max=999"`
+	PageNo   int    `json:"page_no" form:"page_no" comment:"页码" validate:"required,min=1,max=999"`
+}
+
+func (params *CertListInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertListOutput struct {
+	List  []CertListItemOutput `json:"list" form:"list" comment:"证书列表"`
+	Total int64                `json:"total" form:"total" comment:"证书总数"`
+}
+
+type CertListItemOutput struct {
+	ID           int64     `json:"id" form:"id"`
+	Name         string    `json:"name" form:"name"`
+	Domains      string    `json:"domains" form:"domains"`
+	Issuer       string    `json:"issuer" form:"issuer"`
+	IsDefault    int       `json:"is_default" form:"is_default"`
+	NotBefore    time.Time `json:"not_before" form:"not_before"`
+	NotAfter     time.Time `json:"not_after" form:"not_after"`
+	ExpireDays   int       `json:"expire_days" form:"expire_days" comment:"剩余有效天数, 已过期为负数"`
+	ExpireStatus int       `json:"expire_status" form:"expire_status" comment:"0=正常 1=即将过期 2=已过期"`
+	UpdatedAt    time.Time `json:"update_at" form:"update_at"`
+}
+
+type CertDetailInput struct {
+	ID int64 `json:"id" form:"id" comment:"证书ID" validate:"required"`
+}
+
+func (params *CertDetailInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertAddInput struct {
+	Name      string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	CertPem   string `json:"cert_pem" form:"cert_pem" comment:"证书" validate:"required"`
+	KeyPem    string `json:"key_pem" form:"key_pem" comment:"私钥" validate:"required"`
+	IsDefault int    `json:"is_default" form:"is_default" comment:"默认证书" validate:"max=1,min=0"`
+}
+
+func (params *CertAddInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertUpdateInput struct {
+	ID        int64  `json:"id" form:"id" comment:"证书ID" validate:"required"`
+	Name      string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	CertPem   string `json:"cert_pem" form:"cert_pem" comment:"证书" validate:""`
+	KeyPem    string `json:"key_pem" form:"key_pem" comment:"私钥" validate:""`
+	IsDefault int    `json:"is_default" form:"is_default" comment:"默认证书" validate:"max=1,min=0"`
+}
+
+func (params *CertUpdateInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
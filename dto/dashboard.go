@@ -5,6 +5,8 @@ type PanelGroupDataOutput struct {
 	AppNum          int64 `json:"appNum"`
 	CurrentQPS      int64 `json:"currentQps"`
 	TodayRequestNum int64 `json:"todayRequestNum"`
+	CertNum         int64 `json:"certNum"`
+	CertExpiringNum int64 `json:"certExpiringNum"`
 }
 
 type DashServiceStatItemOutput struct {
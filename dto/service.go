@@ -14,7 +14,7 @@ type ServiceUpdateHTTPInput struct {
 	ServiceName string `json:"service_name" form:"service_name" comment:"服务名" example:"test_http_service_indb" validate:"required,valid_service_name"` //服务名
 	ServiceDesc string `json:"service_desc" form:"service_desc" comment:"服务描述" example:"test_http_service_indb" validate:"required,max=255,min=1"`     //服务描述
 
-	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=1,min=0"`                             //接入类型
+	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=2,min=0"`                             //接入类型
 	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名或者前缀" example:"/test_http_service_indb" validate:"required,valid_rule"` //域名或者前缀
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https" example:"" validate:"max=1,min=0"`                        //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri" example:"" validate:"max=1,min=0"`            //启用strip_uri
@@ -22,30 +22,124 @@ type ServiceUpdateHTTPInput struct {
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`                //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"`   //header转换
 
+	Priority    int    `json:"priority" form:"priority" comment:"匹配优先级" example:"0" validate:"min=0"`                 //匹配优先级，越大越优先
+	MatchMethod string `json:"match_method" form:"match_method" comment:"匹配请求方法" example:"GET,POST" validate:""`      //匹配请求方法
+	MatchHeader string `json:"match_header" form:"match_header" comment:"匹配header" example:"x-canary 1" validate:""`  //匹配header 格式: name value 或 name ~regex
+	MatchQuery  string `json:"match_query" form:"match_query" comment:"匹配query参数" example:"version ~^v2" validate:""` //匹配query参数 格式: name value 或 name ~regex
+
+	MirrorTarget  string `json:"mirror_target" form:"mirror_target" comment:"流量镜像地址" example:"127.0.0.1:8081" validate:"valid_mirror_target"` //流量镜像地址
+	MirrorPercent int    `json:"mirror_percent" form:"mirror_percent" comment:"流量镜像比例" example:"10" validate:"max=100,min=0"`                 //流量镜像比例, 百分比
+
+	CacheEnable int    `json:"cache_enable" form:"cache_enable" comment:"启用响应缓存" example:"0" validate:"max=1,min=0"`                 //启用响应缓存
+	CacheStore  int    `json:"cache_store" form:"cache_store" comment:"缓存存储" example:"0" validate:"max=1,min=0"`                     //缓存存储 0=内存 1=redis
+	CacheTTL    int    `json:"cache_ttl" form:"cache_ttl" comment:"默认缓存秒数" example:"60" validate:"min=0"`                            //上游未指定有效期时的缓存秒数
+	CacheStale  int    `json:"cache_stale" form:"cache_stale" comment:"过期后可用秒数" example:"30" validate:"min=0"`                       //过期后仍可返回旧内容并后台刷新的秒数
+	CacheKey    string `json:"cache_key" form:"cache_key" comment:"缓存key组成" example:"path,query,header:Accept-Language" validate:""` //缓存key组成，逗号间隔
+
+	RespStatusMap   string `json:"resp_status_map" form:"resp_status_map" comment:"响应状态码映射" example:"502 503" validate:""`          //响应状态码映射，每行一条
+	RespJsonMask    string `json:"resp_json_mask" form:"resp_json_mask" comment:"json响应脱敏字段" example:"$.data.password" validate:""` //json响应脱敏字段，每行一条
+	RespBodyReplace string `json:"resp_body_replace" form:"resp_body_replace" comment:"响应体正则替换" example:"" validate:""`             //响应体正则替换，每行一条
+
+	CompressEnable    int    `json:"compress_enable" form:"compress_enable" comment:"启用响应压缩" example:"0" validate:"max=1,min=0"`                   //启用响应压缩
+	CompressMinSize   int    `json:"compress_min_size" form:"compress_min_size" comment:"压缩最小响应大小" example:"1024" validate:"min=0"`                //压缩的最小响应大小
+	CompressTypes     string `json:"compress_types" form:"compress_types" comment:"压缩的Content-Type" example:"application/json,text/*" validate:""` //压缩的Content-Type，逗号间隔
+	DecompressRequest int    `json:"decompress_request" form:"decompress_request" comment:"解压请求体" example:"0" validate:"max=1,min=0"`              //解压gzip或br编码的请求体后转发
+
+	WebsocketIdleTimeout int `json:"websocket_idle_timeout" form:"websocket_idle_timeout" comment:"websocket空闲超时" example:"60" validate:"min=0"`  //websocket连接空闲超时，单位s
+	WebsocketMaxLifetime int `json:"websocket_max_lifetime" form:"websocket_max_lifetime" comment:"websocket最长存活时间" example:"0" validate:"min=0"` //websocket连接最长存活时间，单位s
+	WebsocketMaxConn     int `json:"websocket_max_conn" form:"websocket_max_conn" comment:"websocket连接数上限" example:"0" validate:"min=0"`          //单个代理进程的websocket连接数上限
+
+	StreamRoutes      string `json:"stream_routes" form:"stream_routes" comment:"流式路由" example:"/events 3600" validate:"valid_stream_routes"` //流式路由，格式: 路径前缀 写超时秒数，逗号间隔
+	StreamIdleTimeout int    `json:"stream_idle_timeout" form:"stream_idle_timeout" comment:"流式响应空闲超时" example:"60" validate:"min=0"`         //流式响应空闲超时，单位s
+
+	AccessRules []HTTPAccessRuleInput `json:"access_rules" form:"access_rules" comment:"附加接入规则" validate:"dive"` //附加接入规则，同一服务可通过多个域名或前缀接入
+
+	HeaderRules []HTTPHeaderRuleInput `json:"header_rules" form:"header_rules" comment:"header规则" validate:"dive"` //请求及响应header规则，按顺序执行
+
+	CorsEnable           int    `json:"cors_enable" form:"cors_enable" comment:"启用跨域" example:"0" validate:"max=1,min=0"`                                   //启用跨域
+	CorsAllowOrigins     string `json:"cors_allow_origins" form:"cors_allow_origins" comment:"跨域允许来源" example:"https://*.example.com" validate:""`          //跨域允许来源，逗号间隔
+	CorsAllowMethods     string `json:"cors_allow_methods" form:"cors_allow_methods" comment:"跨域允许方法" example:"GET,POST" validate:""`                       //跨域允许方法，为空不限制
+	CorsAllowHeaders     string `json:"cors_allow_headers" form:"cors_allow_headers" comment:"跨域允许header" example:"Content-Type,Authorization" validate:""` //跨域允许header，为空不限制
+	CorsExposeHeaders    string `json:"cors_expose_headers" form:"cors_expose_headers" comment:"跨域暴露header" example:"" validate:""`                         //允许浏览器读取的响应header
+	CorsAllowCredentials int    `json:"cors_allow_credentials" form:"cors_allow_credentials" comment:"跨域允许cookie" example:"0" validate:"max=1,min=0"`       //跨域允许携带cookie
+	CorsMaxAge           int    `json:"cors_max_age" form:"cors_max_age" comment:"预检缓存秒数" example:"600" validate:"min=0"`                                   //预检结果缓存秒数
+
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                     //关键词
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                               //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                               //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`          //服务端限流
 
-	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
+	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=6,min=0"`                                //轮询方式
+	HashKeyType            int    `json:"hash_key_type" form:"hash_key_type" comment:"一致性hash取值方式" example:"" validate:"max=5,min=0"`                  //一致性hash取值方式 0=url 1=client_ip 2=header 3=cookie 4=query 5=jwt_claim
+	HashKeyName            string `json:"hash_key_name" form:"hash_key_name" comment:"一致性hash取值名称" example:"" validate:""`                              //一致性hash取值名称
+	HashReplicas           int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" example:"" validate:"min=0"`                        //一致性hash虚拟节点数
+	HashLoadFactor         int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数" example:"125" validate:"omitempty,min=100"` //一致性hash有界负载系数, 百分比
+	StickySession          int    `json:"sticky_session" form:"sticky_session" comment:"cookie会话保持" example:"" validate:"max=1,min=0"`                //cookie会话保持
+	StickyCookie           string `json:"sticky_cookie" form:"sticky_cookie" comment:"会话保持cookie名" example:"gateway_sticky" validate:""`             //会话保持cookie名
+	RetryNum               int    `json:"retry_num" form:"retry_num" comment:"重试次数" example:"" validate:"min=0,max=5"`                               //重试次数
+	RetryOn                string `json:"retry_on" form:"retry_on" comment:"重试条件" example:"connect_failure,502" validate:"valid_retry_on"`           //重试条件
+	RetryNonIdempotent     int    `json:"retry_non_idempotent" form:"retry_non_idempotent" comment:"重试非幂等请求" example:"" validate:"max=1,min=0"` //重试非幂等请求
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值" example:"50" validate:"max=100,min=0"`              //熔断错误率阈值, 百分比
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值" example:"" validate:"max=100,min=0"`                //熔断慢调用比例阈值, 百分比
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" example:"1000" validate:"min=0"`                        //慢调用耗时, 单位ms
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" example:"20" validate:"min=0"`                      //熔断窗口最少请求数
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" example:"10" validate:"min=0"`                                //熔断统计窗口, 单位s
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" example:"30" validate:"min=0"`                            //熔断时长, 单位s
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" example:"" validate:"max=1,min=0"`                                      //按节点熔断
+	BreakerFallbackCode int    `json:"breaker_fallback_code" form:"breaker_fallback_code" comment:"熔断返回状态码" example:"503" validate:"omitempty,min=200,max=599"` //熔断返回状态码
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" example:"" validate:""`                              //熔断返回内容
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" example:"5" validate:"min=0"`                      //被动探活连续失败摘除次数
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" example:"30" validate:"min=0"`                //摘除基础时长, 单位s
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" example:"300" validate:"min=0"`       //摘除最长时长, 单位s
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" example:"50" validate:"max=100,min=0"` //同时摘除节点数占比上限, 百分比
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"`                //ip列表
 	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`             //权重列表
 	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`   //建立连接超时, 单位s
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+
+	CheckMethod   int    `json:"check_method" form:"check_method" comment:"探活方式" example:"" validate:"max=2,min=0"`            //探活方式 0=tcp 1=http 2=grpc
+	CheckTimeout  int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" example:"" validate:"min=0"`         //探活超时, 单位s
+	CheckInterval int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" example:"" validate:"min=0"`       //探活间隔, 单位s
+	CheckRise     int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" example:"" validate:"min=0"`               //连续成功恢复次数
+	CheckFall     int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" example:"" validate:"min=0"`               //连续失败摘除次数
+	CheckPath     string `json:"check_path" form:"check_path" comment:"http探活路径" example:"/ping" validate:""`               //http探活路径
+	CheckStatus   string `json:"check_status" form:"check_status" comment:"http探活状态码范围" example:"200-399" validate:"valid_check_status"` //http探活状态码范围
+	CheckBody     string `json:"check_body" form:"check_body" comment:"http探活响应体匹配" example:"" validate:""`                //http探活响应体匹配
 }
 
 func (param *ServiceAddHTTPInput) BindValidParam(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, param)
 }
 
+//服务主规则之外的http接入规则
+type HTTPAccessRuleInput struct {
+	RuleType     int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=2,min=0"`                  //接入类型
+	Rule         string `json:"rule" form:"rule" comment:"接入路径：域名或者前缀" example:"/legacy-api" validate:"required,valid_rule"`  //域名或者前缀
+	NeedStripUri int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri" example:"" validate:"max=1,min=0"` //启用strip_uri
+	UrlRewrite   string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`     //url重写功能
+	Priority     int    `json:"priority" form:"priority" comment:"匹配优先级" example:"0" validate:"min=0"`                        //匹配优先级，越大越优先
+	MatchMethod  string `json:"match_method" form:"match_method" comment:"匹配请求方法" example:"" validate:""`                     //匹配请求方法
+	MatchHeader  string `json:"match_header" form:"match_header" comment:"匹配header" example:"" validate:""`                   //匹配header 格式: name value 或 name ~regex
+	MatchQuery   string `json:"match_query" form:"match_query" comment:"匹配query参数" example:"" validate:""`                    //匹配query参数 格式: name value 或 name ~regex
+}
+
+type HTTPHeaderRuleInput struct {
+	Direction   int    `json:"direction" form:"direction" comment:"作用方向" example:"0" validate:"max=1,min=0"`               //作用方向 0=请求 1=响应
+	Op          string `json:"op" form:"op" comment:"操作" example:"set" validate:"required,oneof=add set del rename"`       //操作 add set del rename
+	HeaderName  string `json:"header_name" form:"header_name" comment:"header名" example:"X-Client-IP" validate:"required"` //header名
+	HeaderValue string `json:"header_value" form:"header_value" comment:"header值" example:"${client_ip}" validate:""`      //header值，支持变量，rename时为新header名
+}
+
 type ServiceAddHTTPInput struct {
 	ServiceName string `json:"service_name" form:"service_name" comment:"服务名" example:"" validate:"required,valid_service_name"` //服务名
 	ServiceDesc string `json:"service_desc" form:"service_desc" comment:"服务描述" example:"" validate:"required,max=255,min=1"`     //服务描述
 
-	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=1,min=0"`                           //接入类型
+	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=2,min=0"`                           //接入类型
 	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名或者前缀" example:"" validate:"required,valid_rule"`                      //域名或者前缀
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https" example:"" validate:"max=1,min=0"`                      //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri" example:"" validate:"max=1,min=0"`          //启用strip_uri
@@ -53,19 +147,94 @@ type ServiceAddHTTPInput struct {
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"` //header转换
 
+	Priority    int    `json:"priority" form:"priority" comment:"匹配优先级" example:"0" validate:"min=0"`                 //匹配优先级，越大越优先
+	MatchMethod string `json:"match_method" form:"match_method" comment:"匹配请求方法" example:"GET,POST" validate:""`      //匹配请求方法
+	MatchHeader string `json:"match_header" form:"match_header" comment:"匹配header" example:"x-canary 1" validate:""`  //匹配header 格式: name value 或 name ~regex
+	MatchQuery  string `json:"match_query" form:"match_query" comment:"匹配query参数" example:"version ~^v2" validate:""` //匹配query参数 格式: name value 或 name ~regex
+
+	MirrorTarget  string `json:"mirror_target" form:"mirror_target" comment:"流量镜像地址" example:"127.0.0.1:8081" validate:"valid_mirror_target"` //流量镜像地址
+	MirrorPercent int    `json:"mirror_percent" form:"mirror_percent" comment:"流量镜像比例" example:"10" validate:"max=100,min=0"`                 //流量镜像比例, 百分比
+
+	CacheEnable int    `json:"cache_enable" form:"cache_enable" comment:"启用响应缓存" example:"0" validate:"max=1,min=0"`                 //启用响应缓存
+	CacheStore  int    `json:"cache_store" form:"cache_store" comment:"缓存存储" example:"0" validate:"max=1,min=0"`                     //缓存存储 0=内存 1=redis
+	CacheTTL    int    `json:"cache_ttl" form:"cache_ttl" comment:"默认缓存秒数" example:"60" validate:"min=0"`                            //上游未指定有效期时的缓存秒数
+	CacheStale  int    `json:"cache_stale" form:"cache_stale" comment:"过期后可用秒数" example:"30" validate:"min=0"`                       //过期后仍可返回旧内容并后台刷新的秒数
+	CacheKey    string `json:"cache_key" form:"cache_key" comment:"缓存key组成" example:"path,query,header:Accept-Language" validate:""` //缓存key组成，逗号间隔
+
+	RespStatusMap   string `json:"resp_status_map" form:"resp_status_map" comment:"响应状态码映射" example:"502 503" validate:""`          //响应状态码映射，每行一条
+	RespJsonMask    string `json:"resp_json_mask" form:"resp_json_mask" comment:"json响应脱敏字段" example:"$.data.password" validate:""` //json响应脱敏字段，每行一条
+	RespBodyReplace string `json:"resp_body_replace" form:"resp_body_replace" comment:"响应体正则替换" example:"" validate:""`             //响应体正则替换，每行一条
+
+	CompressEnable    int    `json:"compress_enable" form:"compress_enable" comment:"启用响应压缩" example:"0" validate:"max=1,min=0"`                   //启用响应压缩
+	CompressMinSize   int    `json:"compress_min_size" form:"compress_min_size" comment:"压缩最小响应大小" example:"1024" validate:"min=0"`                //压缩的最小响应大小
+	CompressTypes     string `json:"compress_types" form:"compress_types" comment:"压缩的Content-Type" example:"application/json,text/*" validate:""` //压缩的Content-Type，逗号间隔
+	DecompressRequest int    `json:"decompress_request" form:"decompress_request" comment:"解压请求体" example:"0" validate:"max=1,min=0"`              //解压gzip或br编码的请求体后转发
+
+	WebsocketIdleTimeout int `json:"websocket_idle_timeout" form:"websocket_idle_timeout" comment:"websocket空闲超时" example:"60" validate:"min=0"`  //websocket连接空闲超时，单位s
+	WebsocketMaxLifetime int `json:"websocket_max_lifetime" form:"websocket_max_lifetime" comment:"websocket最长存活时间" example:"0" validate:"min=0"` //websocket连接最长存活时间，单位s
+	WebsocketMaxConn     int `json:"websocket_max_conn" form:"websocket_max_conn" comment:"websocket连接数上限" example:"0" validate:"min=0"`          //单个代理进程的websocket连接数上限
+
+	StreamRoutes      string `json:"stream_routes" form:"stream_routes" comment:"流式路由" example:"/events 3600" validate:"valid_stream_routes"` //流式路由，格式: 路径前缀 写超时秒数，逗号间隔
+	StreamIdleTimeout int    `json:"stream_idle_timeout" form:"stream_idle_timeout" comment:"流式响应空闲超时" example:"60" validate:"min=0"`         //流式响应空闲超时，单位s
+
+	AccessRules []HTTPAccessRuleInput `json:"access_rules" form:"access_rules" comment:"附加接入规则" validate:"dive"` //附加接入规则，同一服务可通过多个域名或前缀接入
+
+	HeaderRules []HTTPHeaderRuleInput `json:"header_rules" form:"header_rules" comment:"header规则" validate:"dive"` //请求及响应header规则，按顺序执行
+
+	CorsEnable           int    `json:"cors_enable" form:"cors_enable" comment:"启用跨域" example:"0" validate:"max=1,min=0"`                                   //启用跨域
+	CorsAllowOrigins     string `json:"cors_allow_origins" form:"cors_allow_origins" comment:"跨域允许来源" example:"https://*.example.com" validate:""`          //跨域允许来源，逗号间隔
+	CorsAllowMethods     string `json:"cors_allow_methods" form:"cors_allow_methods" comment:"跨域允许方法" example:"GET,POST" validate:""`                       //跨域允许方法，为空不限制
+	CorsAllowHeaders     string `json:"cors_allow_headers" form:"cors_allow_headers" comment:"跨域允许header" example:"Content-Type,Authorization" validate:""` //跨域允许header，为空不限制
+	CorsExposeHeaders    string `json:"cors_expose_headers" form:"cors_expose_headers" comment:"跨域暴露header" example:"" validate:""`                         //允许浏览器读取的响应header
+	CorsAllowCredentials int    `json:"cors_allow_credentials" form:"cors_allow_credentials" comment:"跨域允许cookie" example:"0" validate:"max=1,min=0"`       //跨域允许携带cookie
+	CorsMaxAge           int    `json:"cors_max_age" form:"cors_max_age" comment:"预检缓存秒数" example:"600" validate:"min=0"`                                   //预检结果缓存秒数
+
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                     //关键词
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                               //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                               //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`          //服务端限流
 
-	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
+	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=6,min=0"`                                //轮询方式
+	HashKeyType            int    `json:"hash_key_type" form:"hash_key_type" comment:"一致性hash取值方式" example:"" validate:"max=5,min=0"`                  //一致性hash取值方式 0=url 1=client_ip 2=header 3=cookie 4=query 5=jwt_claim
+	HashKeyName            string `json:"hash_key_name" form:"hash_key_name" comment:"一致性hash取值名称" example:"" validate:""`                              //一致性hash取值名称
+	HashReplicas           int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" example:"" validate:"min=0"`                        //一致性hash虚拟节点数
+	HashLoadFactor         int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数" example:"125" validate:"omitempty,min=100"` //一致性hash有界负载系数, 百分比
+	StickySession          int    `json:"sticky_session" form:"sticky_session" comment:"cookie会话保持" example:"" validate:"max=1,min=0"`                //cookie会话保持
+	StickyCookie           string `json:"sticky_cookie" form:"sticky_cookie" comment:"会话保持cookie名" example:"gateway_sticky" validate:""`             //会话保持cookie名
+	RetryNum               int    `json:"retry_num" form:"retry_num" comment:"重试次数" example:"" validate:"min=0,max=5"`                               //重试次数
+	RetryOn                string `json:"retry_on" form:"retry_on" comment:"重试条件" example:"connect_failure,502" validate:"valid_retry_on"`           //重试条件
+	RetryNonIdempotent     int    `json:"retry_non_idempotent" form:"retry_non_idempotent" comment:"重试非幂等请求" example:"" validate:"max=1,min=0"` //重试非幂等请求
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值" example:"50" validate:"max=100,min=0"`              //熔断错误率阈值, 百分比
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值" example:"" validate:"max=100,min=0"`                //熔断慢调用比例阈值, 百分比
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" example:"1000" validate:"min=0"`                        //慢调用耗时, 单位ms
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" example:"20" validate:"min=0"`                      //熔断窗口最少请求数
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" example:"10" validate:"min=0"`                                //熔断统计窗口, 单位s
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" example:"30" validate:"min=0"`                            //熔断时长, 单位s
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" example:"" validate:"max=1,min=0"`                                      //按节点熔断
+	BreakerFallbackCode int    `json:"breaker_fallback_code" form:"breaker_fallback_code" comment:"熔断返回状态码" example:"503" validate:"omitempty,min=200,max=599"` //熔断返回状态码
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" example:"" validate:""`                              //熔断返回内容
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" example:"5" validate:"min=0"`                      //被动探活连续失败摘除次数
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" example:"30" validate:"min=0"`                //摘除基础时长, 单位s
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" example:"300" validate:"min=0"`       //摘除最长时长, 单位s
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" example:"50" validate:"max=100,min=0"` //同时摘除节点数占比上限, 百分比
 	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"" validate:"required,valid_ipportlist"`                            //ip列表
 	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"" validate:"required,valid_weightlist"`               //权重列表
 	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`   //建立连接超时, 单位s
 	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
 	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
 	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+
+	CheckMethod   int    `json:"check_method" form:"check_method" comment:"探活方式" example:"" validate:"max=2,min=0"`            //探活方式 0=tcp 1=http 2=grpc
+	CheckTimeout  int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" example:"" validate:"min=0"`         //探活超时, 单位s
+	CheckInterval int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" example:"" validate:"min=0"`       //探活间隔, 单位s
+	CheckRise     int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" example:"" validate:"min=0"`               //连续成功恢复次数
+	CheckFall     int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" example:"" validate:"min=0"`               //连续失败摘除次数
+	CheckPath     string `json:"check_path" form:"check_path" comment:"http探活路径" example:"/ping" validate:""`               //http探活路径
+	CheckStatus   string `json:"check_status" form:"check_status" comment:"http探活状态码范围" example:"200-399" validate:"valid_check_status"` //http探活状态码范围
+	CheckBody     string `json:"check_body" form:"check_body" comment:"http探活响应体匹配" example:"" validate:""`                //http探活响应体匹配
 }
 
 type ServiceDeleteInput struct {
@@ -107,6 +276,16 @@ type ServiceStatOutput struct {
 	Yesterday []int64 `json:"yesterday" form:"yesterday" comment:"昨日流量" example:"" validate:""` //列表
 }
 
+type ServiceWebsocketStatOutput struct {
+	ActiveConn   int64 `json:"active_conn" form:"active_conn" comment:"当前连接数" example:"" validate:""`     //各代理进程当前连接数之和
+	TotalConn    int64 `json:"total_conn" form:"total_conn" comment:"累计连接数" example:"" validate:""`       //累计连接数
+	RejectedConn int64 `json:"rejected_conn" form:"rejected_conn" comment:"拒绝连接数" example:"" validate:""` //超过连接数上限被拒绝的连接数
+	FramesIn     int64 `json:"frames_in" form:"frames_in" comment:"上行帧数" example:"" validate:""`          //客户端发往下游的帧数
+	FramesOut    int64 `json:"frames_out" form:"frames_out" comment:"下行帧数" example:"" validate:""`        //下游发往客户端的帧数
+	BytesIn      int64 `json:"bytes_in" form:"bytes_in" comment:"上行字节数" example:"" validate:""`           //客户端发往下游的字节数
+	BytesOut     int64 `json:"bytes_out" form:"bytes_out" comment:"下行字节数" example:"" validate:""`         //下游发往客户端的字节数
+}
+
 type ServiceAddGrpcInput struct {
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
@@ -119,9 +298,32 @@ type ServiceAddGrpcInput struct {
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	HashKeyType       int    `json:"hash_key_type" form:"hash_key_type" comment:"一致性hash取值方式" validate:"max=5,min=0"`
+	HashKeyName       string `json:"hash_key_name" form:"hash_key_name" comment:"一致性hash取值名称" validate:""`
+	HashReplicas      int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" validate:"min=0"`
+	HashLoadFactor    int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数, 百分比" validate:"omitempty,min=100"`
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" validate:"min=0"`
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" validate:"min=0"`
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" validate:"max=1,min=0"`
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" validate:""`
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" validate:"min=0"`
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" validate:"min=0"`
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" validate:"min=0"`
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" validate:"max=100,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"探活方式" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" validate:"min=0"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" validate:"min=0"`
 }
 
 func (params *ServiceAddGrpcInput) GetValidParams(c *gin.Context) error {
@@ -141,9 +343,32 @@ type ServiceUpdateGrpcInput struct {
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	HashKeyType       int    `json:"hash_key_type" form:"hash_key_type" comment:"一致性hash取值方式" validate:"max=5,min=0"`
+	HashKeyName       string `json:"hash_key_name" form:"hash_key_name" comment:"一致性hash取值名称" validate:""`
+	HashReplicas      int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" validate:"min=0"`
+	HashLoadFactor    int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数, 百分比" validate:"omitempty,min=100"`
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" validate:"min=0"`
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" validate:"min=0"`
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" validate:"max=1,min=0"`
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" validate:""`
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" validate:"min=0"`
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" validate:"min=0"`
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" validate:"min=0"`
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" validate:"max=100,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"探活方式" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" validate:"min=0"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" validate:"min=0"`
 }
 
 func (params *ServiceUpdateGrpcInput) GetValidParams(c *gin.Context) error {
@@ -154,6 +379,8 @@ type ServiceAddTcpInput struct {
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	TLSMode           int    `json:"tls_mode" form:"tls_mode" comment:"tls接入 0=透传字节 1=tls卸载 2=按SNI透传, 开启后多个服务可共用端口" validate:"max=2,min=0"`
+	SNIHosts          string `json:"sni_hosts" form:"sni_hosts" comment:"SNI域名, 支持*.开头的通配, 多个逗号间隔, 为空时作为端口默认服务" validate:"valid_sni_hosts"`
 	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:"
 "`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
@@ -163,9 +390,30 @@ type ServiceAddTcpInput struct {
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	HashReplicas      int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" validate:"min=0"`
+	HashLoadFactor    int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数, 百分比" validate:"omitempty,min=100"`
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" validate:"min=0"`
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" validate:"min=0"`
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" validate:"max=1,min=0"`
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" validate:""`
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" validate:"min=0"`
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" validate:"min=0"`
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" validate:"min=0"`
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" validate:"max=100,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"探活方式" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" validate:"min=0"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" validate:"min=0"`
 }
 
 func (params *ServiceAddTcpInput) GetValidParams(c *gin.Context) error {
@@ -177,6 +425,8 @@ type ServiceUpdateTcpInput struct {
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
 	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	TLSMode           int    `json:"tls_mode" form:"tls_mode" comment:"tls接入 0=透传字节 1=tls卸载 2=按SNI透传, 开启后多个服务可共用端口" validate:"max=2,min=0"`
+	SNIHosts          string `json:"sni_hosts" form:"sni_hosts" comment:"SNI域名, 支持*.开头的通配, 多个逗号间隔, 为空时作为端口默认服务" validate:"valid_sni_hosts"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
@@ -184,11 +434,114 @@ type ServiceUpdateTcpInput struct {
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
 	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	HashReplicas      int    `json:"hash_replicas" form:"hash_replicas" comment:"一致性hash虚拟节点数" validate:"min=0"`
+	HashLoadFactor    int    `json:"hash_load_factor" form:"hash_load_factor" comment:"一致性hash有界负载系数, 百分比" validate:"omitempty,min=100"`
+
+	BreakerErrorPercent int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断错误率阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowPercent  int    `json:"breaker_slow_percent" form:"breaker_slow_percent" comment:"熔断慢调用比例阈值, 百分比" validate:"max=100,min=0"`
+	BreakerSlowTime     int    `json:"breaker_slow_time" form:"breaker_slow_time" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequests  int    `json:"breaker_min_requests" form:"breaker_min_requests" comment:"熔断窗口最少请求数" validate:"min=0"`
+	BreakerWindow       int    `json:"breaker_window" form:"breaker_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenTime     int    `json:"breaker_open_time" form:"breaker_open_time" comment:"熔断时长, 单位s" validate:"min=0"`
+	BreakerNode         int    `json:"breaker_node" form:"breaker_node" comment:"按节点熔断" validate:"max=1,min=0"`
+	BreakerFallbackBody string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"熔断返回内容" validate:""`
+
+	OutlierErrNum       int `json:"outlier_err_num" form:"outlier_err_num" comment:"被动探活连续失败摘除次数" validate:"min=0"`
+	OutlierEjectTime    int `json:"outlier_eject_time" form:"outlier_eject_time" comment:"摘除基础时长, 单位s" validate:"min=0"`
+	OutlierMaxEjectTime int `json:"outlier_max_eject_time" form:"outlier_max_eject_time" comment:"摘除最长时长, 单位s" validate:"min=0"`
+	OutlierMaxPercent   int `json:"outlier_max_percent" form:"outlier_max_percent" comment:"同时摘除节点数占比上限, 百分比" validate:"max=100,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"探活方式" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"探活超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"探活间隔, 单位s" validate:"min=0"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"连续成功恢复次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"连续失败摘除次数" validate:"min=0"`
 }
 
 func (params *ServiceUpdateTcpInput) GetValidParams(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, params)
 }
+
+type ServiceGroupListInput struct {
+	ServiceID int64 `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"` //服务ID
+}
+
+func (param *ServiceGroupListInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceGroupAddInput struct {
+	ServiceID     int64  `json:"service_id" form:"service_id" comment:"服务ID" example:"56" validate:"required"`                                 //服务ID
+	GroupName     string `json:"group_name" form:"group_name" comment:"分组名称" example:"canary" validate:"required,valid_service_name"`         //分组名称
+	RoundType     int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=6,min=0"`                               //轮询方式
+	IpList        string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"`           //ip列表
+	WeightList    string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`            //权重列表
+	MatchType     int    `json:"match_type" form:"match_type" comment:"匹配方式" example:"0" validate:"max=4,min=0"`                              //匹配方式
+	MatchName     string `json:"match_name" form:"match_name" comment:"header或cookie名" example:"" validate:""`                                //header或cookie名
+	MatchValue    string `json:"match_value" form:"match_value" comment:"匹配值" example:"" validate:""`                                         //匹配值
+	TrafficWeight int    `json:"traffic_weight" form:"traffic_weight" comment:"流量百分比" example:"10" validate:"max=100,min=0"`                 //流量百分比
+}
+
+func (param *ServiceGroupAddInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceGroupUpdateInput struct {
+	ID            int64  `json:"id" form:"id" comment:"分组ID" example:"1" validate:"required,min=1"`                                   //分组ID
+	RoundType     int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=6,min=0"`                     //轮询方式
+	IpList        string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"` //ip列表
+	WeightList    string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`  //权重列表
+	MatchType     int    `json:"match_type" form:"match_type" comment:"匹配方式" example:"0" validate:"max=4,min=0"`                    //匹配方式
+	MatchName     string `json:"match_name" form:"match_name" comment:"header或cookie名" example:"" validate:""`                      //header或cookie名
+	MatchValue    string `json:"match_value" form:"match_value" comment:"匹配值" example:"" validate:""`                               //匹配值
+	TrafficWeight int    `json:"traffic_weight" form:"traffic_weight" comment:"流量百分比" example:"10" validate:"max=100,min=0"`       //流量百分比
+}
+
+func (param *ServiceGroupUpdateInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceGroupDeleteInput struct {
+	ID int64 `json:"id" form:"id" comment:"分组ID" example:"1" validate:"required"` //分组ID
+}
+
+func (param *ServiceGroupDeleteInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceCachePurgeInput struct {
+	ID        int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`             //服务ID
+	KeyPrefix string `json:"key_prefix" form:"key_prefix" comment:"缓存key前缀" example:"/api" validate:""` //缓存key前缀，为空时清除整个服务
+}
+
+func (param *ServiceCachePurgeInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceUpstreamTLSInput struct {
+	ID                 int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                              //服务ID
+	Enable             int    `json:"enable" form:"enable" comment:"启用下游tls配置" example:"1" validate:"max=1,min=0"`                               //启用下游tls配置
+	CaCert             string `json:"ca_cert" form:"ca_cert" comment:"CA证书" example:"" validate:""`                                              //信任的CA证书，PEM格式
+	ClientCert         string `json:"client_cert" form:"client_cert" comment:"客户端证书" example:"" validate:""`                                     //客户端证书，PEM格式
+	ClientKey          string `json:"client_key" form:"client_key" comment:"客户端私钥" example:"" validate:""`                                       //客户端私钥，PEM格式，证书不变时为空表示保留原私钥
+	ServerName         string `json:"server_name" form:"server_name" comment:"SNI域名" example:"api.internal" validate:""`                         //SNI及证书校验使用的域名
+	MinVersion         string `json:"min_version" form:"min_version" comment:"最低tls版本" example:"1.2" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"` //最低tls版本
+	InsecureSkipVerify int    `json:"insecure_skip_verify" form:"insecure_skip_verify" comment:"跳过证书校验" example:"0" validate:"max=1,min=0"`      //跳过证书校验，仅用于开发环境
+}
+
+func (param *ServiceUpstreamTLSInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
+
+type ServiceClientAuthInput struct {
+	ID              int64  `json:"id" form:"id" comment:"服务ID" example:"56" validate:"required"`                                   //服务ID
+	Mode            int    `json:"mode" form:"mode" comment:"客户端证书认证" example:"2" validate:"max=2,min=0"`                          //0=关闭 1=可选 2=必须
+	CaCert          string `json:"ca_cert" form:"ca_cert" comment:"客户端CA证书" example:"" validate:""`                                //信任的客户端CA证书，PEM格式
+	AllowedSubjects string `json:"allowed_subjects" form:"allowed_subjects" comment:"允许的证书标识" example:"*.partner.com" validate:""` //匹配CN及SAN，支持*通配，多个逗号间隔
+}
+
+func (param *ServiceClientAuthInput) BindValidParam(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, param)
+}
@@ -59,6 +59,8 @@ CREATE TABLE `gateway_app` (
   `white_ips` varchar(1000) NOT NULL DEFAULT '' COMMENT 'ip白名单，支持前缀匹配',
   `qpd` bigint(20) NOT NULL DEFAULT '0' COMMENT '日请求量限制',
   `qps` bigint(20) NOT NULL DEFAULT '0' COMMENT '每秒请求量限制',
+  `cert_subjects` varchar(1000) NOT NULL DEFAULT '' COMMENT '客户端证书标识 CN或SAN, 多个逗号间隔',
+  `cert_services` varchar(1000) NOT NULL DEFAULT '' COMMENT '证书标识生效的服务名, 多个逗号间隔',
   `create_at` datetime NOT NULL COMMENT '添加时间',
   `update_at` datetime NOT NULL COMMENT '更新时间',
   `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
@@ -68,11 +70,32 @@ CREATE TABLE `gateway_app` (
 -- 转存表中的数据 `gateway_app`
 --
 
-INSERT INTO `gateway_app` (`id`, `app_id`, `name`, `secret`, `white_ips`, `qpd`, `qps`, `create_at`, `update_at`, `is_delete`) VALUES
-(31, 'app_id_a', '租户A', '449441eb5e72dca9c42a12f3924ea3a2', 'white_ips', 100000, 100, '2020-04-15 20:55:02', '2020-04-21 07:23:34', 0),
-(32, 'app_id_b', '租户B', '8d7b11ec9be0e59a36b52f32366c09cb', '', 20, 0, '2020-04-15 21:40:52', '2020-04-21 07:23:27', 0),
-(33, 'app_id', '租户名称', '', '', 0, 0, '2020-04-15 22:02:23', '2020-04-15 22:06:51', 1),
-(34, 'app_id45', '名称', '07d980f8a49347523ee1d5c1c41aec02', '', 0, 0, '2020-04-15 22:06:38', '2020-04-15 22:06:49', 1);
+INSERT INTO `gateway_app` (`id`, `app_id`, `name`, `secret`, `white_ips`, `qpd`, `qps`, `cert_subjects`, `cert_services`, `create_at`, `update_at`, `is_delete`) VALUES
+(31, 'app_id_a', '租户A', '449441eb5e72dca9c42a12f3924ea3a2', 'white_ips', 100000, 100, '', '', '2020-04-15 20:55:02', '2020-04-21 07:23:34', 0),
+(32, 'app_id_b', '租户B', '8d7b11ec9be0e59a36b52f32366c09cb', '', 20, 0, '', '', '2020-04-15 21:40:52', '2020-04-21 07:23:27', 0),
+(33, 'app_id', '租户名称', '', '', 0, 0, '', '', '2020-04-15 22:02:23', '2020-04-15 22:06:51', 1),
+(34, 'app_id45', '名称', '07d980f8a49347523ee1d5c1c41aec02', '', 0, 0, '', '', '2020-04-15 22:06:38', '2020-04-15 22:06:49', 1);
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_cert`
+--
+
+CREATE TABLE `gateway_cert` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '证书名称',
+  `domains` varchar(2000) NOT NULL DEFAULT '' COMMENT '证书包含的域名, 从证书解析, 逗号间隔',
+  `issuer` varchar(255) NOT NULL DEFAULT '' COMMENT '签发者',
+  `cert_pem` text NOT NULL COMMENT '证书 PEM格式, 可包含中间证书',
+  `key_pem` text NOT NULL COMMENT '私钥 PEM格式',
+  `is_default` tinyint(4) NOT NULL DEFAULT '0' COMMENT '默认证书 1=是',
+  `not_before` datetime NOT NULL COMMENT '生效时间',
+  `not_after` datetime NOT NULL COMMENT '过期时间',
+  `create_at` datetime NOT NULL COMMENT '添加时间',
+  `update_at` datetime NOT NULL COMMENT '更新时间',
+  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关https证书表';
 
 -- --------------------------------------------------------
 
@@ -153,13 +176,36 @@ INSERT INTO `gateway_service_grpc_rule` (`id`, `service_id`, `port`, `header_tra
 CREATE TABLE `gateway_service_http_rule` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL COMMENT '服务id',
-  `rule_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '匹配类型 0=url前缀url_prefix 1=域名domain ',
-  `rule` varchar(255) NOT NULL DEFAULT '' COMMENT 'type=domain表示域名，type=url_prefix时表示url前缀',
+  `rule_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '匹配类型 0=url前缀url_prefix 1=域名domain 2=正则路径url_regex',
+  `rule` varchar(255) NOT NULL DEFAULT '' COMMENT 'type=domain表示域名(支持*.开头的通配域名)，type=url_prefix时表示url前缀，type=url_regex时表示路径正则',
   `need_https` tinyint(4) NOT NULL DEFAULT '0' COMMENT '支持https 1=支持',
   `need_strip_uri` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用strip_uri 1=启用',
   `need_websocket` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否支持websocket 1=支持',
   `url_rewrite` varchar(5000) NOT NULL DEFAULT '' COMMENT 'url重写功能 格式：^/gatekeeper/test_service(.*) $1 多个逗号间隔',
-  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔'
+  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔',
+  `priority` int(11) NOT NULL DEFAULT '0' COMMENT '匹配优先级，越大越优先',
+  `match_method` varchar(255) NOT NULL DEFAULT '' COMMENT '匹配请求方法 如GET,POST, 为空不限制',
+  `match_header` varchar(2000) NOT NULL DEFAULT '' COMMENT '匹配header 格式: name value 或 name ~regex, 多个逗号间隔',
+  `match_query` varchar(2000) NOT NULL DEFAULT '' COMMENT '匹配query参数 格式: name value 或 name ~regex, 多个逗号间隔',
+  `mirror_target` varchar(255) NOT NULL DEFAULT '' COMMENT '流量镜像地址 格式: ip:port 或 http(s)://ip:port, 为空不镜像',
+  `mirror_percent` int(11) NOT NULL DEFAULT '0' COMMENT '流量镜像比例, 百分比',
+  `cache_enable` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用响应缓存 1=启用',
+  `cache_store` tinyint(4) NOT NULL DEFAULT '0' COMMENT '缓存存储 0=内存 1=redis',
+  `cache_ttl` int(11) NOT NULL DEFAULT '0' COMMENT '上游未指定有效期时的缓存秒数, 0表示只缓存上游指定有效期的响应',
+  `cache_stale` int(11) NOT NULL DEFAULT '0' COMMENT '过期后仍可返回旧内容并后台刷新的秒数',
+  `cache_key` varchar(255) NOT NULL DEFAULT '' COMMENT '缓存key组成 path,query,method,host,header:名称,cookie:名称 逗号间隔, 为空时为path,query,method',
+  `resp_status_map` varchar(1000) NOT NULL DEFAULT '' COMMENT '响应状态码映射 格式: 原状态码 新状态码, 每行一条',
+  `resp_json_mask` varchar(2000) NOT NULL DEFAULT '' COMMENT 'json响应脱敏字段 JSONPath格式如$.data.password, 每行一条',
+  `resp_body_replace` varchar(5000) NOT NULL DEFAULT '' COMMENT '响应体正则替换 格式: 正则 替换内容, 每行一条',
+  `compress_enable` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用响应压缩 1=启用, 按Accept-Encoding使用br或gzip',
+  `compress_min_size` int(11) NOT NULL DEFAULT '0' COMMENT '压缩的最小响应大小, 单位byte, 0使用默认值',
+  `compress_types` varchar(1000) NOT NULL DEFAULT '' COMMENT '压缩的Content-Type 逗号间隔, 支持text/*, 为空使用默认值',
+  `decompress_request` tinyint(4) NOT NULL DEFAULT '0' COMMENT '解压gzip或br编码的请求体后转发 1=启用',
+  `websocket_idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT 'websocket连接空闲超时, 单位s, 0使用默认值',
+  `websocket_max_lifetime` int(11) NOT NULL DEFAULT '0' COMMENT 'websocket连接最长存活时间, 单位s, 0使用默认值',
+  `websocket_max_conn` int(11) NOT NULL DEFAULT '0' COMMENT '单个代理进程的websocket连接数上限, 0不限制',
+  `stream_routes` varchar(1000) NOT NULL DEFAULT '' COMMENT '流式路由 格式: 路径前缀 或 路径前缀 写超时秒数(0不限制), 多个逗号间隔',
+  `stream_idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '流式响应空闲超时, 下游无数据(含心跳)超过该秒数断开, 0使用默认值'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -237,10 +283,37 @@ INSERT INTO `gateway_service_info` (`id`, `load_type`, `service_name`, `service_
 CREATE TABLE `gateway_service_load_balance` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
-  `check_method` tinyint(20) NOT NULL DEFAULT '0' COMMENT '检查方法 0=tcpchk,检测端口是否握手成功',
+  `check_method` tinyint(20) NOT NULL DEFAULT '0' COMMENT '检查方法 0=tcpchk,检测端口是否握手成功 1=http 2=grpc',
   `check_timeout` int(10) NOT NULL DEFAULT '0' COMMENT 'check超时时间,单位s',
   `check_interval` int(11) NOT NULL DEFAULT '0' COMMENT '检查间隔, 单位s',
-  `round_type` tinyint(4) NOT NULL DEFAULT '2' COMMENT '轮询方式 0=random 1=round-robin 2=weight_round-robin 3=ip_hash',
+  `check_rise` int(11) NOT NULL DEFAULT '0' COMMENT '连续成功次数达到后恢复节点',
+  `check_fall` int(11) NOT NULL DEFAULT '0' COMMENT '连续失败次数达到后摘除节点',
+  `check_path` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查路径',
+  `check_status` varchar(255) NOT NULL DEFAULT '' COMMENT 'http期望状态码范围 如200-399',
+  `check_body` varchar(255) NOT NULL DEFAULT '' COMMENT 'http响应体需包含的内容',
+  `round_type` tinyint(4) NOT NULL DEFAULT '2' COMMENT '轮询方式 0=random 1=round-robin 2=weight_round-robin 3=ip_hash 4=least_conn 5=p2c 6=ewma',
+  `hash_key_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '一致性hash取值 0=url 1=client_ip 2=header 3=cookie 4=query 5=jwt_claim',
+  `hash_key_name` varchar(255) NOT NULL DEFAULT '' COMMENT '一致性hash取值名称 如header名',
+  `hash_replicas` int(11) NOT NULL DEFAULT '0' COMMENT '一致性hash虚拟节点数, 0=默认10',
+  `hash_load_factor` int(11) NOT NULL DEFAULT '0' COMMENT '一致性hash有界负载系数, 百分比 如125, 0=不限制',
+  `sticky_session` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否开启cookie会话保持 1=开启',
+  `sticky_cookie` varchar(255) NOT NULL DEFAULT '' COMMENT '会话保持cookie名',
+  `retry_num` int(11) NOT NULL DEFAULT '0' COMMENT '换节点重试次数, 0=不重试',
+  `retry_on` varchar(255) NOT NULL DEFAULT '' COMMENT '重试条件 如connect_failure,timeout,502,503,504',
+  `retry_non_idempotent` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否重试非幂等请求 1=是',
+  `breaker_error_percent` int(11) NOT NULL DEFAULT '0' COMMENT '熔断错误率阈值, 百分比, 0=不按错误率熔断',
+  `breaker_slow_percent` int(11) NOT NULL DEFAULT '0' COMMENT '熔断慢调用比例阈值, 百分比, 0=不按慢调用熔断',
+  `breaker_slow_time` int(11) NOT NULL DEFAULT '0' COMMENT '慢调用耗时, 单位ms',
+  `breaker_min_requests` int(11) NOT NULL DEFAULT '0' COMMENT '熔断统计窗口内最少请求数',
+  `breaker_window` int(11) NOT NULL DEFAULT '0' COMMENT '熔断统计窗口, 单位s',
+  `breaker_open_time` int(11) NOT NULL DEFAULT '0' COMMENT '熔断时长, 单位s',
+  `breaker_node` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否按节点熔断 1=是',
+  `breaker_fallback_code` int(11) NOT NULL DEFAULT '0' COMMENT '熔断时返回的http状态码',
+  `breaker_fallback_body` varchar(2000) NOT NULL DEFAULT '' COMMENT '熔断时返回的内容',
+  `outlier_err_num` int(11) NOT NULL DEFAULT '0' COMMENT '被动探活连续失败多少次摘除, 0=默认5',
+  `outlier_eject_time` int(11) NOT NULL DEFAULT '0' COMMENT '摘除基础时长, 单位s, 0=默认30',
+  `outlier_max_eject_time` int(11) NOT NULL DEFAULT '0' COMMENT '摘除最长时长, 单位s, 0=默认300',
+  `outlier_max_percent` int(11) NOT NULL DEFAULT '0' COMMENT '同时摘除节点数占比上限, 百分比, 0=默认50, 小于100时单节点不摘除',
   `ip_list` varchar(2000) NOT NULL DEFAULT '' COMMENT 'ip列表',
   `weight_list` varchar(2000) NOT NULL DEFAULT '' COMMENT '权重列表',
   `forbid_list` varchar(2000) NOT NULL DEFAULT '' COMMENT '禁用ip列表',
@@ -283,6 +356,93 @@ INSERT INTO `gateway_service_load_balance` (`id`, `service_id`, `check_method`,
 
 -- --------------------------------------------------------
 
+--
+-- 表的结构 `gateway_service_upstream_group`
+--
+
+CREATE TABLE `gateway_service_upstream_group` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `group_name` varchar(255) NOT NULL DEFAULT '' COMMENT '分组名称 如canary',
+  `round_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '轮询方式 同load_balance',
+  `ip_list` varchar(2000) NOT NULL DEFAULT '' COMMENT 'ip列表',
+  `weight_list` varchar(2000) NOT NULL DEFAULT '' COMMENT '权重列表',
+  `match_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '匹配方式 0=按比例 1=header 2=cookie 3=客户端ip段 4=jwt app_id',
+  `match_name` varchar(255) NOT NULL DEFAULT '' COMMENT 'header或cookie名',
+  `match_value` varchar(2000) NOT NULL DEFAULT '' COMMENT '匹配值，逗号间隔，客户端ip段支持cidr',
+  `traffic_weight` int(11) NOT NULL DEFAULT '0' COMMENT '按比例分流时的流量百分比',
+  `create_at` datetime NOT NULL DEFAULT '1971-01-01 00:00:00' COMMENT '添加时间',
+  `update_at` datetime NOT NULL DEFAULT '1971-01-01 00:00:00' COMMENT '更新时间',
+  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关灰度分组表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_header_rule`
+--
+
+CREATE TABLE `gateway_service_header_rule` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `direction` tinyint(4) NOT NULL DEFAULT '0' COMMENT '作用方向 0=请求 1=响应',
+  `op` varchar(20) NOT NULL DEFAULT '' COMMENT '操作 add=增加 set=设置 del=删除 rename=改名',
+  `header_name` varchar(255) NOT NULL DEFAULT '' COMMENT 'header名',
+  `header_value` varchar(2000) NOT NULL DEFAULT '' COMMENT 'header值，支持变量${client_ip}、${trace_id}、${app_id}等，rename时为新header名'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关header规则表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_cors`
+--
+
+CREATE TABLE `gateway_service_cors` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `enable` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用跨域 1=启用',
+  `allow_origins` varchar(2000) NOT NULL DEFAULT '' COMMENT '允许的来源 逗号间隔，支持*及*.example.com',
+  `allow_methods` varchar(255) NOT NULL DEFAULT '' COMMENT '允许的请求方法 逗号间隔，为空不限制',
+  `allow_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '允许的请求header 逗号间隔，为空或*不限制',
+  `expose_headers` varchar(1000) NOT NULL DEFAULT '' COMMENT '允许浏览器读取的响应header 逗号间隔',
+  `allow_credentials` tinyint(4) NOT NULL DEFAULT '0' COMMENT '允许携带cookie 1=允许',
+  `max_age` int(11) NOT NULL DEFAULT '0' COMMENT '预检结果缓存秒数'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关跨域策略表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_upstream_tls`
+--
+
+CREATE TABLE `gateway_service_upstream_tls` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `enable` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用下游tls配置 1=启用',
+  `ca_cert` text NOT NULL COMMENT '信任的CA证书 PEM格式, 为空使用系统CA',
+  `client_cert` text NOT NULL COMMENT '客户端证书 PEM格式, 下游要求双向认证时配置',
+  `client_key` text NOT NULL COMMENT '客户端私钥 PEM格式',
+  `server_name` varchar(255) NOT NULL DEFAULT '' COMMENT 'SNI及证书校验使用的域名, 为空使用下游地址',
+  `min_version` varchar(10) NOT NULL DEFAULT '' COMMENT '最低tls版本 1.0/1.1/1.2/1.3, 为空为1.2',
+  `insecure_skip_verify` tinyint(4) NOT NULL DEFAULT '0' COMMENT '跳过证书校验 1=跳过, 仅用于开发环境'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关下游tls配置表';
+
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_client_auth`
+--
+
+CREATE TABLE `gateway_service_client_auth` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `mode` tinyint(4) NOT NULL DEFAULT '0' COMMENT '客户端证书认证 0=关闭 1=可选 2=必须',
+  `ca_cert` text NOT NULL COMMENT '信任的客户端CA证书 PEM格式, 可包含多个',
+  `allowed_subjects` varchar(1000) NOT NULL DEFAULT '' COMMENT '允许的证书标识 匹配CN及SAN, 支持*通配, 多个逗号间隔, 为空不限制且不映射租户'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关客户端证书认证表';
+
+-- --------------------------------------------------------
+
 --
 -- 表的结构 `gateway_service_tcp_rule`
 --
@@ -290,7 +450,9 @@ INSERT INTO `gateway_service_load_balance` (`id`, `service_id`, `check_method`,
 CREATE TABLE `gateway_service_tcp_rule` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL COMMENT '服务id',
-  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号'
+  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号',
+  `tls_mode` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'tls接入 0=透传字节 1=tls卸载 2=按SNI透传',
+  `sni_hosts` varchar(1000) NOT NULL DEFAULT '' COMMENT 'SNI域名 支持*.开头的通配, 多个逗号间隔, 为空时作为端口默认服务'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -326,6 +488,12 @@ ALTER TABLE `gateway_admin`
 ALTER TABLE `gateway_app`
   ADD PRIMARY KEY (`id`);
 
+--
+-- Indexes for table `gateway_cert`
+--
+ALTER TABLE `gateway_cert`
+  ADD PRIMARY KEY (`id`);
+
 --
 -- Indexes for table `gateway_service_access_control`
 --
@@ -356,6 +524,41 @@ ALTER TABLE `gateway_service_info`
 ALTER TABLE `gateway_service_load_balance`
   ADD PRIMARY KEY (`id`);
 
+--
+-- Indexes for table `gateway_service_upstream_group`
+--
+ALTER TABLE `gateway_service_upstream_group`
+  ADD PRIMARY KEY (`id`),
+  ADD KEY `idx_service_id` (`service_id`);
+
+--
+-- Indexes for table `gateway_service_header_rule`
+--
+ALTER TABLE `gateway_service_header_rule`
+  ADD PRIMARY KEY (`id`),
+  ADD KEY `idx_service_id` (`service_id`);
+
+--
+-- Indexes for table `gateway_service_cors`
+--
+ALTER TABLE `gateway_service_cors`
+  ADD PRIMARY KEY (`id`),
+  ADD UNIQUE KEY `idx_service_id` (`service_id`);
+
+--
+-- Indexes for table `gateway_service_upstream_tls`
+--
+ALTER TABLE `gateway_service_upstream_tls`
+  ADD PRIMARY KEY (`id`),
+  ADD UNIQUE KEY `idx_service_id` (`service_id`);
+
+--
+-- Indexes for table `gateway_service_client_auth`
+--
+ALTER TABLE `gateway_service_client_auth`
+  ADD PRIMARY KEY (`id`),
+  ADD UNIQUE KEY `idx_service_id` (`service_id`);
+
 --
 -- Indexes for table `gateway_service_tcp_rule`
 --
@@ -377,6 +580,11 @@ ALTER TABLE `gateway_admin`
 ALTER TABLE `gateway_app`
   MODIFY `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id', AUTO_INCREMENT=35;
 --
+-- 使用表AUTO_INCREMENT `gateway_cert`
+--
+ALTER TABLE `gateway_cert`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
 -- 使用表AUTO_INCREMENT `gateway_service_access_control`
 --
 ALTER TABLE `gateway_service_access_control`
@@ -402,6 +610,31 @@ ALTER TABLE `gateway_service_info`
 ALTER TABLE `gateway_service_load_balance`
   MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=190;
 --
+-- 使用表AUTO_INCREMENT `gateway_service_upstream_group`
+--
+ALTER TABLE `gateway_service_upstream_group`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
+-- 使用表AUTO_INCREMENT `gateway_service_header_rule`
+--
+ALTER TABLE `gateway_service_header_rule`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
+-- 使用表AUTO_INCREMENT `gateway_service_cors`
+--
+ALTER TABLE `gateway_service_cors`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
+-- 使用表AUTO_INCREMENT `gateway_service_upstream_tls`
+--
+ALTER TABLE `gateway_service_upstream_tls`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
+-- 使用表AUTO_INCREMENT `gateway_service_client_auth`
+--
+ALTER TABLE `gateway_service_client_auth`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=1;
+--
 -- 使用表AUTO_INCREMENT `gateway_service_tcp_rule`
 --
 ALTER TABLE `gateway_service_tcp_rule`
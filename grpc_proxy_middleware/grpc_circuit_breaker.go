@@ -0,0 +1,32 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+)
+
+//服务熔断时返回Unavailable及降级内容
+func GrpcCircuitBreakerMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		serviceDetail := GrpcStreamService(ss, serviceDetail)
+		breaker, err := dao.LoadBalancerHandler.GetCircuitBreaker(serviceDetail)
+		if err != nil {
+			return err
+		}
+		if breaker != nil && !breaker.Allow() {
+			body := serviceDetail.LoadBalance.BreakerFallbackBody
+			if body == "" {
+				body = "service circuit breaker open"
+			}
+			return status.Error(codes.Unavailable, body)
+		}
+		if err := handler(srv, ss); err != nil {
+			log.Printf("GrpcCircuitBreakerMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//客户端证书认证：证书链已在tls握手时按服务CA校验，此处校验证书标识并映射为租户
+func GrpcClientCertMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		if !ok {
+			return status.Error(codes.Internal, "miss metadata from context")
+		}
+		//租户信息只能由网关设置，丢弃客户端伪造的值
+		delete(md, "app")
+		if !serviceDetail.ClientAuth.Enabled() {
+			return handler(srv, ss)
+		}
+		var identities []string
+		if peerCtx, ok := peer.FromContext(ss.Context()); ok {
+			if tlsInfo, ok := peerCtx.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+				identities = dao.CertIdentities(tlsInfo.State.VerifiedChains[0][0])
+			}
+		}
+		if identities == nil {
+			if serviceDetail.ClientAuth.Mode == dao.ClientAuthModeRequired {
+				return status.Error(codes.Unauthenticated, "client certificate required")
+			}
+			return handler(srv, ss)
+		}
+		if !serviceDetail.ClientAuth.AllowIdentity(identities) {
+			return status.Error(codes.PermissionDenied, "client certificate subject not allowed")
+		}
+		//只有服务允许规则中的标识才映射租户，且租户需绑定该服务
+		allowed := serviceDetail.ClientAuth.AllowedIdentities(identities)
+		if appInfo := dao.AppManagerHandler.GetAppByCertIdentity(serviceDetail.Info.ServiceName, allowed); appInfo != nil {
+			md.Set("app", public.Obj2Json(appInfo))
+		}
+		return handler(srv, ss)
+	}
+}
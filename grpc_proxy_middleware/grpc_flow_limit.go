@@ -32,7 +32,8 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 		addrPos:=strings.LastIndex(peerAddr,":")
 		clientIP:=peerAddr[0:addrPos]
 		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
-			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+			clientLimiter, err := public.FlowLimiterHandler.GetOwnedLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
 				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
 			if err != nil {
@@ -0,0 +1,41 @@
+package grpc_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+//一致性hash取值，header对应metadata，取不到时退化为方法名
+func GrpcHashKey(serviceDetail *dao.ServiceDetail) func(ss grpc.ServerStream) string {
+	return func(ss grpc.ServerStream) string {
+		keyName := strings.ToLower(serviceDetail.LoadBalance.HashKeyName)
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		hashKey := ""
+		switch serviceDetail.LoadBalance.HashKeyType {
+		case public.HashKeyTypeClientIP:
+			if peerCtx, ok := peer.FromContext(ss.Context()); ok {
+				hashKey, _, _ = net.SplitHostPort(peerCtx.Addr.String())
+			}
+		case public.HashKeyTypeHeader:
+			if values := md.Get(keyName); len(values) > 0 {
+				hashKey = values[0]
+			}
+		case public.HashKeyTypeJwtClaim:
+			if auths := md.Get("authorization"); len(auths) > 0 {
+				token := strings.ReplaceAll(auths[0], "Bearer ", "")
+				if claims, err := public.JwtDecode(token); err == nil {
+					hashKey = public.JwtClaim(claims, serviceDetail.LoadBalance.HashKeyName)
+				}
+			}
+		}
+		if hashKey == "" {
+			hashKey, _ = grpc.MethodFromServerStream(ss)
+		}
+		return hashKey
+	}
+}
@@ -23,8 +23,9 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 			authToken = auths[0]
 		}
 		token:=strings.ReplaceAll(authToken,"Bearer ","")
-		appMatched:=false
-		if token!=""{
+		//已通过客户端证书映射租户时不再解析token
+		appMatched:=len(md.Get("app"))>0
+		if token!="" && !appMatched{
 			claims,err:=public.JwtDecode(token)
 			if err!=nil{
 				return errors.WithMessage(err,"JwtDecode")
@@ -40,7 +40,8 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		addrPos:=strings.LastIndex(peerAddr,":")
 		clientIP:=peerAddr[0:addrPos]
 		if appInfo.Qps > 0 {
-			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+			clientLimiter, err := public.FlowLimiterHandler.GetOwnedLimiter(
+				public.FlowAppPrefix+appInfo.AppID,
 				public.FlowAppPrefix+appInfo.AppID+"_"+clientIP,
 				float64(appInfo.Qps))
 			if err != nil {
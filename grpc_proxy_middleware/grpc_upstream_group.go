@@ -0,0 +1,87 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log"
+	"net"
+	"strings"
+)
+
+type upstreamGroupKey struct{}
+
+//携带所选灰度分组的stream
+type upstreamGroupStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *upstreamGroupStream) Context() context.Context {
+	return s.ctx
+}
+
+//灰度分流，header对应metadata，不支持cookie
+func GrpcUpstreamGroupMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if len(serviceDetail.UpstreamGroups) == 0 {
+			return handler(srv, ss)
+		}
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		matchCtx := &dao.UpstreamMatchContext{
+			Header: func(name string) string {
+				if values := md.Get(strings.ToLower(name)); len(values) > 0 {
+					return values[0]
+				}
+				return ""
+			},
+		}
+		if peerCtx, ok := peer.FromContext(ss.Context()); ok {
+			matchCtx.ClientIP, _, _ = net.SplitHostPort(peerCtx.Addr.String())
+		}
+		if appInfos := md.Get("app"); len(appInfos) > 0 {
+			appInfo := &dao.App{}
+			if err := json.Unmarshal([]byte(appInfos[0]), appInfo); err == nil {
+				matchCtx.AppID = appInfo.AppID
+			}
+		}
+		groupDetail := serviceDetail.SelectUpstream(matchCtx)
+		if groupDetail.GroupName() == "" {
+			return handler(srv, ss)
+		}
+		groupCounter, err := public.FlowCounterHandler.GetCounter(public.FlowGroupPrefix + serviceDetail.Info.ServiceName + "_" + groupDetail.GroupName())
+		if err != nil {
+			return err
+		}
+		groupCounter.Increase()
+		groupStream := &upstreamGroupStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), upstreamGroupKey{}, groupDetail),
+		}
+		if err := handler(srv, groupStream); err != nil {
+			log.Printf("GrpcUpstreamGroupMiddleware failed with error %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
+
+//记录stream开始时的服务配置，reload后进行中的stream仍按此选择上游
+func GrpcServiceStream(ss grpc.ServerStream, serviceDetail *dao.ServiceDetail) grpc.ServerStream {
+	return &upstreamGroupStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), upstreamGroupKey{}, serviceDetail),
+	}
+}
+
+//stream所选的灰度分组，未命中时返回stream开始时的服务配置，均未记录时返回serviceDetail
+func GrpcStreamService(ss grpc.ServerStream, serviceDetail *dao.ServiceDetail) *dao.ServiceDetail {
+	if groupDetail, ok := ss.Context().Value(upstreamGroupKey{}).(*dao.ServiceDetail); ok {
+		return groupDetail
+	}
+	return serviceDetail
+}
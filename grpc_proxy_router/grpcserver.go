@@ -1,68 +1,300 @@
 package grpc_proxy_router
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
 	"github.com/e421083458/go_gateway/grpc_proxy_middleware"
+	"github.com/e421083458/go_gateway/public"
 	"github.com/e421083458/go_gateway/reverse_proxy"
-	"github.com/e421083458/grpc-proxy/proxy"
+	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
-var grpcServerList = []*warpGrpcServer{}
+var (
+	grpcServerMap     = map[string]*warpGrpcServer{}
+	grpcServerLocker  sync.Mutex
+	grpcConnPruneStop = make(chan struct{})
+)
+
+const (
+	grpcConnPruneInterval   = 30 * time.Second //定时回收已摘除节点的下游连接
+	DefaultGrpcDrainTimeout = 10 * time.Second //停止监听后等待进行中rpc结束的时长
+)
 
 type warpGrpcServer struct {
-	Addr string
+	Addr         string
+	listenerJson string //启动时影响监听及下游连接的配置快照
+	lis          *grpcListener
+	tlsConfig    *tls.Config //下游tls配置，停止后关闭对应连接
 	*grpc.Server
+
+	serviceDetail *dao.ServiceDetail //当前服务配置，reload后无需重新绑定时直接替换
+	locker        sync.RWMutex
+}
+
+func (s *warpGrpcServer) getService() *dao.ServiceDetail {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return s.serviceDetail
+}
+
+func (s *warpGrpcServer) setService(serviceDetail *dao.ServiceDetail) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.serviceDetail = serviceDetail
+}
+
+//端口、客户端证书认证及下游tls变化时需重新绑定，其余配置在新建stream时生效
+func grpcListenerJson(serviceDetail *dao.ServiceDetail) string {
+	return public.Obj2Json([]interface{}{serviceDetail.GRPCRule.Port, serviceDetail.ClientAuth, serviceDetail.UpstreamTLS})
+}
+
+//listener关闭时通知，用于确认端口已释放
+type grpcListener struct {
+	net.Listener
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (l *grpcListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return err
+}
+
+//服务reload后重新对齐监听
+type grpcServerObserver struct {
+}
+
+func (o *grpcServerObserver) Update() {
+	grpcServerReconcile()
+	grpcConnPrune()
 }
 
 func GrpcServerRun() {
-	serviceList := dao.ServiceManagerHandler.GetGrpcServiceList()
-	for _, serviceItem := range serviceList {
-		tempItem := serviceItem
-		go func(serviceDetail *dao.ServiceDetail) {
-			addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
-			rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
-			if err != nil {
-				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
+	grpcServerReconcile()
+	dao.ServiceManagerHandler.Attach(&grpcServerObserver{})
+	go func() {
+		ticker := time.NewTicker(grpcConnPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-grpcConnPruneStop:
 				return
+			case <-ticker.C:
+				grpcConnPrune()
 			}
-			lis, err := net.Listen("tcp", addr)
+		}
+	}()
+}
+
+//汇总grpc服务(含灰度分组)当前可用节点，关闭其余下游地址的连接
+func grpcConnPrune() {
+	activeAddrs := map[string]bool{}
+	for _, serviceDetail := range dao.ServiceManagerHandler.GetGrpcServiceList() {
+		detailList := []*dao.ServiceDetail{serviceDetail}
+		for _, group := range serviceDetail.UpstreamGroups {
+			detailList = append(detailList, serviceDetail.ForGroup(group))
+		}
+		for _, detail := range detailList {
+			conf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(detail)
 			if err != nil {
-				log.Fatalf(" [INFO] GrpcListen %v err:%v\n", addr, err)
+				continue
 			}
-			grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(rb)
-			s := grpc.NewServer(
-				grpc.ChainStreamInterceptor(
-					grpc_proxy_middleware.GrpcFlowCountMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcFlowLimitMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcWhiteListMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcBlackListMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcHeaderTransferMiddleware(serviceDetail),
-				),
-				grpc.CustomCodec(proxy.Codec()),
-				grpc.UnknownServiceHandler(grpcHandler))
-
-			grpcServerList = append(grpcServerList, &warpGrpcServer{
-				Addr:   addr,
-				Server: s,
-			})
-			log.Printf(" [INFO] grpc_proxy_run %v\n", addr)
-			if err := s.Serve(lis); err != nil {
-				log.Fatalf(" [INFO] grpc_proxy_run %v err:%v\n", addr, err)
+			for _, item := range conf.GetConf() {
+				activeAddrs[strings.Split(item, ",")[0]] = true
 			}
-		}(tempItem)
+		}
 	}
+	reverse_proxy.PruneGrpcConns(activeAddrs)
 }
 
-func GrpcServerStop() {
-	for _, grpcServer := range grpcServerList {
+//按当前服务列表启停监听：新增服务启动，删除服务优雅关闭，监听相关配置变更的服务重新绑定
+func grpcServerReconcile() {
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+	serviceMap := map[string]*dao.ServiceDetail{}
+	for _, serviceItem := range dao.ServiceManagerHandler.GetGrpcServiceList() {
+		serviceMap[serviceItem.Info.ServiceName] = serviceItem
+	}
+	for serviceName, grpcServer := range grpcServerMap {
+		serviceDetail, ok := serviceMap[serviceName]
+		if ok && grpcListenerJson(serviceDetail) == grpcServer.listenerJson {
+			grpcServer.setService(serviceDetail)
+			continue
+		}
+		grpcServerShutdown(grpcServer)
+		delete(grpcServerMap, serviceName)
+	}
+	for serviceName, serviceDetail := range serviceMap {
+		if _, ok := grpcServerMap[serviceName]; ok {
+			continue
+		}
+		grpcServer, err := grpcServerStart(serviceDetail)
+		if err != nil {
+			log.Printf(" [ERROR] grpc_proxy_run %v err:%v\n", serviceName, err)
+			continue
+		}
+		grpcServerMap[serviceName] = grpcServer
+	}
+}
+
+func grpcServerStart(serviceDetail *dao.ServiceDetail) (*warpGrpcServer, error) {
+	addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
+	if _, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := dao.UpstreamTLSHandler.GetTLSConfig(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs, err := dao.ClientAuthHandler.GetCertPool(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	lis := &grpcListener{Listener: ln, closed: make(chan struct{})}
+	grpcServer := &warpGrpcServer{
+		Addr:          addr,
+		listenerJson:  grpcListenerJson(serviceDetail),
+		lis:           lis,
+		tlsConfig:     tlsConfig,
+		serviceDetail: serviceDetail,
+	}
+	//命中灰度分组时使用分组的负载均衡器
+	balancer := func(stream grpc.ServerStream) (load_balance.LoadBalance, load_balance.Feedback, error) {
+		streamService := grpc_proxy_middleware.GrpcStreamService(stream, grpcServer.getService())
+		rb, err := dao.LoadBalancerHandler.GetLoadBalancer(streamService)
+		if err != nil {
+			return nil, nil, err
+		}
+		fb, err := dao.LoadBalancerHandler.GetFeedback(streamService)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rb, fb, nil
+	}
+	hashKey := func(stream grpc.ServerStream) string {
+		streamService := grpc_proxy_middleware.GrpcStreamService(stream, grpcServer.getService())
+		return grpc_proxy_middleware.GrpcHashKey(streamService)(stream)
+	}
+	grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(balancer, hashKey, tlsConfig)
+	//每个stream按当前服务配置执行中间件，进行中的stream沿用开始时的配置
+	interceptor := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		streamService := grpcServer.getService()
+		ss = grpc_proxy_middleware.GrpcServiceStream(ss, streamService)
+		return grpcStreamChain(
+			grpc_proxy_middleware.GrpcFlowCountMiddleware(streamService),
+			grpc_proxy_middleware.GrpcFlowLimitMiddleware(streamService),
+			grpc_proxy_middleware.GrpcClientCertMiddleware(streamService),
+			grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(streamService),
+			grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(streamService),
+			grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(streamService),
+			grpc_proxy_middleware.GrpcWhiteListMiddleware(streamService),
+			grpc_proxy_middleware.GrpcBlackListMiddleware(streamService),
+			grpc_proxy_middleware.GrpcHeaderTransferMiddleware(streamService),
+			grpc_proxy_middleware.GrpcUpstreamGroupMiddleware(streamService),
+			grpc_proxy_middleware.GrpcCircuitBreakerMiddleware(streamService),
+		)(srv, ss, info, handler)
+	}
+	opts := []grpc.ServerOption{
+		grpc.StreamInterceptor(interceptor),
+		grpc.CustomCodec(reverse_proxy.GrpcCodec()),
+		grpc.UnknownServiceHandler(grpcHandler),
+	}
+	//开启客户端证书认证时监听改为tls，服务端证书从证书库按SNI选择
+	if clientCAs != nil {
+		clientAuth := tls.VerifyClientCertIfGiven
+		if serviceDetail.ClientAuth.Mode == dao.ClientAuthModeRequired {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			GetCertificate: dao.CertManagerHandler.GetTLSCertificate,
+			ClientAuth:     clientAuth,
+			ClientCAs:      clientCAs,
+		})))
+	}
+	s := grpc.NewServer(opts...)
+	grpcServer.Server = s
+
+	log.Printf(" [INFO] grpc_proxy_run %v\n", addr)
+	go func() {
+		if err := s.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			log.Printf(" [ERROR] grpc_proxy_run %v err:%v\n", addr, err)
+		}
+	}()
+	return grpcServer, nil
+}
+
+//依次执行stream中间件
+func grpcStreamChain(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, nextHandler := interceptors[i], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, nextHandler)
+			}
+		}
+		return next(srv, ss)
+	}
+}
+
+//等待端口释放后返回，进行中的rpc在后台排空
+func grpcServerShutdown(grpcServer *warpGrpcServer) {
+	go grpcServerDrain(grpcServer)
+	<-grpcServer.lis.closed
+	log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+}
+
+//优雅关闭，超过drain_timeout仍有进行中的rpc(如长连接stream)时强制关闭，之后关闭下游连接
+func grpcServerDrain(grpcServer *warpGrpcServer) {
+	drainTimeout := time.Duration(lib.GetIntConf("proxy.grpc.drain_timeout")) * time.Second
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultGrpcDrainTimeout
+	}
+	stopped := make(chan struct{})
+	go func() {
 		grpcServer.GracefulStop()
-		log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+		close(stopped)
+	}()
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		log.Printf(" [ERROR] grpc_proxy_drain %v err:drain timeout after %v\n", grpcServer.Addr, drainTimeout)
+		grpcServer.Stop()
+		<-stopped
+	}
+	reverse_proxy.CloseGrpcConns(grpcServer.tlsConfig)
+}
+
+func GrpcServerStop() {
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+	close(grpcConnPruneStop)
+	wg := sync.WaitGroup{}
+	for _, grpcServer := range grpcServerMap {
+		wg.Add(1)
+		go func(grpcServer *warpGrpcServer) {
+			defer wg.Done()
+			grpcServerDrain(grpcServer)
+			log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+		}(grpcServer)
 	}
+	wg.Wait()
 }
@@ -0,0 +1,92 @@
+package grpc_proxy_router
+
+import (
+	"context"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGrpcServerDrainTimeout(t *testing.T) {
+	v := viper.New()
+	v.Set("grpc.drain_timeout", 1)
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": v}
+	defer func() {
+		lib.ViperConfMap = nil
+	}()
+
+	//长连接stream不主动结束，超时后强制关闭
+	entered := make(chan struct{})
+	s := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		close(entered)
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := &grpcListener{Listener: ln, closed: make(chan struct{})}
+	go s.Serve(lis)
+	grpcServer := &warpGrpcServer{Addr: ln.Addr().String(), lis: lis, Server: s}
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/test.Echo/Stream"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("expect stream started")
+	}
+
+	start := time.Now()
+	grpcServerDrain(grpcServer)
+	if cost := time.Since(start); cost < time.Second || cost > 3*time.Second {
+		t.Fatalf("expect drain timeout, got %v", cost)
+	}
+	select {
+	case <-lis.closed:
+	default:
+		t.Fatal("expect listener closed")
+	}
+}
+
+func TestGrpcListenerJson(t *testing.T) {
+	newService := func(port int, roundType int, whiteList string) *dao.ServiceDetail {
+		return &dao.ServiceDetail{
+			Info:          &dao.ServiceInfo{ServiceName: "grpc_listener_test"},
+			GRPCRule:      &dao.GrpcRule{Port: port},
+			LoadBalance:   &dao.LoadBalance{RoundType: roundType},
+			AccessControl: &dao.AccessControl{WhiteList: whiteList},
+		}
+	}
+	base := grpcListenerJson(newService(8001, 0, ""))
+	//负载均衡、权限等配置变化不需要重新绑定
+	if grpcListenerJson(newService(8001, 2, "127.0.0.1")) != base {
+		t.Fatal("expect same listener conf")
+	}
+	if grpcListenerJson(newService(8002, 0, "")) == base {
+		t.Fatal("expect port change rebind")
+	}
+	withAuth := newService(8001, 0, "")
+	withAuth.ClientAuth = &dao.ClientAuth{Mode: dao.ClientAuthModeRequired}
+	if grpcListenerJson(withAuth) == base {
+		t.Fatal("expect client auth change rebind")
+	}
+	withTLS := newService(8001, 0, "")
+	withTLS.UpstreamTLS = &dao.UpstreamTLS{ClientKeyHash: "hash"}
+	if grpcListenerJson(withTLS) == base {
+		t.Fatal("expect upstream tls change rebind")
+	}
+}
@@ -9,7 +9,7 @@ import (
 //匹配接入方式 基于请求信息
 func HTTPAccessModeMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		service, err := dao.ServiceManagerHandler.HTTPAccessMode(c)
+		service, rule, err := dao.ServiceManagerHandler.HTTPAccessMode(c)
 		if err != nil {
 			middleware.ResponseError(c, 1001, err)
 			c.Abort()
@@ -17,6 +17,15 @@ func HTTPAccessModeMiddleware() gin.HandlerFunc {
 		}
 		//fmt.Println("matched service",public.Obj2Json(service))
 		c.Set("service", service)
+		c.Set("http_rule", rule)
 		c.Next()
 	}
 }
+
+//命中的接入规则，strip_uri及url重写使用
+func httpAccessRule(c *gin.Context, serviceDetail *dao.ServiceDetail) *dao.HttpRule {
+	if ruleInterface, ok := c.Get("http_rule"); ok {
+		return ruleInterface.(*dao.HttpRule)
+	}
+	return serviceDetail.HTTPRule
+}
@@ -0,0 +1,444 @@
+package http_proxy_middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheMaxBodySize       = 1024 * 1024
+	DefaultCacheRevalidateTimeout = 10 * time.Second
+	DefaultCacheLockTimeout       = 5 * time.Second
+)
+
+//响应缓存：只缓存GET请求的响应，HEAD请求使用GET的缓存，遵循Cache-Control/Expires/Vary
+//过期后在stale期内先返回旧内容并后台刷新，并发未命中时只有一个请求回源，等待超过lock_timeout后各自回源
+func HTTPCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rule := serviceDetail.HTTPRule
+		if rule.CacheEnable != 1 || !httpCacheableRequest(c.Request) || isStreamRequest(c) {
+			c.Next()
+			return
+		}
+		reqCacheControl := parseCacheControl(c.Request.Header)
+		if _, ok := reqCacheControl["no-store"]; ok {
+			c.Next()
+			return
+		}
+		//no-cache或max-age=0要求回源，回源结果仍可写入缓存
+		noCache := reqCacheControl["max-age"] == "0" || c.GetHeader("Pragma") == "no-cache"
+		if _, ok := reqCacheControl["no-cache"]; ok {
+			noCache = true
+		}
+
+		public.CachePurgeHandler.Start(time.Duration(lib.GetIntConf("proxy.cache.purge_sync_interval")) * time.Second)
+		store := public.CacheStoreHandler.GetStore(rule.CacheStore, int64(lib.GetIntConf("proxy.cache.memory_max_bytes")))
+		cacheKey := httpCacheKey(c, rule.CacheKey)
+		//灰度分组的响应分开缓存，分组名追加在末尾，按key前缀清除时同样生效
+		if groupName := serviceDetail.GroupName(); groupName != "" {
+			cacheKey += "|@" + groupName
+		}
+		key := public.CacheKey(serviceDetail.Info.ServiceName, cacheKey)
+		if !noCache && serveHTTPCache(c, store, key, serviceDetail) {
+			return
+		}
+		//HEAD未命中时直接回源，不参与回源合并也不写入缓存
+		if c.Request.Method != http.MethodGet {
+			c.Header("X-Cache", "MISS")
+			c.Next()
+			return
+		}
+		if wait, leader := httpCacheFlight.join(key); leader {
+			defer httpCacheFlight.done(key)
+		} else {
+			lockTimeout := time.Duration(lib.GetIntConf("proxy.cache.lock_timeout")) * time.Second
+			if lockTimeout <= 0 {
+				lockTimeout = DefaultCacheLockTimeout
+			}
+			timer := time.NewTimer(lockTimeout)
+			select {
+			case <-wait:
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+			timer.Stop()
+			//回源请求未写入缓存时各自回源
+			if !noCache && serveHTTPCache(c, store, key, serviceDetail) {
+				return
+			}
+		}
+
+		maxBodySize := lib.GetIntConf("proxy.cache.max_body_size")
+		if maxBodySize <= 0 {
+			maxBodySize = DefaultCacheMaxBodySize
+		}
+		writer := &httpCacheWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}, maxBodySize: maxBodySize}
+		c.Writer = writer
+		c.Header("X-Cache", "MISS")
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		//ResponseError输出的网关错误不缓存
+		if len(c.Errors) > 0 || writer.overflow || writer.header == nil {
+			return
+		}
+		storeHTTPCache(store, key, c.Request, rule, writer.Status(), writer.header, writer.body.Bytes())
+	}
+}
+
+func httpCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Header.Get("Upgrade") == ""
+}
+
+//按配置拼接缓存key，path及query取客户端原始请求，不受strip_uri及url重写影响
+//默认包含host，避免多域名及通配域名服务串用缓存
+func httpCacheKey(c *gin.Context, conf string) string {
+	if conf == "" {
+		conf = "host,path,query,method"
+	}
+	reqURL := c.Request.URL
+	if originURL, err := url.ParseRequestURI(c.Request.RequestURI); err == nil {
+		reqURL = originURL
+	}
+	parts := []string{}
+	for _, item := range strings.Split(conf, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "path":
+			parts = append(parts, reqURL.Path)
+		case item == "query":
+			parts = append(parts, reqURL.Query().Encode())
+		case item == "method":
+			//HEAD与GET共用缓存
+			method := c.Request.Method
+			if method == http.MethodHead {
+				method = http.MethodGet
+			}
+			parts = append(parts, method)
+		case item == "host":
+			parts = append(parts, strings.ToLower(c.Request.Host))
+		case strings.HasPrefix(item, "header:"):
+			parts = append(parts, c.GetHeader(item[len("header:"):]))
+		case strings.HasPrefix(item, "cookie:"):
+			value, _ := c.Cookie(item[len("cookie:"):])
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+//Vary响应按对应header值另存
+func httpCacheVaryKey(key string, vary []string, header http.Header) string {
+	parts := []string{key, "vary"}
+	for _, name := range vary {
+		parts = append(parts, strings.Join(header.Values(name), ","))
+	}
+	return strings.Join(parts, "|")
+}
+
+func lookupHTTPCache(store public.CacheStore, key string, req *http.Request) *public.CacheEntry {
+	entry, ok := store.Get(key)
+	if !ok {
+		return nil
+	}
+	if len(entry.VaryHeader) > 0 {
+		if entry, ok = store.Get(httpCacheVaryKey(key, entry.VaryHeader, req.Header)); !ok {
+			return nil
+		}
+	}
+	if time.Now().After(entry.StaleAt) || public.CachePurgeHandler.Purged(key, entry.StoredAt) {
+		return nil
+	}
+	return entry
+}
+
+//命中时输出缓存内容，已过期的内容触发后台刷新
+func serveHTTPCache(c *gin.Context, store public.CacheStore, key string, serviceDetail *dao.ServiceDetail) bool {
+	entry := lookupHTTPCache(store, key, c.Request)
+	if entry == nil {
+		return false
+	}
+	status := "HIT"
+	if time.Now().After(entry.FreshAt) {
+		status = "STALE"
+		revalidateHTTPCache(c, store, key, serviceDetail)
+	}
+	header := c.Writer.Header()
+	for name, values := range entry.Header {
+		header[name] = append([]string{}, values...)
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(entry.StoredAt)/time.Second)))
+	header.Set("X-Cache", status)
+	c.Writer.WriteHeader(entry.StatusCode)
+	if c.Request.Method != http.MethodHead {
+		c.Writer.Write(entry.Body)
+	}
+	c.Abort()
+	return true
+}
+
+//后台回源刷新缓存，同一key同时只刷新一次
+func revalidateHTTPCache(c *gin.Context, store public.CacheStore, key string, serviceDetail *dao.ServiceDetail) {
+	if _, leader := httpCacheFlight.join(key); !leader {
+		return
+	}
+	timeout := time.Duration(lib.GetIntConf("proxy.cache.revalidate_timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultCacheRevalidateTimeout
+	}
+	//请求上下文会被回收，需在返回前复制请求
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req := c.Request.Clone(ctx)
+	req.Method = http.MethodGet
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	go func() {
+		defer httpCacheFlight.done(key)
+		defer cancel()
+		recorder := httptest.NewRecorder()
+		bgCtx, _ := gin.CreateTestContext(recorder)
+		bgCtx.Request = req
+		bgCtx.Set("service", serviceDetail)
+		serveHTTPReverseProxy(bgCtx, serviceDetail, false)
+		if len(bgCtx.Errors) > 0 {
+			return
+		}
+		storeHTTPCache(store, key, req, serviceDetail.HTTPRule, recorder.Code, recorder.Header(), recorder.Body.Bytes())
+	}()
+}
+
+//按响应头计算有效期后写入缓存，不可缓存的响应直接忽略
+func storeHTTPCache(store public.CacheStore, key string, req *http.Request, rule *dao.HttpRule, statusCode int, header http.Header, body []byte) {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return
+	}
+	if header.Get("Set-Cookie") != "" {
+		return
+	}
+	cacheControl := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cacheControl[directive]; ok {
+			return
+		}
+	}
+	//带认证信息的请求只有显式允许共享缓存时才缓存
+	if req.Header.Get("Authorization") != "" {
+		_, isPublic := cacheControl["public"]
+		_, sMaxAge := cacheControl["s-maxage"]
+		if !isPublic && !sMaxAge {
+			return
+		}
+	}
+	vary := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "*" {
+				return
+			} else if name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+
+	now := time.Now()
+	ttl, ok := httpCacheLifetime(cacheControl, header, now)
+	if !ok {
+		ttl = time.Duration(rule.CacheTTL) * time.Second
+	}
+	if ttl <= 0 {
+		return
+	}
+	stale := time.Duration(rule.CacheStale) * time.Second
+	if value, ok := cacheControl["stale-while-revalidate"]; ok {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			stale = time.Duration(seconds) * time.Second
+		}
+	}
+	//保留时长不超过max_ttl，清除记录保留同样时长
+	maxTTL := public.CacheMaxTTL()
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	if ttl+stale > maxTTL {
+		stale = maxTTL - ttl
+	}
+	entryHeader := header.Clone()
+	entryHeader.Del("X-Cache")
+	entry := &public.CacheEntry{
+		StatusCode: statusCode,
+		Header:     entryHeader,
+		Body:       append([]byte{}, body...),
+		StoredAt:   now,
+		FreshAt:    now.Add(ttl),
+		StaleAt:    now.Add(ttl + stale),
+	}
+	if len(vary) > 0 {
+		store.Set(key, &public.CacheEntry{
+			VaryHeader: vary,
+			StoredAt:   entry.StoredAt,
+			FreshAt:    entry.FreshAt,
+			StaleAt:    entry.StaleAt,
+		})
+		key = httpCacheVaryKey(key, vary, req.Header)
+	}
+	store.Set(key, entry)
+}
+
+//有效期优先级 s-maxage > max-age > Expires，均未指定时返回false
+func httpCacheLifetime(cacheControl map[string]string, header http.Header, now time.Time) (time.Duration, bool) {
+	var age time.Duration
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil {
+		age = time.Duration(seconds) * time.Second
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := cacheControl[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, true
+			}
+			return time.Duration(seconds)*time.Second - age, true
+		}
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		//无法解析的Expires视为已过期
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, true
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		return expiresAt.Sub(date), true
+	}
+	return 0, false
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, item := range strings.Split(value, ",") {
+			items := strings.SplitN(strings.TrimSpace(item), "=", 2)
+			name := strings.ToLower(items[0])
+			if name == "" {
+				continue
+			}
+			directives[name] = ""
+			if len(items) == 2 {
+				directives[name] = strings.Trim(items[1], `"`)
+			}
+		}
+	}
+	return directives
+}
+
+//响应输出的同时保存响应体，超过上限时放弃缓存
+//header在写出前保存，不含网关按请求设置的响应头(如跨域header)
+type httpCacheWriter struct {
+	gin.ResponseWriter
+	header      http.Header
+	body        *bytes.Buffer
+	maxBodySize int
+	overflow    bool
+}
+
+//未声明为流式路由的SSE响应同样不缓存
+func (w *httpCacheWriter) snapshot() {
+	if w.header == nil {
+		w.header = w.Header().Clone()
+		if strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream") {
+			w.overflow = true
+		}
+	}
+}
+
+func (w *httpCacheWriter) WriteHeader(code int) {
+	w.snapshot()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *httpCacheWriter) WriteHeaderNow() {
+	w.snapshot()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *httpCacheWriter) Write(data []byte) (int, error) {
+	w.snapshot()
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *httpCacheWriter) WriteString(s string) (int, error) {
+	w.snapshot()
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *httpCacheWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > w.maxBodySize {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+var httpCacheFlight = &cacheFlight{calls: map[string]chan struct{}{}}
+
+//合并同一key的并发回源
+type cacheFlight struct {
+	calls  map[string]chan struct{}
+	locker sync.Mutex
+}
+
+//第一个请求成为回源者，其余返回等待回源结束的channel
+func (f *cacheFlight) join(key string) (<-chan struct{}, bool) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	if wait, ok := f.calls[key]; ok {
+		return wait, false
+	}
+	f.calls[key] = make(chan struct{})
+	return nil, true
+}
+
+func (f *cacheFlight) done(key string) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	if wait, ok := f.calls[key]; ok {
+		close(wait)
+		delete(f.calls, key)
+	}
+}
@@ -0,0 +1,338 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	header := http.Header{}
+	header.Add("Cache-Control", `public, Max-Age=60, no-cache="Set-Cookie"`)
+	header.Add("Cache-Control", "s-maxage=120,,stale-while-revalidate=30")
+	directives := parseCacheControl(header)
+	expect := map[string]string{
+		"public":                 "",
+		"max-age":                "60",
+		"no-cache":               "Set-Cookie",
+		"s-maxage":               "120",
+		"stale-while-revalidate": "30",
+	}
+	if len(directives) != len(expect) {
+		t.Fatalf("expect %v, got %v", expect, directives)
+	}
+	for name, value := range expect {
+		if got, ok := directives[name]; !ok || got != value {
+			t.Errorf("directive %s: expect %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestHttpCacheLifetime(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	httpTime := func(t time.Time) string {
+		return t.Format(http.TimeFormat)
+	}
+	cases := []struct {
+		name   string
+		header map[string]string
+		ttl    time.Duration
+		ok     bool
+	}{
+		{"none", map[string]string{}, 0, false},
+		{"max-age", map[string]string{"Cache-Control": "max-age=60"}, 60 * time.Second, true},
+		{"s-maxage first", map[string]string{"Cache-Control": "max-age=60, s-maxage=120"}, 120 * time.Second, true},
+		{"max-age minus age", map[string]string{"Cache-Control": "max-age=60", "Age": "20"}, 40 * time.Second, true},
+		{"bad max-age", map[string]string{"Cache-Control": "max-age=abc"}, 0, true},
+		{"max-age before expires", map[string]string{"Cache-Control": "max-age=60", "Expires": httpTime(now.Add(time.Hour))}, 60 * time.Second, true},
+		{"expires with date", map[string]string{"Expires": httpTime(now.Add(time.Hour)), "Date": httpTime(now.Add(-time.Hour))}, 2 * time.Hour, true},
+		{"expires without date", map[string]string{"Expires": httpTime(now.Add(time.Hour))}, time.Hour, true},
+		{"bad expires", map[string]string{"Expires": "0"}, 0, true},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		for name, value := range c.header {
+			header.Set(name, value)
+		}
+		ttl, ok := httpCacheLifetime(parseCacheControl(header), header, now)
+		if ttl != c.ttl || ok != c.ok {
+			t.Errorf("%s: expect %v %v, got %v %v", c.name, c.ttl, c.ok, ttl, ok)
+		}
+	}
+}
+
+func TestHttpCacheKey(t *testing.T) {
+	cacheKey := func(method, target, conf string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(method, target, nil)
+		c.Request.Header.Set("Accept-Language", "zh")
+		c.Request.AddCookie(&http.Cookie{Name: "uid", Value: "1"})
+		return httpCacheKey(c, conf)
+	}
+	cases := []struct {
+		name   string
+		method string
+		target string
+		conf   string
+		expect string
+	}{
+		{"default", "GET", "http://a.example.com/api?b=2&a=1", "", "a.example.com|/api|a=1&b=2|GET"},
+		{"default host case", "GET", "http://A.Example.com/api", "", "a.example.com|/api||GET"},
+		{"path only", "HEAD", "http://a.example.com/api?a=1", "path", "/api"},
+		{"head uses get", "HEAD", "http://a.example.com/api", "", "a.example.com|/api||GET"},
+		{"header and cookie", "GET", "http://a.example.com/api", "path, header:Accept-Language,cookie:uid", "/api|zh|1"},
+	}
+	for _, c := range cases {
+		if key := cacheKey(c.method, c.target, c.conf); key != c.expect {
+			t.Errorf("%s: expect %q, got %q", c.name, c.expect, key)
+		}
+	}
+	if cacheKey("GET", "http://a.example.com/api", "") == cacheKey("GET", "http://b.example.com/api", "") {
+		t.Fatal("expect different key for different host")
+	}
+}
+
+func setCacheConf(maxTTL int) func() {
+	v := viper.New()
+	v.Set("cache.max_ttl", maxTTL)
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": v}
+	return func() {
+		lib.ViperConfMap = nil
+	}
+}
+
+func TestStoreHTTPCache(t *testing.T) {
+	defer setCacheConf(100)()
+	rule := &dao.HttpRule{CacheTTL: 60}
+	cases := []struct {
+		name      string
+		status    int
+		header    map[string]string
+		reqHeader map[string]string
+		stored    bool
+	}{
+		{"default ttl", 200, map[string]string{}, nil, true},
+		{"not cacheable status", 500, map[string]string{}, nil, false},
+		{"set cookie", 200, map[string]string{"Set-Cookie": "uid=1"}, nil, false},
+		{"no store", 200, map[string]string{"Cache-Control": "no-store"}, nil, false},
+		{"private", 200, map[string]string{"Cache-Control": "private, max-age=60"}, nil, false},
+		{"expired", 200, map[string]string{"Cache-Control": "max-age=0"}, nil, false},
+		{"vary all", 200, map[string]string{"Vary": "*"}, nil, false},
+		{"authorization", 200, map[string]string{"Cache-Control": "max-age=60"}, map[string]string{"Authorization": "Bearer x"}, false},
+		{"authorization public", 200, map[string]string{"Cache-Control": "public, max-age=60"}, map[string]string{"Authorization": "Bearer x"}, true},
+	}
+	for _, c := range cases {
+		store := public.NewMemoryCacheStore(0)
+		header := http.Header{}
+		for name, value := range c.header {
+			header.Set(name, value)
+		}
+		req := httptest.NewRequest("GET", "http://a.example.com/api", nil)
+		for name, value := range c.reqHeader {
+			req.Header.Set(name, value)
+		}
+		storeHTTPCache(store, "key", req, rule, c.status, header, []byte("body"))
+		if entry := lookupHTTPCache(store, "key", req); (entry != nil) != c.stored {
+			t.Errorf("%s: expect stored=%v", c.name, c.stored)
+		}
+	}
+
+	//保留时长不超过max_ttl，超出部分先扣减stale时间
+	store := public.NewMemoryCacheStore(0)
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=80, stale-while-revalidate=60")
+	req := httptest.NewRequest("GET", "http://a.example.com/api", nil)
+	storeHTTPCache(store, "key", req, rule, 200, header, []byte("body"))
+	entry := lookupHTTPCache(store, "key", req)
+	if entry == nil || entry.FreshAt.Sub(entry.StoredAt) != 80*time.Second || entry.StaleAt.Sub(entry.StoredAt) != 100*time.Second {
+		t.Fatalf("expect stale capped by max ttl, got %+v", entry)
+	}
+	header.Set("Cache-Control", "max-age=3600")
+	storeHTTPCache(store, "key", req, rule, 200, header, []byte("body"))
+	entry = lookupHTTPCache(store, "key", req)
+	if entry == nil || entry.FreshAt.Sub(entry.StoredAt) != 100*time.Second || !entry.StaleAt.Equal(entry.FreshAt) {
+		t.Fatalf("expect ttl capped by max ttl, got %+v", entry)
+	}
+}
+
+//HEAD请求命中GET写入的缓存，只返回header
+func TestHTTPCacheHead(t *testing.T) {
+	defer setCacheConf(0)()
+	serviceDetail := &dao.ServiceDetail{
+		Info:     &dao.ServiceInfo{ServiceName: "cache_head_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)},
+		HTTPRule: &dao.HttpRule{CacheEnable: 1, CacheTTL: 60},
+	}
+	upstreamCount := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+	}, HTTPCacheMiddleware())
+	handler := func(c *gin.Context) {
+		upstreamCount++
+		if c.Request.Method == http.MethodHead {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	}
+	router.GET("/head", handler)
+	router.HEAD("/head", handler)
+
+	for _, item := range []struct {
+		method string
+		status string
+		body   string
+	}{
+		{"HEAD", "MISS", ""},
+		{"GET", "MISS", "ok"},
+		{"HEAD", "HIT", ""},
+		{"GET", "HIT", "ok"},
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(item.method, "/head", nil))
+		if recorder.Header().Get("X-Cache") != item.status || recorder.Body.String() != item.body {
+			t.Fatalf("%s: expect %s %q, got %s %q", item.method, item.status, item.body, recorder.Header().Get("X-Cache"), recorder.Body.String())
+		}
+	}
+	if upstreamCount != 2 {
+		t.Fatalf("expect 2 upstream requests, got %d", upstreamCount)
+	}
+}
+
+//灰度分组与默认节点的响应分开缓存
+func TestHTTPCacheUpstreamGroup(t *testing.T) {
+	defer setCacheConf(0)()
+	serviceDetail := &dao.ServiceDetail{
+		Info:           &dao.ServiceInfo{ServiceName: "cache_group_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)},
+		HTTPRule:       &dao.HttpRule{CacheEnable: 1, CacheTTL: 60},
+		LoadBalance:    &dao.LoadBalance{},
+		UpstreamGroups: []*dao.UpstreamGroup{{GroupName: "canary"}},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Canary") != "" {
+			c.Set("service", serviceDetail.ForGroup(serviceDetail.UpstreamGroups[0]))
+			return
+		}
+		c.Set("service", serviceDetail)
+	}, HTTPCacheMiddleware())
+	router.GET("/group", func(c *gin.Context) {
+		groupDetail := c.MustGet("service").(*dao.ServiceDetail)
+		c.String(http.StatusOK, "upstream:"+groupDetail.GroupName())
+	})
+
+	for _, item := range []struct {
+		canary bool
+		status string
+		body   string
+	}{
+		{false, "MISS", "upstream:"},
+		{true, "MISS", "upstream:canary"},
+		{false, "HIT", "upstream:"},
+		{true, "HIT", "upstream:canary"},
+	} {
+		req := httptest.NewRequest("GET", "/group", nil)
+		if item.canary {
+			req.Header.Set("X-Canary", "1")
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if recorder.Header().Get("X-Cache") != item.status || recorder.Body.String() != item.body {
+			t.Fatalf("canary=%v: expect %s %q, got %s %q", item.canary, item.status, item.body, recorder.Header().Get("X-Cache"), recorder.Body.String())
+		}
+	}
+}
+
+func TestHTTPCacheVary(t *testing.T) {
+	defer setCacheConf(0)()
+	store := public.NewMemoryCacheStore(0)
+	rule := &dao.HttpRule{}
+	request := func(encoding, language string) *http.Request {
+		req := httptest.NewRequest("GET", "http://a.example.com/api", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		req.Header.Set("Accept-Language", language)
+		return req
+	}
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=60")
+	header.Set("Vary", "accept-encoding, Accept-Language")
+	storeHTTPCache(store, "key", request("gzip", "zh"), rule, 200, header, []byte("gzip zh"))
+	storeHTTPCache(store, "key", request("br", "zh"), rule, 200, header, []byte("br zh"))
+
+	cases := []struct {
+		encoding string
+		language string
+		body     string
+	}{
+		{"gzip", "zh", "gzip zh"},
+		{"br", "zh", "br zh"},
+		{"gzip", "en", ""},
+		{"", "zh", ""},
+	}
+	for _, c := range cases {
+		entry := lookupHTTPCache(store, "key", request(c.encoding, c.language))
+		body := ""
+		if entry != nil {
+			body = string(entry.Body)
+		}
+		if body != c.body {
+			t.Errorf("%s %s: expect %q, got %q", c.encoding, c.language, c.body, body)
+		}
+	}
+}
+
+func TestHTTPCacheLockTimeout(t *testing.T) {
+	v := viper.New()
+	v.Set("cache.lock_timeout", 1)
+	v.Set("cache.max_ttl", 0)
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": v}
+	defer func() {
+		lib.ViperConfMap = nil
+	}()
+
+	serviceDetail := &dao.ServiceDetail{
+		Info:     &dao.ServiceInfo{ServiceName: "cache_lock_test"},
+		HTTPRule: &dao.HttpRule{CacheEnable: 1, CacheTTL: 60},
+	}
+	release := make(chan struct{})
+	leaderDone := make(chan struct{})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+	}, HTTPCacheMiddleware())
+	router.GET("/slow", func(c *gin.Context) {
+		if c.GetHeader("X-Leader") != "" {
+			<-release
+		}
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusOK, "ok")
+	})
+
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest("GET", "/slow", nil)
+		req.Header.Set("X-Leader", "1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	//回源请求未结束，等待超时后自行回源
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow", nil))
+	close(release)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expect miss, got %v %v", recorder.Code, recorder.Header().Get("X-Cache"))
+	}
+	if cost := time.Since(start); cost < time.Second || cost > 3*time.Second {
+		t.Fatalf("expect wait lock timeout, got %v", cost)
+	}
+	<-leaderDone
+}
@@ -0,0 +1,47 @@
+package http_proxy_middleware
+
+import (
+	"encoding/json"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+//服务熔断时直接返回降级内容，不再转发到下游
+func HTTPCircuitBreakerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		breaker, err := dao.LoadBalancerHandler.GetCircuitBreaker(serviceDetail)
+		if err != nil {
+			middleware.ResponseError(c, 2002, err)
+			c.Abort()
+			return
+		}
+		if breaker != nil && !breaker.Allow() {
+			code := serviceDetail.LoadBalance.BreakerFallbackCode
+			if code == 0 {
+				code = http.StatusServiceUnavailable
+			}
+			body := serviceDetail.LoadBalance.BreakerFallbackBody
+			if body == "" {
+				body = "service circuit breaker open"
+			}
+			contentType := "text/plain; charset=utf-8"
+			if json.Valid([]byte(body)) {
+				contentType = "application/json; charset=utf-8"
+			}
+			c.Data(code, contentType, []byte(body))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
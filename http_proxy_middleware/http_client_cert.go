@@ -0,0 +1,52 @@
+package http_proxy_middleware
+
+import (
+	"crypto/x509"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+//客户端证书认证：https握手时请求客户端证书，按服务配置的CA及证书标识校验，证书标识映射为租户
+func HTTPClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if !serviceDetail.ClientAuth.Enabled() {
+			c.Next()
+			return
+		}
+		var certs []*x509.Certificate
+		if c.Request.TLS != nil {
+			certs = c.Request.TLS.PeerCertificates
+		}
+		if len(certs) == 0 {
+			if serviceDetail.ClientAuth.Mode == dao.ClientAuthModeRequired {
+				middleware.ResponseError(c, 2002, errors.New("client certificate required"))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		//可选模式下携带了证书也必须校验通过
+		identities, err := dao.ClientAuthHandler.Verify(serviceDetail, certs)
+		if err != nil {
+			middleware.ResponseError(c, 2003, err)
+			c.Abort()
+			return
+		}
+		//只有服务允许规则中的标识才映射租户，且租户需绑定该服务
+		allowed := serviceDetail.ClientAuth.AllowedIdentities(identities)
+		if appInfo := dao.AppManagerHandler.GetAppByCertIdentity(serviceDetail.Info.ServiceName, allowed); appInfo != nil {
+			c.Set("app", appInfo)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,327 @@
+package http_proxy_middleware
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCompressMinSize       = 1024
+	DefaultCompressGzipLevel     = gzip.DefaultCompression
+	DefaultCompressBrotliLevel   = 4
+	DefaultDecompressMaxBodySize = 10 * 1024 * 1024
+	DefaultCompressTypes         = "text/html,text/plain,text/css,text/xml,text/javascript,application/json,application/javascript,application/xml"
+)
+
+//响应压缩：按Accept-Encoding协商br或gzip，已编码、升级连接及流式响应不压缩
+//另可为不支持压缩请求体的下游解压客户端发送的gzip/br请求体
+func HTTPCompressMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rule := serviceDetail.HTTPRule
+		if rule.DecompressRequest == 1 {
+			if err := decompressRequestBody(c); err != nil {
+				middleware.ResponseError(c, 2002, err)
+				c.Abort()
+				return
+			}
+		}
+		if rule.CompressEnable != 1 || c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || isStreamRequest(c) {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		minSize := rule.CompressMinSize
+		if minSize == 0 {
+			minSize = lib.GetIntConf("proxy.compress.min_size")
+		}
+		if minSize <= 0 {
+			minSize = DefaultCompressMinSize
+		}
+		types := rule.CompressTypes
+		if types == "" {
+			types = lib.GetStringConf("proxy.compress.types")
+		}
+		if types == "" {
+			types = DefaultCompressTypes
+		}
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			minSize:        minSize,
+			types:          strings.Split(types, ","),
+		}
+		c.Writer = writer
+		c.Next()
+		writer.close()
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+//优先br，其次gzip，q=0表示不接受
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		items := strings.Split(strings.TrimSpace(item), ";")
+		name := strings.ToLower(strings.TrimSpace(items[0]))
+		q := 1.0
+		for _, param := range items[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, encoding := range []string{"br", "gzip"} {
+		if enabled, ok := accepted[encoding]; ok {
+			if enabled {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+//请求体解压后长度未知，超过上限时读取报错
+func decompressRequestBody(c *gin.Context) error {
+	encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	var reader io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		reader = gr
+	case "br":
+		reader = brotli.NewReader(c.Request.Body)
+	default:
+		return nil
+	}
+	maxSize := int64(lib.GetIntConf("proxy.compress.max_request_body_size"))
+	if maxSize <= 0 {
+		maxSize = DefaultDecompressMaxBodySize
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, &decompressBody{Reader: reader, Closer: c.Request.Body}, maxSize)
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Del("Content-Length")
+	c.Request.ContentLength = -1
+	return nil
+}
+
+type decompressBody struct {
+	io.Reader
+	io.Closer
+}
+
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+//写出响应体时才决定是否压缩：长度未知的响应先缓冲到最小压缩大小
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	types    []string
+	status   int
+	decided  bool
+	buf      []byte
+	encoder  compressEncoder
+}
+
+func (w *compressWriter) eligible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch {
+	case w.status != 0 && w.status < http.StatusOK,
+		w.status == http.StatusNoContent,
+		w.status == http.StatusPartialContent,
+		w.status == http.StatusNotModified:
+		return false
+	}
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if mediaType == "" || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, item := range w.types {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == mediaType || (strings.HasSuffix(item, "/*") && strings.HasPrefix(mediaType, item[:len(item)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) passthrough() error {
+	w.decided = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.ResponseWriter.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *compressWriter) start() error {
+	w.decided = true
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	header.Add("Vary", "Accept-Encoding")
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.encoding == "br" {
+		level := lib.GetIntConf("proxy.compress.brotli_level")
+		if level <= 0 {
+			level = DefaultCompressBrotliLevel
+		}
+		w.encoder = brotli.NewWriterLevel(w.ResponseWriter, level)
+	} else {
+		level := lib.GetIntConf("proxy.compress.gzip_level")
+		if level <= 0 {
+			level = DefaultCompressGzipLevel
+		}
+		encoder, err := gzip.NewWriterLevel(w.ResponseWriter, level)
+		if err != nil {
+			return err
+		}
+		w.encoder = encoder
+	}
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.encoder.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.passthrough()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if !w.eligible() {
+			if err := w.passthrough(); err != nil {
+				return 0, err
+			}
+			return w.ResponseWriter.Write(data)
+		}
+		if length, err := strconv.Atoi(w.Header().Get("Content-Length")); err == nil {
+			if length < w.minSize {
+				if err := w.passthrough(); err != nil {
+					return 0, err
+				}
+				return w.ResponseWriter.Write(data)
+			}
+			if err := w.start(); err != nil {
+				return 0, err
+			}
+			return w.encoder.Write(data)
+		}
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+//长度未知的响应反向代理每次写入后都会flush，此时按已缓冲内容决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		if w.eligible() {
+			w.start()
+		} else {
+			w.passthrough()
+		}
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.passthrough()
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
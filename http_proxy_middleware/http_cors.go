@@ -0,0 +1,86 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+//网关统一处理的跨域响应头，下游返回的同名header会被覆盖
+var corsResponseHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Expose-Headers",
+}
+
+//跨域策略：预检请求由网关直接应答，普通请求在响应头写出前设置跨域header
+func HTTPCorsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		cors := serviceDetail.Cors
+		origin := c.GetHeader("Origin")
+		if cors == nil || cors.Enable != 1 || origin == "" {
+			c.Next()
+			return
+		}
+		allowed := cors.AllowOrigin(origin)
+
+		requestMethod := c.GetHeader("Access-Control-Request-Method")
+		if c.Request.Method == http.MethodOptions && requestMethod != "" {
+			requestHeaders := c.GetHeader("Access-Control-Request-Headers")
+			if !allowed || !cors.AllowMethod(requestMethod) || !cors.AllowHeader(requestHeaders) {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			header := c.Writer.Header()
+			setCorsHeader(header, cors, origin)
+			header.Set("Access-Control-Allow-Methods", requestMethod)
+			if requestHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", requestHeaders)
+			}
+			if cors.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+			}
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		addResponseHeaderHook(c, func(header http.Header) {
+			for _, name := range corsResponseHeaders {
+				header.Del(name)
+			}
+			if !allowed {
+				return
+			}
+			setCorsHeader(header, cors, origin)
+			if cors.ExposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", cors.ExposeHeaders)
+			}
+		})
+		c.Next()
+	}
+}
+
+//允许携带cookie时不能返回*，需回显请求来源
+func setCorsHeader(header http.Header, cors *dao.CorsPolicy, origin string) {
+	if cors.AllowOrigins == "*" && cors.AllowCredentials != 1 {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+	if cors.AllowCredentials == 1 {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
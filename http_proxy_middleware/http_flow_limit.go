@@ -35,7 +35,8 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 		}
 
 		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
-			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+			clientLimiter, err := public.FlowLimiterHandler.GetOwnedLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+c.ClientIP(),
 				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
 			if err != nil {
@@ -3,8 +3,10 @@ package http_proxy_middleware
 import (
 	"github.com/e421083458/go_gateway/dao"
 	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/public"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"net/http"
 	"strings"
 )
 
@@ -30,6 +32,72 @@ func HTTPHeaderTransferMiddleware() gin.HandlerFunc {
 				c.Request.Header.Del(items[1])
 			}
 		}
+		//结构化header规则，按添加顺序执行，是响应header的唯一改写方式
+		//响应规则在上游响应改写(状态码映射、响应体改写)之后、响应头写出前执行，缓存命中时同样生效
+		responseRules := []*dao.HeaderRule{}
+		for _, rule := range serviceDetail.HeaderRules {
+			if rule.Direction == public.HeaderRuleResponse {
+				responseRules = append(responseRules, rule)
+				continue
+			}
+			applyHeaderRule(c, c.Request.Header, rule)
+		}
+		if len(responseRules) > 0 {
+			addResponseHeaderHook(c, func(header http.Header) {
+				for _, rule := range responseRules {
+					applyHeaderRule(c, header, rule)
+				}
+			})
+		}
 		c.Next()
 	}
 }
+
+func applyHeaderRule(c *gin.Context, header http.Header, rule *dao.HeaderRule) {
+	switch rule.Op {
+	case "add":
+		header.Add(rule.HeaderName, headerVariable(c, rule.HeaderValue))
+	case "set":
+		header.Set(rule.HeaderName, headerVariable(c, rule.HeaderValue))
+	case "del":
+		header.Del(rule.HeaderName)
+	case "rename":
+		if values := header.Values(rule.HeaderName); len(values) > 0 {
+			header.Del(rule.HeaderName)
+			header[http.CanonicalHeaderKey(rule.HeaderValue)] = values
+		}
+	}
+}
+
+//替换header值中的变量：${client_ip}、${trace_id}、${app_id}、${service_name}、${host}、${request_uri}
+func headerVariable(c *gin.Context, value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	appID := ""
+	if appInterface, ok := c.Get("app"); ok {
+		appID = appInterface.(*dao.App).AppID
+	}
+	serviceName := ""
+	if serverInterface, ok := c.Get("service"); ok {
+		serviceName = serverInterface.(*dao.ServiceDetail).Info.ServiceName
+	}
+	return strings.NewReplacer(
+		"${client_ip}", c.ClientIP(),
+		"${trace_id}", httpTraceID(c),
+		"${app_id}", appID,
+		"${service_name}", serviceName,
+		"${host}", c.Request.Host,
+		"${request_uri}", c.Request.RequestURI,
+	).Replace(value)
+}
+
+//优先沿用上游传入的trace id，同一请求内保持一致
+func httpTraceID(c *gin.Context) string {
+	if traceID := c.GetHeader("didi-header-rid"); traceID != "" {
+		return traceID
+	}
+	trace := public.GetGinTraceContext(c)
+	c.Set("trace", trace)
+	return trace.TraceId
+}
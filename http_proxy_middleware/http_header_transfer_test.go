@@ -0,0 +1,49 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHeaderTransferMiddleware(t *testing.T) {
+	serviceDetail := &dao.ServiceDetail{
+		Info:     &dao.ServiceInfo{ServiceName: "header_rule_test"},
+		HTTPRule: &dao.HttpRule{},
+		HeaderRules: []*dao.HeaderRule{
+			{Direction: public.HeaderRuleRequest, Op: "set", HeaderName: "X-Service", HeaderValue: "${service_name}"},
+			{Direction: public.HeaderRuleRequest, Op: "del", HeaderName: "X-Debug"},
+			{Direction: public.HeaderRuleResponse, Op: "set", HeaderName: "Cache-Control", HeaderValue: "no-cache, no-store"},
+			{Direction: public.HeaderRuleResponse, Op: "del", HeaderName: "Server"},
+			{Direction: public.HeaderRuleResponse, Op: "add", HeaderName: "X-A", HeaderValue: "1, 2"},
+			{Direction: public.HeaderRuleResponse, Op: "rename", HeaderName: "X-A", HeaderValue: "X-B"},
+		},
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("service", serviceDetail)
+	}, HTTPHeaderTransferMiddleware())
+	router.GET("/", func(c *gin.Context) {
+		if c.GetHeader("X-Service") != "header_rule_test" || c.GetHeader("X-Debug") != "" {
+			t.Errorf("unexpected request header %v", c.Request.Header)
+		}
+		//上游响应头，header规则在响应头写出前执行
+		c.Header("Server", "nginx")
+		c.Header("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Debug", "1")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	header := recorder.Header()
+	//header值中的逗号原样保留，规则按添加顺序执行
+	if header.Get("Cache-Control") != "no-cache, no-store" || header.Get("Server") != "" ||
+		header.Get("X-A") != "" || header.Get("X-B") != "1, 2" {
+		t.Fatalf("unexpected response header %v", header)
+	}
+}
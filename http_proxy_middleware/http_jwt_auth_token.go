@@ -26,8 +26,9 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 		// appInfo 放到 gin.context
 		token:=strings.ReplaceAll(c.GetHeader("Authorization"),"Bearer ","")
 		//fmt.Println("token",token)
-		appMatched:=false
-		if token!=""{
+		//已通过客户端证书映射租户时不再解析token
+		_,appMatched:=c.Get("app")
+		if token!="" && !appMatched{
 			claims,err:=public.JwtDecode(token)
 			if err!=nil{
 				middleware.ResponseError(c, 2002, err)
@@ -18,7 +18,8 @@ func HTTPJwtFlowLimitMiddleware() gin.HandlerFunc {
 		}
 		appInfo := appInterface.(*dao.App)
 		if appInfo.Qps > 0 {
-			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+			clientLimiter, err := public.FlowLimiterHandler.GetOwnedLimiter(
+				public.FlowAppPrefix+appInfo.AppID,
 				public.FlowAppPrefix+appInfo.AppID+"_"+c.ClientIP(),
 				float64(appInfo.Qps))
 			if err != nil {
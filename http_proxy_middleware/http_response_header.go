@@ -0,0 +1,58 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+//响应头写出前依次执行回调，用于覆盖下游返回的header(缓存命中时同样生效)
+type responseHeaderWriter struct {
+	gin.ResponseWriter
+	hooks []func(header http.Header)
+	done  bool
+}
+
+func (w *responseHeaderWriter) before() {
+	if w.done {
+		return
+	}
+	w.done = true
+	for _, hook := range w.hooks {
+		hook(w.Header())
+	}
+}
+
+func (w *responseHeaderWriter) WriteHeader(code int) {
+	w.before()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseHeaderWriter) WriteHeaderNow() {
+	w.before()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseHeaderWriter) Write(data []byte) (int, error) {
+	w.before()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseHeaderWriter) WriteString(s string) (int, error) {
+	w.before()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseHeaderWriter) Flush() {
+	w.before()
+	w.ResponseWriter.Flush()
+}
+
+//注册响应头回调，同一请求只包装一次Writer
+func addResponseHeaderHook(c *gin.Context, hook func(header http.Header)) {
+	writer, ok := c.Writer.(*responseHeaderWriter)
+	if !ok {
+		writer = &responseHeaderWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+	}
+	writer.hooks = append(writer.hooks, hook)
+}
@@ -0,0 +1,59 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/reverse_proxy"
+	"sync"
+)
+
+var ResponseTransformHandler *ResponseTransformManager
+
+//按服务名缓存解析后的响应改写规则，reload后清空重新解析
+type ResponseTransformManager struct {
+	TransformMap map[string]*reverse_proxy.ResponseTransform
+	Locker       sync.RWMutex
+}
+
+func NewResponseTransformManager() *ResponseTransformManager {
+	return &ResponseTransformManager{
+		TransformMap: map[string]*reverse_proxy.ResponseTransform{},
+		Locker:       sync.RWMutex{},
+	}
+}
+
+func init() {
+	ResponseTransformHandler = NewResponseTransformManager()
+	dao.ServiceManagerHandler.Attach(ResponseTransformHandler)
+}
+
+//未配置响应改写时返回nil
+func (m *ResponseTransformManager) GetTransform(service *dao.ServiceDetail) (*reverse_proxy.ResponseTransform, error) {
+	m.Locker.RLock()
+	transform, ok := m.TransformMap[service.Info.ServiceName]
+	m.Locker.RUnlock()
+	if ok {
+		return transform, nil
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if transform, ok := m.TransformMap[service.Info.ServiceName]; ok {
+		return transform, nil
+	}
+	httpRule := service.HTTPRule
+	transform, err := reverse_proxy.NewResponseTransform(httpRule.RespStatusMap, httpRule.RespJsonMask,
+		httpRule.RespBodyReplace, int64(lib.GetIntConf("proxy.transform.max_buffer_size")))
+	if err != nil {
+		return nil, err
+	}
+	m.TransformMap[service.Info.ServiceName] = transform
+	return transform, nil
+}
+
+//服务reload后清空，已删除的服务不再保留
+func (m *ResponseTransformManager) Update() {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	m.TransformMap = map[string]*reverse_proxy.ResponseTransform{}
+}
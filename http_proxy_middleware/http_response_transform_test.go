@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func TestResponseTransformManager(t *testing.T) {
+	newService := func(jsonMask string) *dao.ServiceDetail {
+		return &dao.ServiceDetail{
+			Info:     &dao.ServiceInfo{ServiceName: "transform_test"},
+			HTTPRule: &dao.HttpRule{RespJsonMask: jsonMask},
+		}
+	}
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": viper.New()}
+	defer func() {
+		lib.ViperConfMap = nil
+	}()
+	manager := NewResponseTransformManager()
+	service := newService("$.password")
+	transform, err := manager.GetTransform(service)
+	if err != nil || transform == nil {
+		t.Fatalf("expect transform, got %v", err)
+	}
+	if cached, _ := manager.GetTransform(service); cached != transform {
+		t.Fatal("expect cached transform")
+	}
+
+	//分组副本等同名服务对象共用缓存
+	copied := *service
+	if cached, _ := manager.GetTransform(&copied); cached != transform {
+		t.Fatal("expect cached transform for service copy")
+	}
+
+	//reload后清空，按新配置重新解析
+	manager.Update()
+	if len(manager.TransformMap) != 0 {
+		t.Fatal("expect empty after update")
+	}
+	if _, err := manager.GetTransform(newService("$.password[")); err == nil {
+		t.Fatal("expect error with reloaded conf")
+	}
+	if transform, err := manager.GetTransform(newService("")); transform != nil || err != nil {
+		t.Fatal("expect nil transform with reloaded conf")
+	}
+	if cached, ok := manager.TransformMap["transform_test"]; !ok || cached != nil {
+		t.Fatal("expect nil transform cached")
+	}
+}
@@ -2,10 +2,14 @@ package http_proxy_middleware
 
 import (
 	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
 	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/public"
 	"github.com/e421083458/go_gateway/reverse_proxy"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"strings"
+	"time"
 )
 
 //匹配接入方式 基于请求信息
@@ -18,26 +22,109 @@ func HTTPReverseProxyMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		serveHTTPReverseProxy(c, serviceDetail, true)
+		c.Abort()
+		return
+	}
+}
 
-		lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+//转发请求到服务下游，mirror为false时不做流量镜像(如缓存后台刷新)
+func serveHTTPReverseProxy(c *gin.Context, serviceDetail *dao.ServiceDetail, mirror bool) {
+	lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		c.Abort()
+		return
+	}
+	fb, err := dao.LoadBalancerHandler.GetFeedback(serviceDetail)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		c.Abort()
+		return
+	}
+	var sticky *reverse_proxy.StickySession
+	if serviceDetail.LoadBalance.StickySession == 1 {
+		lbConf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(serviceDetail)
 		if err != nil {
 			middleware.ResponseError(c, 2002, err)
 			c.Abort()
 			return
 		}
-		trans, err := dao.TransportorHandler.GetTrans(serviceDetail)
-		if err != nil {
-			middleware.ResponseError(c, 2003, err)
-			c.Abort()
-			return
+		cookiePath := ""
+		if rule := httpAccessRule(c, serviceDetail); rule.RuleType == public.HTTPRuleTypePrefixURL {
+			cookiePath = rule.Rule
 		}
-		//middleware.ResponseSuccess(c,"ok")
-		//return
-		//创建 reverseproxy
-		//使用 reverseproxy.ServerHTTP(c.Request,c.Response)
-		proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans)
-		proxy.ServeHTTP(c.Writer, c.Request)
+		sticky = reverse_proxy.NewStickySession(serviceDetail.LoadBalance.StickyCookie, serviceDetail.Info.ServiceName, cookiePath, lbConf)
+	}
+	var retry *reverse_proxy.RetryConf
+	if serviceDetail.LoadBalance.RetryNum > 0 {
+		budget := public.RetryBudgetHandler.GetBudget(serviceDetail.Info.ServiceName,
+			lib.GetFloat64Conf("proxy.retry.budget_ratio"), lib.GetFloat64Conf("proxy.retry.budget_min_per_sec"))
+		retry = reverse_proxy.NewRetryConf(serviceDetail.LoadBalance.RetryNum, serviceDetail.LoadBalance.RetryOn,
+			serviceDetail.LoadBalance.RetryNonIdempotent == 1, int64(lib.GetIntConf("proxy.retry.max_body_size")), budget)
+	}
+	trans, err := dao.TransportorHandler.GetTrans(serviceDetail)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		c.Abort()
+		return
+	}
+	transform, err := ResponseTransformHandler.GetTransform(serviceDetail)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
 		c.Abort()
 		return
 	}
+	//流量镜像在主请求转发前复制请求，镜像耗时不计入主请求
+	if mirror {
+		mirrorScheme := "http"
+		if serviceDetail.HTTPRule.NeedHttps == 1 {
+			mirrorScheme = "https"
+		}
+		mirrorConf := reverse_proxy.NewMirrorConf(serviceDetail.HTTPRule.MirrorTarget, serviceDetail.HTTPRule.MirrorPercent, mirrorScheme,
+			int64(lib.GetIntConf("proxy.mirror.max_body_size")), time.Duration(lib.GetIntConf("proxy.mirror.timeout"))*time.Second,
+			int64(lib.GetIntConf("proxy.mirror.max_pending")))
+		if mirrorConf != nil {
+			mirrorConf.Mirror(c.Request, trans)
+		}
+	}
+	//middleware.ResponseSuccess(c,"ok")
+	//return
+	//创建 reverseproxy
+	//使用 reverseproxy.ServerHTTP(c.Request,c.Response)
+	stream := isStreamRequest(c)
+	if transform != nil && stream {
+		transform = transform.WithSkipBody()
+	}
+	proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans, fb, httpHashKey(c, serviceDetail), sticky, retry, transform)
+	//流式请求每次写入后立即flush
+	if stream {
+		proxy.FlushInterval = -1
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+//一致性hash取值，取不到时退化为请求url
+func httpHashKey(c *gin.Context, serviceDetail *dao.ServiceDetail) string {
+	keyName := serviceDetail.LoadBalance.HashKeyName
+	hashKey := ""
+	switch serviceDetail.LoadBalance.HashKeyType {
+	case public.HashKeyTypeClientIP:
+		hashKey = c.ClientIP()
+	case public.HashKeyTypeHeader:
+		hashKey = c.GetHeader(keyName)
+	case public.HashKeyTypeCookie:
+		hashKey, _ = c.Cookie(keyName)
+	case public.HashKeyTypeQuery:
+		hashKey = c.Query(keyName)
+	case public.HashKeyTypeJwtClaim:
+		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
+		if claims, err := public.JwtDecode(token); err == nil {
+			hashKey = public.JwtClaim(claims, keyName)
+		}
+	}
+	if hashKey == "" {
+		hashKey = c.Request.URL.String()
+	}
+	return hashKey
 }
@@ -0,0 +1,144 @@
+package http_proxy_middleware
+
+import (
+	"context"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const streamRouteKey = "stream_route"
+
+type rawWriterKey struct{}
+
+//保存http server原始的ResponseWriter，gin的ResponseWriter不支持单独设置写超时
+func RawResponseWriterHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handler.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), rawWriterKey{}, w)))
+	})
+}
+
+//流式路由(SSE、长轮询等)：写超时按路由覆盖http server的write_timeout，
+//下游超过空闲超时没有输出(心跳也算输出)时断开，缓冲类中间件跳过流式请求
+func HTTPStreamMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		rule := serviceDetail.HTTPRule
+		writeTimeout, ok := matchStreamRoute(rule.StreamRoutes, c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Set(streamRouteKey, true)
+		if writeTimeout < 0 {
+			writeTimeout = lib.GetIntConf("proxy.stream.write_timeout")
+		}
+		//http3监听没有写超时，无需调整
+		if rw, ok := c.Request.Context().Value(rawWriterKey{}).(http.ResponseWriter); ok && c.Request.ProtoMajor < 3 {
+			deadline := time.Time{}
+			if writeTimeout > 0 {
+				deadline = time.Now().Add(time.Duration(writeTimeout) * time.Second)
+			}
+			if err := http.NewResponseController(rw).SetWriteDeadline(deadline); err != nil {
+				middleware.ResponseError(c, 2002, err)
+				c.Abort()
+				return
+			}
+		}
+
+		idleTimeout := rule.StreamIdleTimeout
+		if idleTimeout == 0 {
+			idleTimeout = lib.GetIntConf("proxy.stream.idle_timeout")
+		}
+		if idleTimeout <= 0 {
+			c.Next()
+			return
+		}
+		//空闲超时取消请求，反向代理随之断开下游
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		writer := &streamWriter{ResponseWriter: c.Writer, lastActive: time.Now().UnixNano()}
+		c.Writer = writer
+		done := make(chan struct{})
+		defer close(done)
+		go writer.watch(time.Duration(idleTimeout)*time.Second, cancel, done)
+		c.Next()
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+//返回路由的写超时秒数，未配置写超时时返回-1
+func matchStreamRoute(conf, path string) (int, bool) {
+	if conf == "" {
+		return 0, false
+	}
+	for _, item := range strings.Split(conf, ",") {
+		items := strings.Fields(item)
+		if len(items) == 0 || !strings.HasPrefix(path, items[0]) {
+			continue
+		}
+		if len(items) == 1 {
+			return -1, true
+		}
+		writeTimeout, err := strconv.Atoi(items[1])
+		if err != nil {
+			return -1, true
+		}
+		return writeTimeout, true
+	}
+	return 0, false
+}
+
+//声明为流式路由或客户端请求SSE的请求不做缓冲处理
+func isStreamRequest(c *gin.Context) bool {
+	return c.GetBool(streamRouteKey) || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+type streamWriter struct {
+	gin.ResponseWriter
+	lastActive int64
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	atomic.StoreInt64(&w.lastActive, time.Now().UnixNano())
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *streamWriter) WriteString(s string) (int, error) {
+	atomic.StoreInt64(&w.lastActive, time.Now().UnixNano())
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *streamWriter) watch(idleTimeout time.Duration, cancel context.CancelFunc, done <-chan struct{}) {
+	interval := idleTimeout / 2
+	if interval > time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&w.lastActive))) >= idleTimeout {
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -20,9 +20,10 @@ func HTTPStripUriMiddleware() gin.HandlerFunc {
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
-		if serviceDetail.HTTPRule.RuleType==public.HTTPRuleTypePrefixURL && serviceDetail.HTTPRule.NeedStripUri==1{
+		httpRule := httpAccessRule(c, serviceDetail)
+		if httpRule.RuleType==public.HTTPRuleTypePrefixURL && httpRule.NeedStripUri==1{
 			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
-			c.Request.URL.Path = strings.Replace(c.Request.URL.Path,serviceDetail.HTTPRule.Rule,"",1)
+			c.Request.URL.Path = strings.Replace(c.Request.URL.Path,httpRule.Rule,"",1)
 			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
 		}
 		//http://127.0.0.1:8080/test_http_string/abbb
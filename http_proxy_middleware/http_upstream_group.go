@@ -0,0 +1,50 @@
+package http_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+//灰度分流，命中分组时以分组节点替换服务节点，并统计分组流量
+func HTTPUpstreamGroupMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if len(serviceDetail.UpstreamGroups) == 0 {
+			c.Next()
+			return
+		}
+
+		matchCtx := &dao.UpstreamMatchContext{
+			Header: c.GetHeader,
+			Cookie: func(name string) string {
+				value, _ := c.Cookie(name)
+				return value
+			},
+			ClientIP: c.ClientIP(),
+		}
+		if appInterface, ok := c.Get("app"); ok {
+			matchCtx.AppID = appInterface.(*dao.App).AppID
+		}
+		groupDetail := serviceDetail.SelectUpstream(matchCtx)
+		if groupDetail.GroupName() != "" {
+			groupCounter, err := public.FlowCounterHandler.GetCounter(public.FlowGroupPrefix + serviceDetail.Info.ServiceName + "_" + groupDetail.GroupName())
+			if err != nil {
+				middleware.ResponseError(c, 4001, err)
+				c.Abort()
+				return
+			}
+			groupCounter.Increase()
+		}
+		c.Set("service", groupDetail)
+		c.Next()
+	}
+}
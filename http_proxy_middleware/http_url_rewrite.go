@@ -19,7 +19,7 @@ func HTTPUrlRewriteMiddleware() gin.HandlerFunc {
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
-		for _,item:=range strings.Split(serviceDetail.HTTPRule.UrlRewrite,","){
+		for _,item:=range strings.Split(httpAccessRule(c, serviceDetail).UrlRewrite,","){
 			//fmt.Println("item rewrite",item)
 			items:=strings.Split(item," ")
 			if len(items)!=2{
@@ -0,0 +1,234 @@
+package http_proxy_middleware
+
+import (
+	"bufio"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/middleware"
+	"github.com/e421083458/go_gateway/public"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//websocket代理：未开启websocket的服务拒绝协议升级，
+//升级后的连接不受http server读写超时限制，改为按空闲超时及最长存活时间断开
+func HTTPWebsocketMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if !isUpgradeRequest(c.Request) {
+			c.Next()
+			return
+		}
+		rule := serviceDetail.HTTPRule
+		if rule.NeedWebsocket != 1 {
+			middleware.ResponseError(c, 2002, errors.New("service not support websocket"))
+			c.Abort()
+			return
+		}
+		if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			middleware.ResponseError(c, 2003, errors.New("unsupported upgrade protocol"))
+			c.Abort()
+			return
+		}
+		stat := public.WebsocketStatHandler.GetStat(serviceDetail.Info.ServiceName)
+		if !stat.Acquire(int64(rule.WebsocketMaxConn)) {
+			middleware.ResponseError(c, 2004, errors.New("websocket connection limit exceeded"))
+			c.Abort()
+			return
+		}
+		//反向代理在升级连接关闭后才返回
+		defer stat.Release()
+
+		idleTimeout := rule.WebsocketIdleTimeout
+		if idleTimeout == 0 {
+			idleTimeout = lib.GetIntConf("proxy.websocket.idle_timeout")
+		}
+		maxLifetime := rule.WebsocketMaxLifetime
+		if maxLifetime == 0 {
+			maxLifetime = lib.GetIntConf("proxy.websocket.max_lifetime")
+		}
+		writer := &websocketWriter{
+			ResponseWriter: c.Writer,
+			stat:           stat,
+			idleTimeout:    time.Duration(idleTimeout) * time.Second,
+			maxLifetime:    time.Duration(maxLifetime) * time.Second,
+		}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+func isUpgradeRequest(req *http.Request) bool {
+	if req.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, item := range strings.Split(req.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(item), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+//反向代理收到101后通过Hijack接管客户端连接，在此替换为带超时及统计的连接
+type websocketWriter struct {
+	gin.ResponseWriter
+	stat        *public.WebsocketStat
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+}
+
+func (w *websocketWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	//清除http server设置的读写超时
+	conn.SetDeadline(time.Time{})
+	wsConn := &websocketConn{
+		Conn:       conn,
+		stat:       w.stat,
+		lastActive: time.Now().UnixNano(),
+		done:       make(chan struct{}),
+	}
+	go wsConn.watch(w.idleTimeout, w.maxLifetime)
+	return wsConn, brw, nil
+}
+
+type websocketConn struct {
+	net.Conn
+	stat       *public.WebsocketStat
+	in         websocketFrameParser
+	out        websocketFrameParser
+	lastActive int64
+	closeOnce  sync.Once
+	done       chan struct{}
+}
+
+func (c *websocketConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+		c.stat.AddTraffic(true, c.in.feed(b[:n]), int64(n))
+	}
+	return n, err
+}
+
+func (c *websocketConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+		c.stat.AddTraffic(false, c.out.feed(b[:n]), int64(n))
+	}
+	return n, err
+}
+
+func (c *websocketConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return c.Conn.Close()
+}
+
+//任一方向有数据即视为活跃，关闭客户端连接后反向代理随之关闭下游连接
+func (c *websocketConn) watch(idleTimeout, maxLifetime time.Duration) {
+	if idleTimeout <= 0 && maxLifetime <= 0 {
+		return
+	}
+	var lifetimeC <-chan time.Time
+	if maxLifetime > 0 {
+		timer := time.NewTimer(maxLifetime)
+		defer timer.Stop()
+		lifetimeC = timer.C
+	}
+	var idleC <-chan time.Time
+	if idleTimeout > 0 {
+		interval := idleTimeout / 2
+		if interval > time.Second {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		idleC = ticker.C
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-lifetimeC:
+			c.Close()
+			return
+		case <-idleC:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&c.lastActive))) >= idleTimeout {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+//按RFC6455帧头统计帧数，只解析帧头，跳过负载
+type websocketFrameParser struct {
+	header    []byte
+	remaining int64
+}
+
+func (p *websocketFrameParser) feed(data []byte) int64 {
+	frames := int64(0)
+	for len(data) > 0 {
+		if p.remaining > 0 {
+			n := int64(len(data))
+			if n > p.remaining {
+				n = p.remaining
+			}
+			p.remaining -= n
+			data = data[n:]
+			continue
+		}
+		p.header = append(p.header, data[0])
+		data = data[1:]
+		if len(p.header) < 2 {
+			continue
+		}
+		headerLen := 2
+		switch p.header[1] & 0x7f {
+		case 126:
+			headerLen += 2
+		case 127:
+			headerLen += 8
+		}
+		if p.header[1]&0x80 != 0 {
+			headerLen += 4
+		}
+		if len(p.header) < headerLen {
+			continue
+		}
+		payloadLen := int64(p.header[1] & 0x7f)
+		switch payloadLen {
+		case 126:
+			payloadLen = int64(p.header[2])<<8 | int64(p.header[3])
+		case 127:
+			payloadLen = 0
+			for _, b := range p.header[2:10] {
+				payloadLen = payloadLen<<8 | int64(b)
+			}
+		}
+		p.remaining = payloadLen
+		p.header = p.header[:0]
+		frames++
+	}
+	return frames
+}
@@ -2,9 +2,13 @@ package http_proxy_router
 
 import (
 	"context"
+	"crypto/tls"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/http_proxy_middleware"
 	"github.com/e421083458/go_gateway/middleware"
 	"github.com/e421083458/go_gateway/golang_common/lib"
 	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
 	"log"
 	"net/http"
 	"time"
@@ -13,19 +17,31 @@ import (
 var (
 	HttpSrvHandler  *http.Server
 	HttpsSrvHandler *http.Server
+	Http3SrvHandler *http3.Server
 )
 
-func HttpServerRun() {
-	gin.SetMode(lib.GetStringConf("proxy.base.debug_mode"))
+//各监听使用相同的中间件链
+func newProxyHandler() http.Handler {
 	r := InitRouter(middleware.RecoveryMiddleware(),
 		middleware.RequestLog())
+	return http_proxy_middleware.RawResponseWriterHandler(r)
+}
+
+func HttpServerRun() {
+	gin.SetMode(lib.GetStringConf("proxy.base.debug_mode"))
 	HttpSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.http.addr"),
-		Handler:        r,
+		Handler:        newProxyHandler(),
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.http.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.http.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.http.max_header_bytes")),
 	}
+	//明文监听同时支持http/1.1及h2c(prior knowledge方式)
+	if lib.GetBoolConf("proxy.http.h2c") {
+		HttpSrvHandler.Protocols = new(http.Protocols)
+		HttpSrvHandler.Protocols.SetHTTP1(true)
+		HttpSrvHandler.Protocols.SetUnencryptedHTTP2(true)
+	}
 	log.Printf(" [INFO] http_proxy_run %s\n", lib.GetStringConf("proxy.http.addr"))
 	if err := HttpSrvHandler.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] http_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http.addr"), err)
@@ -34,23 +50,71 @@ func HttpServerRun() {
 
 func HttpsServerRun() {
 	gin.SetMode(lib.GetStringConf("proxy.base.debug_mode"))
-	r := InitRouter(middleware.RecoveryMiddleware(),
-		middleware.RequestLog())
+	handler := newProxyHandler()
+	if lib.GetBoolConf("proxy.http3.enable") {
+		http3ServerRun(handler)
+		handler = altSvcHandler(handler)
+	}
 	HttpsSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.https.addr"),
-		Handler:        r,
+		Handler:        handler,
 		ReadTimeout:    time.Duration(lib.GetIntConf("proxy.https.read_timeout")) * time.Second,
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.https.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
+		TLSConfig:      newHttpsTLSConfig(),
 	}
 	log.Printf(" [INFO] https_proxy_run %s\n", lib.GetStringConf("proxy.https.addr"))
-	//todo 以下命令只在编译机有效，如果是交叉编译情况下需要单独设置路径
-	//if err := HttpsSrvHandler.ListenAndServeTLS(cert_file.Path("server.crt"), cert_file.Path("server.key")); err != nil && err!=http.ErrServerClosed {
-	if err := HttpsSrvHandler.ListenAndServeTLS("./cert_file/server.crt", "./cert_file/server.key"); err != nil && err != http.ErrServerClosed {
+	if err := HttpsSrvHandler.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
 	}
 }
 
+//http3与https共用证书及中间件链，监听udp端口
+func http3ServerRun(handler http.Handler) {
+	addr := lib.GetStringConf("proxy.http3.addr")
+	if addr == "" {
+		addr = lib.GetStringConf("proxy.https.addr")
+	}
+	Http3SrvHandler = &http3.Server{
+		Addr:           addr,
+		Handler:        handler,
+		TLSConfig:      http3.ConfigureTLSConfig(newHttpsTLSConfig()),
+		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
+		IdleTimeout:    time.Duration(lib.GetIntConf("proxy.http3.idle_timeout")) * time.Second,
+	}
+	log.Printf(" [INFO] http3_proxy_run %s\n", addr)
+	go func() {
+		if err := Http3SrvHandler.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf(" [ERROR] http3_proxy_run %s err:%v\n", addr, err)
+		}
+	}()
+}
+
+//https响应通过Alt-Svc通告http3端口，http3监听未就绪时不通告
+func altSvcHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		Http3SrvHandler.SetQUICHeaders(w.Header())
+		handler.ServeHTTP(w, req)
+	})
+}
+
+//开启客户端证书认证的服务存在时才请求客户端证书，避免浏览器无故弹出证书选择
+func newHttpsTLSConfig() *tls.Config {
+	conf := &tls.Config{
+		GetCertificate: dao.CertManagerHandler.GetTLSCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	clientAuthConf := conf.Clone()
+	clientAuthConf.ClientAuth = tls.RequestClientCert
+	conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		if dao.ServiceManagerHandler.HasHTTPClientAuth() {
+			return clientAuthConf, nil
+		}
+		return nil, nil
+	}
+	return conf
+}
+
 func HttpServerStop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -63,6 +127,12 @@ func HttpServerStop() {
 func HttpsServerStop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if Http3SrvHandler != nil {
+		if err := Http3SrvHandler.Shutdown(ctx); err != nil {
+			log.Printf(" [ERROR] http3_proxy_stop err:%v\n", err)
+		}
+		log.Printf(" [INFO] http3_proxy_stop %v stopped\n", Http3SrvHandler.Addr)
+	}
 	if err := HttpsSrvHandler.Shutdown(ctx); err != nil {
 		log.Fatalf(" [ERROR] https_proxy_stop err:%v\n", err)
 	}
@@ -26,16 +26,24 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 
 	router.Use(
 		http_proxy_middleware.HTTPAccessModeMiddleware(),
+		http_proxy_middleware.HTTPCorsMiddleware(),
 		http_proxy_middleware.HTTPFlowCountMiddleware(),
 		http_proxy_middleware.HTTPFlowLimitMiddleware(),
+		http_proxy_middleware.HTTPClientCertMiddleware(),
 		http_proxy_middleware.HTTPJwtAuthTokenMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowCountMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowLimitMiddleware(),
 		http_proxy_middleware.HTTPWhiteListMiddleware(),
 		http_proxy_middleware.HTTPBlackListMiddleware(),
+		http_proxy_middleware.HTTPWebsocketMiddleware(),
+		http_proxy_middleware.HTTPStreamMiddleware(),
 		http_proxy_middleware.HTTPHeaderTransferMiddleware(),
 		http_proxy_middleware.HTTPStripUriMiddleware(),
 		http_proxy_middleware.HTTPUrlRewriteMiddleware(),
+		http_proxy_middleware.HTTPUpstreamGroupMiddleware(),
+		http_proxy_middleware.HTTPCompressMiddleware(),
+		http_proxy_middleware.HTTPCacheMiddleware(),
+		http_proxy_middleware.HTTPCircuitBreakerMiddleware(),
 		http_proxy_middleware.HTTPReverseProxyMiddleware())
 
 	return router
@@ -8,9 +8,11 @@ import (
 	"github.com/e421083458/go_gateway/http_proxy_router"
 	"github.com/e421083458/go_gateway/router"
 	"github.com/e421083458/go_gateway/tcp_proxy_router"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 //endpoint dashboard后台管理  server代理服务器
@@ -47,6 +49,8 @@ func main() {
 		defer lib.Destroy()
 		dao.ServiceManagerHandler.LoadOnce()
 		dao.AppManagerHandler.LoadOnce()
+		dao.CertManagerHandler.LoadOnce()
+		dao.ReloadInterval(time.Duration(lib.GetIntConf("proxy.base.reload_interval")) * time.Second)
 
 		go func() {
 			http_proxy_router.HttpServerRun()
@@ -61,9 +65,19 @@ func main() {
 			grpc_proxy_router.GrpcServerRun()
 		}()
 
-		quit := make(chan os.Signal)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+		//SIGHUP 立即重新加载服务、租户及证书配置
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		for sig := range quit {
+			if sig != syscall.SIGHUP {
+				break
+			}
+			if err := dao.ReloadAll(); err != nil {
+				log.Printf(" [ERROR] reload err:%v\n", err)
+				continue
+			}
+			log.Printf(" [INFO] reload finished\n")
+		}
 
 		tcp_proxy_router.TcpServerStop()
 		grpc_proxy_router.GrpcServerStop()
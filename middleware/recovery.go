@@ -6,6 +6,7 @@ import (
 	"github.com/e421083458/go_gateway/public"
 	"github.com/e421083458/go_gateway/golang_common/lib"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"runtime/debug"
 )
 
@@ -14,6 +15,10 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				//流式响应输出中断，交由http server直接断开连接
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				//先做一下日志记录
 				fmt.Println(string(debug.Stack()))
 				public.ComLogNotice(c, "_com_panic", map[string]interface{}{
@@ -22,8 +22,12 @@ func RequestInLog(c *gin.Context) {
 	c.Set("startExecTime", time.Now())
 	c.Set("trace", traceContext)
 
-	bodyBytes, _ := ioutil.ReadAll(c.Request.Body)
-	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes)) // Write body back
+	//长度未知的流式请求体不读取，避免缓冲整个请求
+	bodyBytes := []byte{}
+	if c.Request.ContentLength >= 0 {
+		bodyBytes, _ = ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes)) // Write body back
+	}
 
 	lib.Log.TagInfo(traceContext, "_com_request_in", map[string]interface{}{
 		"uri":    c.Request.RequestURI,
@@ -110,6 +110,54 @@ func TranslationMiddleware() gin.HandlerFunc {
 				}
 				return true
 			})
+			val.RegisterValidation("valid_check_status", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				matched, _ := regexp.Match(`^\d{3}(-\d{3})?$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_retry_on", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, item := range strings.Split(fl.Field().String(), ",") {
+					if matched, _ := regexp.Match(`^(connect_failure|timeout|\d{3})$`, []byte(item)); !matched {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_mirror_target", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				matched, _ := regexp.Match(`^(https?://)?[^\s/]+\:\d+$`, []byte(fl.Field().String()))
+				return matched
+			})
+
+			val.RegisterValidation("valid_stream_routes", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, item := range strings.Split(fl.Field().String(), ",") {
+					if matched, _ := regexp.Match(`^/\S*( \d+)?$`, []byte(strings.TrimSpace(item))); !matched {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_sni_hosts", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, item := range strings.Split(fl.Field().String(), ",") {
+					if matched, _ := regexp.Match(`^(\*\.)?[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*$`, []byte(strings.TrimSpace(item))); !matched {
+						return false
+					}
+				}
+				return true
+			})
 
 			//自定义翻译器
 			//https://github.com/go-playground/validator/blob/v9/_examples/translations/main.go
@@ -161,6 +209,36 @@ func TranslationMiddleware() gin.HandlerFunc {
 				t, _ := ut.T("valid_weightlist", fe.Field())
 				return t
 			})
+			val.RegisterTranslation("valid_check_status", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_check_status", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_check_status", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_retry_on", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_retry_on", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_retry_on", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_mirror_target", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_mirror_target", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_mirror_target", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_stream_routes", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_stream_routes", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_stream_routes", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_sni_hosts", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_sni_hosts", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_sni_hosts", fe.Field())
+				return t
+			})
 			break
 		}
 		c.Set(public.TranslatorKey, trans)
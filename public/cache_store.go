@@ -0,0 +1,243 @@
+package public
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/garyburd/redigo/redis"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//缓存最长保留时间，包含过期后可返回旧内容的时间
+const DefaultCacheMaxTTL = 7 * 24 * time.Hour
+
+func CacheMaxTTL() time.Duration {
+	maxTTL := time.Duration(lib.GetIntConf("proxy.cache.max_ttl")) * time.Second
+	if maxTTL <= 0 {
+		maxTTL = DefaultCacheMaxTTL
+	}
+	return maxTTL
+}
+
+//缓存的响应，Fresh之前直接使用，Stale之前可先返回旧内容并后台刷新
+type CacheEntry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	VaryHeader []string    `json:"vary_header"` //非空时为Vary索引，真实内容按header值另存
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshAt    time.Time   `json:"fresh_at"`
+	StaleAt    time.Time   `json:"stale_at"`
+}
+
+func (e *CacheEntry) size() int64 {
+	size := int64(len(e.Body))
+	for name, values := range e.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+}
+
+var CacheStoreHandler *CacheStoreManager
+
+func init() {
+	CacheStoreHandler = NewCacheStoreManager()
+}
+
+type CacheStoreManager struct {
+	memory *MemoryCacheStore
+	redis  *RedisCacheStore
+	locker sync.Mutex
+}
+
+func NewCacheStoreManager() *CacheStoreManager {
+	return &CacheStoreManager{
+		redis: &RedisCacheStore{},
+	}
+}
+
+//内存缓存进程内共享，按总字节数淘汰
+func (m *CacheStoreManager) GetStore(storeType int, memoryMaxBytes int64) CacheStore {
+	if storeType == CacheStoreRedis {
+		return m.redis
+	}
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if m.memory == nil {
+		m.memory = NewMemoryCacheStore(memoryMaxBytes)
+	}
+	return m.memory
+}
+
+const DefaultCacheMemoryMaxBytes = 100 * 1024 * 1024
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+	size  int64
+}
+
+//LRU内存缓存
+type MemoryCacheStore struct {
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[string]*list.Element
+	locker   sync.Mutex
+}
+
+func NewMemoryCacheStore(maxBytes int64) *MemoryCacheStore {
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMemoryMaxBytes
+	}
+	return &MemoryCacheStore{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (s *MemoryCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*memoryCacheItem)
+	if time.Now().After(item.entry.StaleAt) {
+		s.removeElement(elem)
+		return nil, false
+	}
+	s.ll.MoveToFront(elem)
+	return item.entry, true
+}
+
+func (s *MemoryCacheStore) Set(key string, entry *CacheEntry) {
+	size := entry.size() + int64(len(key))
+	if size > s.maxBytes {
+		return
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if elem, ok := s.items[key]; ok {
+		s.removeElement(elem)
+	}
+	s.items[key] = s.ll.PushFront(&memoryCacheItem{key: key, entry: entry, size: size})
+	s.curBytes += size
+	for s.curBytes > s.maxBytes {
+		s.removeElement(s.ll.Back())
+	}
+}
+
+func (s *MemoryCacheStore) removeElement(elem *list.Element) {
+	item := s.ll.Remove(elem).(*memoryCacheItem)
+	delete(s.items, item.key)
+	s.curBytes -= item.size
+}
+
+//redis缓存，多个代理进程共享，过期交由redis处理
+type RedisCacheStore struct {
+}
+
+func (s *RedisCacheStore) Get(key string) (*CacheEntry, bool) {
+	data, err := redis.Bytes(RedisConfDo("GET", RedisCacheKeyPrefix+key))
+	if err != nil {
+		if err != redis.ErrNil {
+			fmt.Println("RedisCacheStore get err", err)
+		}
+		return nil, false
+	}
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (s *RedisCacheStore) Set(key string, entry *CacheEntry) {
+	expire := int64(time.Until(entry.StaleAt)/time.Second) + 1
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if _, err := RedisConfDo("SET", RedisCacheKeyPrefix+key, data, "EX", expire); err != nil {
+		fmt.Println("RedisCacheStore set err", err)
+	}
+}
+
+var CachePurgeHandler *CachePurger
+
+func init() {
+	CachePurgeHandler = &CachePurger{records: map[string]int64{}}
+}
+
+//按服务或key前缀清除缓存：dashboard写入清除记录，代理进程定期同步，
+//早于清除时间写入且key匹配前缀的缓存视为失效，内存及redis缓存通用
+type CachePurger struct {
+	records map[string]int64
+	locker  sync.RWMutex
+	once    sync.Once
+}
+
+//清除记录为 服务名|key前缀，prefix为空时清除整个服务
+//记录至少保留缓存最长保留时间，早于清除时间写入的缓存到期前不会重新生效
+func AddCachePurge(serviceName, prefix string) error {
+	return RedisConfPipline(func(c redis.Conn) {
+		c.Send("HSET", RedisCachePurgeKey, CacheKey(serviceName, prefix), time.Now().UnixNano())
+		c.Send("EXPIRE", RedisCachePurgeKey, int64(CacheMaxTTL()/time.Second))
+	})
+}
+
+func CacheKey(serviceName, key string) string {
+	return serviceName + "|" + key
+}
+
+//定期从redis同步清除记录，首次调用时启动
+func (p *CachePurger) Start(interval time.Duration) {
+	p.once.Do(func() {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		p.sync()
+		go func() {
+			for range time.Tick(interval) {
+				p.sync()
+			}
+		}()
+	})
+}
+
+func (p *CachePurger) sync() {
+	records, err := redis.Int64Map(RedisConfDo("HGETALL", RedisCachePurgeKey))
+	if err != nil {
+		fmt.Println("CachePurger sync err", err)
+		return
+	}
+	p.locker.Lock()
+	p.records = records
+	p.locker.Unlock()
+}
+
+func (p *CachePurger) Purged(key string, storedAt time.Time) bool {
+	p.locker.RLock()
+	defer p.locker.RUnlock()
+	for prefix, purgeAt := range p.records {
+		if storedAt.UnixNano() <= purgeAt && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
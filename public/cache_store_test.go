@@ -0,0 +1,53 @@
+package public
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheStore(t *testing.T) {
+	entry := func(body string) *CacheEntry {
+		return &CacheEntry{Body: []byte(body), StaleAt: time.Now().Add(time.Minute)}
+	}
+	//每项大小为key长度加body长度，共10byte
+	store := NewMemoryCacheStore(30)
+	store.Set("k1", entry("12345678"))
+	store.Set("k2", entry("12345678"))
+	store.Set("k3", entry("12345678"))
+	if _, ok := store.Get("k1"); !ok {
+		t.Fatal("expect k1 cached")
+	}
+	//超过上限时淘汰最久未使用的k2
+	store.Set("k4", entry("12345678"))
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		if _, ok := store.Get(key); ok != (key != "k2") {
+			t.Errorf("%s: expect cached=%v", key, key != "k2")
+		}
+	}
+
+	//覆盖写入不重复计算大小，此时最久未使用的为k1
+	store.Set("k4", entry("1234"))
+	if store.curBytes != 26 || store.ll.Len() != 3 {
+		t.Fatalf("expect 3 items 26 bytes, got %v %v", store.ll.Len(), store.curBytes)
+	}
+	store.Set("k5", entry("1234"))
+	if _, ok := store.items["k1"]; ok || store.curBytes != 22 {
+		t.Fatalf("expect k1 evicted, got %v bytes", store.curBytes)
+	}
+
+	//超过上限的单项不缓存
+	store.Set("k6", entry(strings.Repeat("1", 30)))
+	if _, ok := store.Get("k6"); ok {
+		t.Fatal("expect oversized entry skipped")
+	}
+
+	//过期内容读取时删除
+	store.Set("k7", &CacheEntry{Body: []byte("1"), StaleAt: time.Now().Add(-time.Second)})
+	if _, ok := store.Get("k7"); ok {
+		t.Fatal("expect stale entry removed")
+	}
+	if _, ok := store.items["k7"]; ok {
+		t.Fatal("expect stale entry deleted")
+	}
+}
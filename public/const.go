@@ -11,13 +11,45 @@ const (
 
 	HTTPRuleTypePrefixURL = 0
 	HTTPRuleTypeDomain    = 1
+	HTTPRuleTypeRegexURL  = 2
+
+	//一致性hash取值方式
+	HashKeyTypeURL      = 0 //http为请求url，grpc为方法名，tcp为客户端ip
+	HashKeyTypeClientIP = 1
+	HashKeyTypeHeader   = 2 //http header，grpc metadata
+	HashKeyTypeCookie   = 3
+	HashKeyTypeQuery    = 4
+	HashKeyTypeJwtClaim = 5 //hash_key_name为空时取app_id
+
+	//灰度分组匹配方式
+	UpstreamMatchWeight   = 0 //按traffic_weight比例分流
+	UpstreamMatchHeader   = 1
+	UpstreamMatchCookie   = 2
+	UpstreamMatchClientIP = 3 //ip或cidr
+	UpstreamMatchAppID    = 4 //jwt租户app_id
+
+	//header规则作用方向
+	HeaderRuleRequest  = 0
+	HeaderRuleResponse = 1
 
 	RedisFlowDayKey  = "flow_day_count"
 	RedisFlowHourKey = "flow_hour_count"
+	RedisCircuitBreakerKey = "circuit_breaker_state"
+	CircuitBreakerServiceField = "service"
+	CircuitBreakerGroupPrefix = "group_"
+	RedisCacheKeyPrefix = "http_cache_"
+	RedisCachePurgeKey = "http_cache_purge"
+	RedisWebsocketStatPrefix = "websocket_stat_"
+	RedisWebsocketActivePrefix = "websocket_active_"
+
+	//响应缓存存储方式
+	CacheStoreMemory = 0
+	CacheStoreRedis  = 1
 
 	FlowTotal          = "flow_total"
 	FlowServicePrefix  = "flow_service_"
 	FlowAppPrefix = "flow_app_"
+	FlowGroupPrefix = "flow_group_"
 
 	JwtSignKey = "my_sign_key"
 	JwtExpires = 60*60
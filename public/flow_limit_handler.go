@@ -15,6 +15,7 @@ type FlowLimiter struct {
 
 type FlowLimiterItem struct {
 	ServiceName string
+	Owner       string //所属服务或租户的限流key，reload时按此整体清理
 	Limter      *rate.Limiter
 }
 
@@ -31,20 +32,45 @@ func init() {
 }
 
 func (counter *FlowLimiter) GetLimiter(serverName string, qps float64) (*rate.Limiter, error) {
-	for _, item := range counter.FlowLmiterSlice {
-		if item.ServiceName == serverName {
-			return item.Limter, nil
-		}
+	return counter.GetOwnedLimiter(serverName, serverName, qps)
+}
+
+//获取归属于owner的限流器，如服务下按客户端ip的限流器归属于服务
+func (counter *FlowLimiter) GetOwnedLimiter(owner, serverName string, qps float64) (*rate.Limiter, error) {
+	counter.Locker.RLock()
+	item, ok := counter.FlowLmiterMap[serverName]
+	counter.Locker.RUnlock()
+	if ok {
+		return item.Limter, nil
 	}
 
+	counter.Locker.Lock()
+	defer counter.Locker.Unlock()
+	if item, ok := counter.FlowLmiterMap[serverName]; ok {
+		return item.Limter, nil
+	}
 	newLimiter := rate.NewLimiter(rate.Limit(qps), int(qps*3))
-	item := &FlowLimiterItem{
+	item = &FlowLimiterItem{
 		ServiceName: serverName,
+		Owner:       owner,
 		Limter:      newLimiter,
 	}
 	counter.FlowLmiterSlice = append(counter.FlowLmiterSlice, item)
-	counter.Locker.Lock()
-	defer counter.Locker.Unlock()
 	counter.FlowLmiterMap[serverName] = item
 	return newLimiter, nil
 }
+
+//删除owner的全部限流器，下次获取时按最新配置重建
+func (counter *FlowLimiter) RemoveByOwner(owner string) {
+	counter.Locker.Lock()
+	defer counter.Locker.Unlock()
+	itemSlice := []*FlowLimiterItem{}
+	for _, item := range counter.FlowLmiterSlice {
+		if item.Owner == owner {
+			delete(counter.FlowLmiterMap, item.ServiceName)
+			continue
+		}
+		itemSlice = append(itemSlice, item)
+	}
+	counter.FlowLmiterSlice = itemSlice
+}
@@ -0,0 +1,43 @@
+package public
+
+import "testing"
+
+func TestFlowLimiterRemoveByOwner(t *testing.T) {
+	limiter := NewFlowLimiter()
+	//服务名foo是foo_bar的前缀，清理foo时不影响foo_bar
+	foo := FlowServicePrefix + "foo"
+	fooBar := FlowServicePrefix + "foo_bar"
+	keys := map[string]string{
+		foo:                           foo,
+		foo + "_127.0.0.1":            foo,
+		fooBar:                        fooBar,
+		fooBar + "_127.0.0.1":         fooBar,
+		FlowAppPrefix + "a_1.1.1.1":   FlowAppPrefix + "a",
+		FlowAppPrefix + "a_b_1.1.1.1": FlowAppPrefix + "a_b",
+	}
+	for key, owner := range keys {
+		if _, err := limiter.GetOwnedLimiter(owner, key, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached, _ := limiter.GetLimiter(fooBar, 10)
+
+	limiter.RemoveByOwner(foo)
+	limiter.RemoveByOwner(FlowAppPrefix + "a")
+	for _, key := range []string{foo, foo + "_127.0.0.1", FlowAppPrefix + "a_1.1.1.1"} {
+		if _, ok := limiter.FlowLmiterMap[key]; ok {
+			t.Errorf("expect %s removed", key)
+		}
+	}
+	for _, key := range []string{fooBar, fooBar + "_127.0.0.1", FlowAppPrefix + "a_b_1.1.1.1"} {
+		if _, ok := limiter.FlowLmiterMap[key]; !ok {
+			t.Errorf("expect %s kept", key)
+		}
+	}
+	if len(limiter.FlowLmiterSlice) != 3 {
+		t.Fatalf("expect 3 limiters, got %d", len(limiter.FlowLmiterSlice))
+	}
+	if reused, _ := limiter.GetLimiter(fooBar, 10); reused != cached {
+		t.Fatal("expect foo_bar limiter reused")
+	}
+}
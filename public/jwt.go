@@ -24,3 +24,18 @@ func JwtEncode(claims jwt.StandardClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(mySigningKey)
 }
+
+//按名称读取claim，空或app_id取签发方即app_id
+func JwtClaim(claims *jwt.StandardClaims, name string) string {
+	switch name {
+	case "", "app_id", "iss":
+		return claims.Issuer
+	case "sub":
+		return claims.Subject
+	case "aud":
+		return claims.Audience
+	case "jti":
+		return claims.Id
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package public
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+)
+
+//熔断状态写入redis，供dashboard展示，field为service或节点地址
+func SetCircuitBreakerState(serviceName, field, state string) {
+	key := GetCircuitBreakerKey(serviceName)
+	if err := RedisConfPipline(func(c redis.Conn) {
+		c.Send("HSET", key, field, state)
+		c.Send("EXPIRE", key, 86400*2)
+	}); err != nil {
+		fmt.Println("SetCircuitBreakerState err", err)
+	}
+}
+
+//熔断器重建时清除旧状态
+func ResetCircuitBreakerState(serviceName string) {
+	if _, err := RedisConfDo("DEL", GetCircuitBreakerKey(serviceName)); err != nil {
+		fmt.Println("ResetCircuitBreakerState err", err)
+	}
+}
+
+func GetCircuitBreakerState(serviceName string) (map[string]string, error) {
+	return redis.StringMap(RedisConfDo("HGETALL", GetCircuitBreakerKey(serviceName)))
+}
+
+func GetCircuitBreakerKey(serviceName string) string {
+	return fmt.Sprintf("%s_%s", RedisCircuitBreakerKey, serviceName)
+}
@@ -0,0 +1,112 @@
+package public
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultRetryBudgetMax = 100 //重试额度上限，避免长时间空闲后突发大量重试
+
+var RetryBudgetHandler *RetryBudgetManager
+
+type RetryBudgetManager struct {
+	RetryBudgetMap   map[string]*RetryBudget
+	RetryBudgetSlice []*RetryBudget
+	Locker           sync.RWMutex
+}
+
+func NewRetryBudgetManager() *RetryBudgetManager {
+	return &RetryBudgetManager{
+		RetryBudgetMap:   map[string]*RetryBudget{},
+		RetryBudgetSlice: []*RetryBudget{},
+		Locker:           sync.RWMutex{},
+	}
+}
+
+func init() {
+	RetryBudgetHandler = NewRetryBudgetManager()
+}
+
+//ratio为每个请求可换取的重试次数，minPerSec为每秒保底重试次数
+func (m *RetryBudgetManager) GetBudget(serviceName string, ratio, minPerSec float64) *RetryBudget {
+	m.Locker.RLock()
+	budget, ok := m.RetryBudgetMap[serviceName]
+	m.Locker.RUnlock()
+	if ok {
+		return budget
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if budget, ok := m.RetryBudgetMap[serviceName]; ok {
+		return budget
+	}
+	budget = &RetryBudget{
+		ServiceName: serviceName,
+		ratio:       ratio,
+		minPerSec:   minPerSec,
+		tokens:      minPerSec,
+		last:        time.Now(),
+	}
+	m.RetryBudgetSlice = append(m.RetryBudgetSlice, budget)
+	m.RetryBudgetMap[serviceName] = budget
+	return budget
+}
+
+func (m *RetryBudgetManager) Remove(serviceName string) {
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if _, ok := m.RetryBudgetMap[serviceName]; !ok {
+		return
+	}
+	delete(m.RetryBudgetMap, serviceName)
+	budgetSlice := []*RetryBudget{}
+	for _, item := range m.RetryBudgetSlice {
+		if item.ServiceName != serviceName {
+			budgetSlice = append(budgetSlice, item)
+		}
+	}
+	m.RetryBudgetSlice = budgetSlice
+}
+
+//重试预算：请求存入额度，重试消耗额度，下游大面积故障时限制重试放大流量
+type RetryBudget struct {
+	ServiceName string
+	ratio       float64
+	minPerSec   float64
+	tokens      float64
+	last        time.Time
+	mu          sync.Mutex
+}
+
+//每个请求调用一次
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens += b.ratio
+	if b.tokens > DefaultRetryBudgetMax {
+		b.tokens = DefaultRetryBudgetMax
+	}
+}
+
+//额度不足时返回false，不再重试
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.minPerSec
+	if b.tokens > DefaultRetryBudgetMax {
+		b.tokens = DefaultRetryBudgetMax
+	}
+	b.last = now
+}
@@ -0,0 +1,53 @@
+package public
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	cases := []struct {
+		name      string
+		ratio     float64
+		minPerSec float64
+		deposits  int
+		idle      time.Duration
+		withdraws int
+	}{
+		{"min per sec initial tokens", 0, 3, 0, 0, 3},
+		{"no budget", 0, 0, 10, 0, 0},
+		{"ratio per request", 0.25, 0, 10, 0, 2},
+		{"ratio and min", 0.5, 1, 4, 0, 3},
+		{"min per sec refill", 0, 2, 0, 1500 * time.Millisecond, 5},
+		{"capped at max", 1, 0, DefaultRetryBudgetMax + 50, 0, DefaultRetryBudgetMax},
+		{"refill capped at max", 0, 10, 0, time.Hour, DefaultRetryBudgetMax},
+	}
+	for _, c := range cases {
+		manager := NewRetryBudgetManager()
+		budget := manager.GetBudget("test", c.ratio, c.minPerSec)
+		//固定起始时间，避免测试耗时计入补充额度
+		budget.last = time.Now().Add(-c.idle)
+		for i := 0; i < c.deposits; i++ {
+			budget.Deposit()
+		}
+		withdraws := 0
+		for budget.Withdraw() {
+			withdraws++
+		}
+		if withdraws != c.withdraws {
+			t.Errorf("%s: expect %d withdraws, got %d", c.name, c.withdraws, withdraws)
+		}
+	}
+}
+
+func TestRetryBudgetManager(t *testing.T) {
+	manager := NewRetryBudgetManager()
+	budget := manager.GetBudget("test", 0.2, 1)
+	if manager.GetBudget("test", 0.5, 5) != budget {
+		t.Fatal("expect same budget for same service")
+	}
+	manager.Remove("test")
+	if manager.GetBudget("test", 0.5, 5) == budget || len(manager.RetryBudgetSlice) != 1 {
+		t.Fatal("expect new budget after remove")
+	}
+}
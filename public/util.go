@@ -1,11 +1,18 @@
 package public
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/e421083458/go_gateway/golang_common/lib"
 	"io"
+	"log"
+	"strings"
+	"sync"
 )
 
 func GenSaltPassword(salt, password string) string {
@@ -36,3 +43,51 @@ func InStringSlice(slice []string,str string) bool{
 	}
 	return false
 }
+
+var (
+	stickySignKey     []byte
+	stickySignKeyOnce sync.Once
+)
+
+//签名密钥取自proxy.sticky.sign_key，未配置时使用进程内随机密钥，重启后或多实例间已下发的cookie失效
+func getStickySignKey() []byte {
+	stickySignKeyOnce.Do(func() {
+		stickySignKey = []byte(lib.GetStringConf("proxy.sticky.sign_key"))
+		if len(stickySignKey) == 0 {
+			log.Printf(" [WARN] proxy.sticky.sign_key not set, use random key\n")
+			stickySignKey = make([]byte, 32)
+			rand.Read(stickySignKey)
+		}
+	})
+	return stickySignKey
+}
+
+//签名，格式为 base64(value).base64(hmac)，用于sticky cookie等防篡改场景
+func SignValue(value string) string {
+	mac := hmac.New(sha256.New, getStickySignKey())
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString([]byte(value)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//校验签名，返回原始value
+func VerifySignedValue(signed string) (string, bool) {
+	pos := strings.LastIndex(signed, ".")
+	if pos < 0 {
+		return "", false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(signed[:pos])
+	if err != nil {
+		return "", false
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signed[pos+1:])
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, getStickySignKey())
+	mac.Write(value)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return "", false
+	}
+	return string(value), true
+}
@@ -0,0 +1,45 @@
+package public
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignValue(t *testing.T) {
+	stickySignKeyOnce.Do(func() {
+		stickySignKey = []byte("test_sign_key")
+	})
+	signed := SignValue("http://127.0.0.1:2003")
+	value, ok := VerifySignedValue(signed)
+	if !ok || value != "http://127.0.0.1:2003" {
+		t.Fatalf("expect verified value, got %v %v", value, ok)
+	}
+
+	pos := strings.LastIndex(signed, ".")
+	otherValue := SignValue("http://127.0.0.1:2004")
+	cases := []struct {
+		name   string
+		signed string
+	}{
+		{"empty", ""},
+		{"no signature", signed[:pos]},
+		{"tampered value", otherValue[:strings.LastIndex(otherValue, ".")] + signed[pos:]},
+		{"tampered signature", signed[:pos+1] + "AAAA" + signed[pos+5:]},
+		{"bad base64 value", "!!!" + signed[pos:]},
+		{"bad base64 signature", signed[:pos+1] + "!!!"},
+	}
+	for _, c := range cases {
+		if value, ok := VerifySignedValue(c.signed); ok {
+			t.Errorf("%s: expect invalid, got %v", c.name, value)
+		}
+	}
+
+	//更换密钥后旧签名失效
+	stickySignKey = []byte("other_sign_key")
+	defer func() {
+		stickySignKey = []byte("test_sign_key")
+	}()
+	if _, ok := VerifySignedValue(signed); ok {
+		t.Fatal("expect invalid with other key")
+	}
+}
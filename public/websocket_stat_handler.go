@@ -0,0 +1,189 @@
+package public
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const WebsocketStatSyncInterval = 5 * time.Second
+
+var WebsocketStatHandler *WebsocketStatManager
+
+type WebsocketStatManager struct {
+	WebsocketStatMap   map[string]*WebsocketStat
+	WebsocketStatSlice []*WebsocketStat
+	Locker             sync.RWMutex
+	node               string
+	once               sync.Once
+}
+
+func NewWebsocketStatManager() *WebsocketStatManager {
+	hostname, _ := os.Hostname()
+	return &WebsocketStatManager{
+		WebsocketStatMap:   map[string]*WebsocketStat{},
+		WebsocketStatSlice: []*WebsocketStat{},
+		Locker:             sync.RWMutex{},
+		node:               fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+	}
+}
+
+func init() {
+	WebsocketStatHandler = NewWebsocketStatManager()
+}
+
+//首次获取时启动同步，各代理进程定期把统计增量及当前连接数写入redis
+func (m *WebsocketStatManager) GetStat(serviceName string) *WebsocketStat {
+	m.once.Do(func() {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					fmt.Println(err)
+				}
+			}()
+			for range time.Tick(WebsocketStatSyncInterval) {
+				m.sync()
+			}
+		}()
+	})
+	m.Locker.RLock()
+	stat, ok := m.WebsocketStatMap[serviceName]
+	m.Locker.RUnlock()
+	if ok {
+		return stat
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if stat, ok := m.WebsocketStatMap[serviceName]; ok {
+		return stat
+	}
+	stat = &WebsocketStat{ServiceName: serviceName}
+	m.WebsocketStatSlice = append(m.WebsocketStatSlice, stat)
+	m.WebsocketStatMap[serviceName] = stat
+	return stat
+}
+
+func (m *WebsocketStatManager) sync() {
+	m.Locker.RLock()
+	statSlice := m.WebsocketStatSlice
+	m.Locker.RUnlock()
+	now := time.Now().Unix()
+	for _, stat := range statSlice {
+		statKey := RedisWebsocketStatPrefix + stat.ServiceName
+		activeKey := RedisWebsocketActivePrefix + stat.ServiceName
+		deltas := []interface{}{
+			"total_conn", atomic.SwapInt64(&stat.totalConn, 0),
+			"rejected_conn", atomic.SwapInt64(&stat.rejectedConn, 0),
+			"frames_in", atomic.SwapInt64(&stat.framesIn, 0),
+			"frames_out", atomic.SwapInt64(&stat.framesOut, 0),
+			"bytes_in", atomic.SwapInt64(&stat.bytesIn, 0),
+			"bytes_out", atomic.SwapInt64(&stat.bytesOut, 0),
+		}
+		active := atomic.LoadInt64(&stat.active)
+		if err := RedisConfPipline(func(c redis.Conn) {
+			for i := 0; i < len(deltas); i += 2 {
+				c.Send("HINCRBY", statKey, deltas[i], deltas[i+1])
+			}
+			c.Send("EXPIRE", statKey, 86400*2)
+			c.Send("HSET", activeKey, m.node, fmt.Sprintf("%d|%d", active, now))
+			c.Send("EXPIRE", activeKey, 86400*2)
+		}); err != nil {
+			fmt.Println("WebsocketStat sync err", err)
+		}
+	}
+}
+
+//websocket统计，连接数上限只在单个代理进程内生效
+type WebsocketStat struct {
+	ServiceName  string
+	active       int64
+	totalConn    int64
+	rejectedConn int64
+	framesIn     int64
+	framesOut    int64
+	bytesIn      int64
+	bytesOut     int64
+}
+
+//maxConn为0时不限制，超过上限返回false
+func (s *WebsocketStat) Acquire(maxConn int64) bool {
+	for {
+		active := atomic.LoadInt64(&s.active)
+		if maxConn > 0 && active >= maxConn {
+			atomic.AddInt64(&s.rejectedConn, 1)
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&s.active, active, active+1) {
+			atomic.AddInt64(&s.totalConn, 1)
+			return true
+		}
+	}
+}
+
+func (s *WebsocketStat) Release() {
+	atomic.AddInt64(&s.active, -1)
+}
+
+func (s *WebsocketStat) Active() int64 {
+	return atomic.LoadInt64(&s.active)
+}
+
+//in为客户端到下游方向
+func (s *WebsocketStat) AddTraffic(in bool, frames, bytes int64) {
+	if in {
+		atomic.AddInt64(&s.framesIn, frames)
+		atomic.AddInt64(&s.bytesIn, bytes)
+		return
+	}
+	atomic.AddInt64(&s.framesOut, frames)
+	atomic.AddInt64(&s.bytesOut, bytes)
+}
+
+type WebsocketStatData struct {
+	ActiveConn   int64
+	TotalConn    int64
+	RejectedConn int64
+	FramesIn     int64
+	FramesOut    int64
+	BytesIn      int64
+	BytesOut     int64
+}
+
+//汇总所有代理进程的统计，超过3个同步周期未更新的进程不计入当前连接数
+func GetWebsocketStatData(serviceName string) (*WebsocketStatData, error) {
+	counts, err := redis.Int64Map(RedisConfDo("HGETALL", RedisWebsocketStatPrefix+serviceName))
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := redis.StringMap(RedisConfDo("HGETALL", RedisWebsocketActivePrefix+serviceName))
+	if err != nil {
+		return nil, err
+	}
+	data := &WebsocketStatData{
+		TotalConn:    counts["total_conn"],
+		RejectedConn: counts["rejected_conn"],
+		FramesIn:     counts["frames_in"],
+		FramesOut:    counts["frames_out"],
+		BytesIn:      counts["bytes_in"],
+		BytesOut:     counts["bytes_out"],
+	}
+	expireAt := time.Now().Add(-3 * WebsocketStatSyncInterval).Unix()
+	for _, value := range nodes {
+		items := strings.Split(value, "|")
+		if len(items) != 2 {
+			continue
+		}
+		active, _ := strconv.ParseInt(items[0], 10, 64)
+		updateAt, _ := strconv.ParseInt(items[1], 10, 64)
+		if updateAt >= expireAt {
+			data.ActiveConn += active
+		}
+	}
+	return data, nil
+}
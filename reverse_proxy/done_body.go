@@ -0,0 +1,37 @@
+package reverse_proxy
+
+import (
+	"io"
+	"sync"
+)
+
+//响应体关闭时回调一次，用于统计请求结束
+type doneBody struct {
+	io.ReadCloser
+	once   sync.Once
+	doneFn func()
+}
+
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.doneFn)
+	return err
+}
+
+//101协议升级时 ReverseProxy 要求 Body 可写
+type doneRWBody struct {
+	*doneBody
+	w io.Writer
+}
+
+func (b *doneRWBody) Write(p []byte) (int, error) {
+	return b.w.Write(p)
+}
+
+func newDoneBody(body io.ReadCloser, doneFn func()) io.ReadCloser {
+	db := &doneBody{ReadCloser: body, doneFn: doneFn}
+	if w, ok := body.(io.Writer); ok {
+		return &doneRWBody{doneBody: db, w: w}
+	}
+	return db
+}
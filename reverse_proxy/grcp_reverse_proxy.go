@@ -2,26 +2,135 @@ package reverse_proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
-	"github.com/e421083458/grpc-proxy/proxy"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
-	"log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance) grpc.StreamHandler {
-	return func() grpc.StreamHandler {
-		nextAddr, err := lb.Get("")
+//balancer按stream返回负载均衡器，灰度分流时各分组不同，tlsConfig为空时与下游明文通信
+func NewGrpcLoadBalanceHandler(balancer func(stream grpc.ServerStream) (load_balance.LoadBalance, load_balance.Feedback, error), hashKey func(stream grpc.ServerStream) string, tlsConfig *tls.Config) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		lb, fb, err := balancer(stream)
 		if err != nil {
-			log.Fatal("get next addr fail")
+			return status.Error(codes.Unavailable, err.Error())
 		}
-		director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
-			c, err := grpc.DialContext(ctx, nextAddr, grpc.WithCodec(proxy.Codec()), grpc.WithInsecure())
-			md, _ := metadata.FromIncomingContext(ctx)
-			outCtx, _ := context.WithCancel(ctx)
-			outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
-			return outCtx, c, err
+		//每个stream单独选择下游
+		nextAddr, err := lb.Get(hashKey(stream))
+		if err != nil || nextAddr == "" {
+			return status.Error(codes.Unavailable, "get next addr fail")
 		}
-		return proxy.TransparentHandler(director)
-	}()
+		//耗时按收到下游响应header计算，长连接stream的存活时长不计入延迟
+		start := time.Now()
+		var headerCost int64
+		conn, err := grpcConnHandler.getConn(nextAddr, tlsConfig)
+		if err == nil {
+			err = proxyGrpcStream(stream, conn, func() {
+				atomic.StoreInt64(&headerCost, int64(time.Since(start)))
+			})
+			grpcConnHandler.release(nextAddr, tlsConfig)
+		}
+		cost := time.Duration(atomic.LoadInt64(&headerCost))
+		if cost == 0 {
+			cost = time.Since(start)
+		}
+		fb.Done(nextAddr, grpcUpstreamErr(err), cost)
+		return err
+	}
+}
+
+//不可用、超时及内部错误计入被动探活失败
+func grpcUpstreamErr(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal:
+		return err
+	}
+	return nil
+}
+
+var grpcConnHandler = &grpcConnPool{connMap: map[string]*grpcConnItem{}}
+
+//按下游地址复用连接，使用tls的连接按服务的tls配置区分
+type grpcConnPool struct {
+	connMap map[string]*grpcConnItem
+	locker  sync.Mutex
+}
+
+type grpcConnItem struct {
+	addr    string
+	conn    *grpc.ClientConn
+	streams int //进行中的stream数，为0时才可回收
+}
+
+func grpcConnKey(addr string, tlsConfig *tls.Config) string {
+	if tlsConfig == nil {
+		return addr
+	}
+	return fmt.Sprintf("%s|%p", addr, tlsConfig)
+}
+
+//取得连接并记为使用中，stream结束后需调用release
+func (p *grpcConnPool) getConn(addr string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	key := grpcConnKey(addr, tlsConfig)
+	if item, ok := p.connMap[key]; ok {
+		item.streams++
+		return item.conn, nil
+	}
+	security := grpc.WithInsecure()
+	if tlsConfig != nil {
+		security = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	//非阻塞建连，连接被多个stream共享，不绑定单次请求的ctx
+	c, err := grpc.DialContext(context.Background(), addr, grpc.WithCodec(GrpcCodec()), security)
+	if err != nil {
+		return nil, err
+	}
+	p.connMap[key] = &grpcConnItem{addr: addr, conn: c, streams: 1}
+	return c, nil
+}
+
+func (p *grpcConnPool) release(addr string, tlsConfig *tls.Config) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if item, ok := p.connMap[grpcConnKey(addr, tlsConfig)]; ok && item.streams > 0 {
+		item.streams--
+	}
+}
+
+//服务停止后关闭其tls配置对应的下游连接，明文连接由各服务共享，由PruneGrpcConns回收
+func CloseGrpcConns(tlsConfig *tls.Config) {
+	if tlsConfig == nil {
+		return
+	}
+	grpcConnHandler.locker.Lock()
+	defer grpcConnHandler.locker.Unlock()
+	suffix := grpcConnKey("", tlsConfig)
+	for key, item := range grpcConnHandler.connMap {
+		if strings.HasSuffix(key, suffix) {
+			item.conn.Close()
+			delete(grpcConnHandler.connMap, key)
+		}
+	}
+}
+
+//关闭下游地址已不在任何服务可用节点中的连接，仍有进行中stream的连接待下次回收
+func PruneGrpcConns(activeAddrs map[string]bool) {
+	grpcConnHandler.locker.Lock()
+	defer grpcConnHandler.locker.Unlock()
+	for key, item := range grpcConnHandler.connMap {
+		if activeAddrs[item.addr] || item.streams > 0 {
+			continue
+		}
+		item.conn.Close()
+		delete(grpcConnHandler.connMap, key)
+	}
 }
@@ -0,0 +1,135 @@
+package reverse_proxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/e421083458/grpc-proxy/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+)
+
+var grpcStreamDesc = &grpc.StreamDesc{
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+//透传的原始消息
+type grpcFrame struct {
+	payload []byte
+}
+
+//grpcFrame按原始字节透传，其余消息交给grpc-proxy的编解码器
+type grpcRawCodec struct {
+	parent grpc.Codec
+}
+
+//代理监听及下游连接使用的编解码器
+func GrpcCodec() grpc.Codec {
+	return &grpcRawCodec{parent: proxy.Codec()}
+}
+
+func (c *grpcRawCodec) Marshal(v interface{}) ([]byte, error) {
+	if out, ok := v.(*grpcFrame); ok {
+		return out.payload, nil
+	}
+	return c.parent.Marshal(v)
+}
+
+func (c *grpcRawCodec) Unmarshal(data []byte, v interface{}) error {
+	if dst, ok := v.(*grpcFrame); ok {
+		dst.payload = data
+		return nil
+	}
+	return c.parent.Unmarshal(data, v)
+}
+
+func (c *grpcRawCodec) String() string {
+	return fmt.Sprintf("gateway>%s", c.parent.String())
+}
+
+//双向透传stream，与grpc-proxy的TransparentHandler一致，但下游连接来自连接池，结束时不关闭
+//onHeader在收到下游响应header时回调，用于统计首包耗时
+func proxyGrpcStream(serverStream grpc.ServerStream, conn *grpc.ClientConn, onHeader func()) error {
+	fullMethodName, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return status.Error(codes.Internal, "lowLevelServerStream not exists in context")
+	}
+	md, _ := metadata.FromIncomingContext(serverStream.Context())
+	clientCtx, clientCancel := context.WithCancel(metadata.NewOutgoingContext(serverStream.Context(), md.Copy()))
+	defer clientCancel()
+	clientStream, err := grpc.NewClientStream(clientCtx, grpcStreamDesc, conn, fullMethodName)
+	if err != nil {
+		return err
+	}
+	s2cErrChan := forwardGrpcServerToClient(serverStream, clientStream)
+	c2sErrChan := forwardGrpcClientToServer(clientStream, serverStream, onHeader)
+	//两个方向哪个先结束不确定，客户端发送结束后继续等待下游响应
+	for i := 0; i < 2; i++ {
+		select {
+		case s2cErr := <-s2cErrChan:
+			if s2cErr != io.EOF {
+				clientCancel()
+				return status.Errorf(codes.Internal, "failed proxying s2c: %v", s2cErr)
+			}
+			clientStream.CloseSend()
+		case c2sErr := <-c2sErrChan:
+			serverStream.SetTrailer(clientStream.Trailer())
+			if c2sErr != io.EOF {
+				return c2sErr
+			}
+			return nil
+		}
+	}
+	return status.Error(codes.Internal, "gRPC proxying should never reach this stage")
+}
+
+//下游响应转发给客户端，首个消息前先转发下游header
+func forwardGrpcClientToServer(src grpc.ClientStream, dst grpc.ServerStream, onHeader func()) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		md, err := src.Header()
+		if err != nil {
+			ret <- err
+			return
+		}
+		onHeader()
+		if err := dst.SendHeader(md); err != nil {
+			ret <- err
+			return
+		}
+		f := &grpcFrame{}
+		for {
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err //io.EOF为正常结束
+				return
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				return
+			}
+		}
+	}()
+	return ret
+}
+
+//客户端请求转发给下游
+func forwardGrpcServerToClient(src grpc.ServerStream, dst grpc.ClientStream) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		f := &grpcFrame{}
+		for {
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err //io.EOF为正常结束
+				return
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				return
+			}
+		}
+	}()
+	return ret
+}
@@ -1,6 +1,7 @@
 package reverse_proxy
 
 import (
+	"fmt"
 	"github.com/e421083458/go_gateway/middleware"
 	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
 	"github.com/gin-gonic/gin"
@@ -8,16 +9,55 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
-func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport) *httputil.ReverseProxy {
+func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport, fb load_balance.Feedback, hashKey string, sticky *StickySession, retry *RetryConf, transform *ResponseTransform) *httputil.ReverseProxy {
+	//每个请求单独创建proxy，start用于统计当前尝试的下游响应耗时，hashKey供一致性hash选择节点
+	//sticky为空时不开启会话保持，retry为空时不重试，transform为空时不改写响应
+	var start time.Time
+	var attemptAddr string
+	attemptFb := fb
+	pinned := false
+	//每次尝试只回报一次：响应体关闭与ErrorHandler可能先后触发
+	var attemptOnce *sync.Once
+	attemptDone := func(addr string, err error, cost time.Duration) {
+		once, attemptFb := attemptOnce, attemptFb
+		once.Do(func() {
+			attemptFb.Done(addr, err, cost)
+		})
+	}
+	//客户端主动断开时只归还进行中计数，不计入耗时及失败
+	attemptRelease := func(addr string) {
+		once, pinned := attemptOnce, pinned
+		once.Do(func() {
+			if releaser, ok := lb.(load_balance.Releaser); ok && !pinned {
+				releaser.Release(addr)
+			}
+		})
+	}
 	//请求协调者
 	director := func(req *http.Request) {
-		nextAddr, err := lb.Get(req.URL.String())
-		//todo 优化点3
-		if err != nil || nextAddr=="" {
-			panic("get next addr fail")
+		start = time.Now()
+		attemptOnce = &sync.Once{}
+		nextAddr := ""
+		if sticky != nil {
+			nextAddr = sticky.pinnedAddr(req)
+		}
+		if nextAddr != "" {
+			//固定节点未经负载均衡选择，只回报给探活
+			pinned = true
+			attemptFb = sticky.Conf
+		} else {
+			var err error
+			nextAddr, err = lb.Get(hashKey)
+			//todo 优化点3
+			if err != nil || nextAddr == "" {
+				panic("get next addr fail")
+			}
 		}
+		attemptAddr = nextAddr
 		target, err := url.Parse(nextAddr)
 		if err != nil {
 			panic(err)
@@ -39,42 +79,87 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 
 	//更改内容
 	modifyFunc := func(resp *http.Response) error {
+		//5xx计入被动探活失败，响应体关闭时才算请求结束(websocket为连接断开时)
+		cost := time.Since(start)
+		var statusErr error
+		if resp.StatusCode >= http.StatusInternalServerError {
+			statusErr = fmt.Errorf("upstream status code %d", resp.StatusCode)
+		}
+		addr := upstreamAddr(resp.Request)
+		resp.Body = newDoneBody(resp.Body, func() {
+			attemptDone(addr, statusErr, cost)
+		})
+		if sticky != nil && !pinned {
+			resp.Header.Add("Set-Cookie", sticky.cookie(upstreamAddr(resp.Request)).String())
+		}
 		if strings.Contains(resp.Header.Get("Connection"), "Upgrade") {
 			return nil
 		}
 
-		//todo 优化点2
-		//var payload []byte
-		//var readErr error
-		//
-		//if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
-		//	gr, err := gzip.NewReader(resp.Body)
-		//	if err != nil {
-		//		return err
-		//	}
-		//	payload, readErr = ioutil.ReadAll(gr)
-		//	resp.Header.Del("Content-Encoding")
-		//} else {
-		//	payload, readErr = ioutil.ReadAll(resp.Body)
-		//}
-		//if readErr != nil {
-		//	return readErr
-		//}
-		//
-		//c.Set("status_code", resp.StatusCode)
-		//c.Set("payload", payload)
-		//resp.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
-		//resp.ContentLength = int64(len(payload))
-		//resp.Header.Set("Content-Length", strconv.FormatInt(int64(len(payload)), 10))
+		//响应改写，transform为空时不改写
+		if transform != nil {
+			return transform.Modify(resp)
+		}
 		return nil
 	}
 
 	//错误回调 ：关闭real_server时测试，错误回调
 	//范围：transport.RoundTrip发生的错误、以及ModifyResponse发生的错误
 	errFunc := func(w http.ResponseWriter, r *http.Request, err error) {
+		//客户端主动断开不计入下游失败
+		if r.Context().Err() != nil {
+			attemptRelease(attemptAddr)
+		} else {
+			attemptDone(attemptAddr, err, time.Since(start))
+		}
 		middleware.ResponseError(c,999,err)
 	}
-	return &httputil.ReverseProxy{Director: director, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if trans != nil {
+		transport = trans
+	}
+	if retry != nil {
+		transport = &retryTransport{
+			base: transport,
+			conf: retry,
+			next: func(tried map[string]bool) string {
+				//一致性hash等策略可能重复返回同一节点，最多尝试节点数次
+				for i := 0; i < retry.RetryNum+len(tried); i++ {
+					nextAddr, err := lb.Get(hashKey)
+					if err != nil {
+						return ""
+					}
+					if !tried[nextAddr] {
+						return nextAddr
+					}
+					if releaser, ok := lb.(load_balance.Releaser); ok {
+						releaser.Release(nextAddr)
+					}
+				}
+				return ""
+			},
+			release: func(addr string) {
+				if releaser, ok := lb.(load_balance.Releaser); ok {
+					releaser.Release(addr)
+				}
+			},
+			done: attemptDone,
+			onRetry: func(addr string) {
+				start = time.Now()
+				attemptOnce = &sync.Once{}
+				attemptAddr = addr
+				attemptFb = fb
+				pinned = false
+			},
+		}
+	}
+	return &httputil.ReverseProxy{Director: director, Transport: transport, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
+}
+
+//还原负载均衡返回的下游地址，如 http://127.0.0.1:2003
+func upstreamAddr(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host
 }
 
 func singleJoiningSlash(a, b string) string {
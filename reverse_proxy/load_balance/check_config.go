@@ -2,17 +2,18 @@ package load_balance
 
 import (
 	"fmt"
-	"net"
 	"reflect"
 	"sort"
+	"sync"
 	"time"
 )
 
 const (
 	//default check setting
-	DefaultCheckMethod    = 0
+	DefaultCheckMethod    = CheckMethodTCP
 	DefaultCheckTimeout   = 5
 	DefaultCheckMaxErrNum = 2
+	DefaultCheckRise      = 1
 	DefaultCheckInterval  = 5
 )
 
@@ -21,6 +22,17 @@ type LoadBalanceCheckConf struct {
 	confIpWeight map[string]string
 	activeList   []string
 	format       string
+	formatIp     map[string]string //格式化后地址与ip的映射
+	outliers     map[string]*outlierState
+	outlierConf  *OutlierConf        //被动探活配置，为空时使用默认值
+	breakerConf  *CircuitBreakerConf //节点熔断配置，为空时不开启
+	breakers     map[string]*CircuitBreaker
+	onBreaker    func(name string, state int)
+	checkConf    *CheckConf
+	checker      Checker
+	mu           sync.RWMutex
+	closeChan    chan struct{}
+	closeOnce    sync.Once
 }
 
 func (s *LoadBalanceCheckConf) Attach(o Observer) {
@@ -34,70 +46,145 @@ func (s *LoadBalanceCheckConf) NotifyAllObservers() {
 }
 
 func (s *LoadBalanceCheckConf) GetConf() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	confList := []string{}
+	breakList := []string{}
 	for _, ip := range s.activeList {
+		if state, ok := s.outliers[ip]; ok && state.ejected {
+			continue
+		}
 		weight, ok := s.confIpWeight[ip]
 		if !ok {
 			weight = "50" //默认weight
 		}
+		if breaker, ok := s.breakers[ip]; ok && breaker.State() == BreakerStateOpen {
+			breakList = append(breakList, fmt.Sprintf(s.format, ip)+","+weight)
+			continue
+		}
 		confList = append(confList, fmt.Sprintf(s.format, ip)+","+weight)
 	}
+	//节点全部熔断时不摘除，由服务级熔断返回降级内容
+	if len(confList) == 0 {
+		return breakList
+	}
 	return confList
 }
 
+//节点探活状态，连续成功rise次恢复，连续失败fall次摘除
+type checkState struct {
+	healthy bool
+	riseNum int
+	fallNum int
+}
+
+//记录一次探活结果，返回节点是否可用
+func (st *checkState) update(err error, rise, fall int) bool {
+	if err == nil {
+		st.fallNum = 0
+		st.riseNum++
+		if !st.healthy && st.riseNum >= rise {
+			st.healthy = true
+		}
+	} else {
+		st.riseNum = 0
+		st.fallNum++
+		if st.healthy && st.fallNum >= fall {
+			st.healthy = false
+		}
+	}
+	return st.healthy
+}
+
 //更新配置时，通知监听者也更新
 func (s *LoadBalanceCheckConf) WatchConf() {
 	//fmt.Println("watchConf")
 	go func() {
-		confIpErrNum := map[string]int{}
+		confIpState := map[string]*checkState{}
+		for item := range s.confIpWeight {
+			confIpState[item] = &checkState{healthy: true}
+		}
 		for {
+			//并发检查，单轮耗时不超过一个超时时间
+			checkErr := map[string]error{}
+			checkLocker := sync.Mutex{}
+			wg := sync.WaitGroup{}
+			for item := range s.confIpWeight {
+				wg.Add(1)
+				go func(addr string) {
+					defer wg.Done()
+					err := s.checker.Check(addr)
+					checkLocker.Lock()
+					checkErr[addr] = err
+					checkLocker.Unlock()
+				}(item)
+			}
+			wg.Wait()
+
 			changedList := []string{}
-			for item, _ := range s.confIpWeight {
-				conn, err := net.DialTimeout("tcp", item, time.Duration(DefaultCheckTimeout)*time.Second)
-				//todo http statuscode
-				if err == nil {
-					conn.Close()
-					if _, ok := confIpErrNum[item]; ok {
-						confIpErrNum[item] = 0
-					}
-				}
-				if err != nil {
-					if _, ok := confIpErrNum[item]; ok {
-						confIpErrNum[item] += 1
-					} else {
-						confIpErrNum[item] = 1
-					}
-				}
-				if confIpErrNum[item] < DefaultCheckMaxErrNum {
+			for item, state := range confIpState {
+				if state.update(checkErr[item], s.checkConf.rise(), s.checkConf.fall()) {
 					changedList = append(changedList, item)
 				}
 			}
 			sort.Strings(changedList)
-			sort.Strings(s.activeList)
-			if !reflect.DeepEqual(changedList, s.activeList) {
+			s.mu.RLock()
+			activeList := append([]string{}, s.activeList...)
+			s.mu.RUnlock()
+			sort.Strings(activeList)
+			if !reflect.DeepEqual(changedList, activeList) {
 				s.UpdateConf(changedList)
 			}
-			time.Sleep(time.Duration(DefaultCheckInterval) * time.Second)
+			select {
+			case <-s.closeChan:
+				return
+			case <-time.After(s.checkConf.interval()):
+			}
 		}
 	}()
 }
 
+//停止探活，服务删除或配置变更时调用
+func (s *LoadBalanceCheckConf) CloseWatch() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+	})
+}
+
 //更新配置时，通知监听者也更新
 func (s *LoadBalanceCheckConf) UpdateConf(conf []string) {
 	//fmt.Println("UpdateConf", conf)
+	s.mu.Lock()
 	s.activeList = conf
+	s.mu.Unlock()
 	for _, obs := range s.observers {
 		obs.Update()
 	}
 }
 
-func NewLoadBalanceCheckConf(format string, conf map[string]string) (*LoadBalanceCheckConf, error) {
+//checkConf为nil时使用默认的tcp探活
+func NewLoadBalanceCheckConf(format string, conf map[string]string, checkConf *CheckConf) (*LoadBalanceCheckConf, error) {
 	aList := []string{}
+	formatIp := map[string]string{}
 	//默认初始化
 	for item, _ := range conf {
 		aList = append(aList, item)
+		formatIp[fmt.Sprintf(format, item)] = item
+	}
+	if checkConf == nil {
+		checkConf = &CheckConf{Method: DefaultCheckMethod}
+	}
+	mConf := &LoadBalanceCheckConf{
+		format:       format,
+		formatIp:     formatIp,
+		outliers:     map[string]*outlierState{},
+		breakers:     map[string]*CircuitBreaker{},
+		activeList:   aList,
+		confIpWeight: conf,
+		checkConf:    checkConf,
+		checker:      NewChecker(format, checkConf),
+		closeChan:    make(chan struct{}),
 	}
-	mConf := &LoadBalanceCheckConf{format: format, activeList: aList, confIpWeight: conf}
 	mConf.WatchConf()
 	return mConf, nil
 }
\ No newline at end of file
@@ -0,0 +1,197 @@
+package load_balance
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CheckMethodTCP  = 0
+	CheckMethodHTTP = 1
+	CheckMethodGRPC = 2
+)
+
+//探活配置，零值字段使用默认值
+type CheckConf struct {
+	Method     int           //检查方法 0=tcp 1=http 2=grpc
+	Timeout    time.Duration //单次检查超时
+	Interval   time.Duration //检查间隔
+	Rise       int           //连续成功多少次标记为可用
+	Fall       int           //连续失败多少次标记为不可用
+	HttpPath   string        //http检查路径
+	HttpStatus string        //http期望状态码范围，如 200-399
+	HttpBody   string        //http响应体需包含的内容，为空不检查
+	TLSConfig  *tls.Config   //https及grpc检查使用的tls配置，为空时https使用默认配置、grpc不加密
+}
+
+func (cc *CheckConf) timeout() time.Duration {
+	if cc.Timeout > 0 {
+		return cc.Timeout
+	}
+	return time.Duration(DefaultCheckTimeout) * time.Second
+}
+
+func (cc *CheckConf) interval() time.Duration {
+	if cc.Interval > 0 {
+		return cc.Interval
+	}
+	return time.Duration(DefaultCheckInterval) * time.Second
+}
+
+func (cc *CheckConf) rise() int {
+	if cc.Rise > 0 {
+		return cc.Rise
+	}
+	return DefaultCheckRise
+}
+
+func (cc *CheckConf) fall() int {
+	if cc.Fall > 0 {
+		return cc.Fall
+	}
+	return DefaultCheckMaxErrNum
+}
+
+//单个节点的探活方式
+type Checker interface {
+	Check(addr string) error
+}
+
+func NewChecker(format string, conf *CheckConf) Checker {
+	switch conf.Method {
+	case CheckMethodHTTP:
+		return NewHttpChecker(format, conf)
+	case CheckMethodGRPC:
+		return &GrpcChecker{timeout: conf.timeout(), tlsConfig: conf.TLSConfig}
+	default:
+		return &TcpChecker{timeout: conf.timeout()}
+	}
+}
+
+//检测端口是否握手成功
+type TcpChecker struct {
+	timeout time.Duration
+}
+
+func (tc *TcpChecker) Check(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, tc.timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+//GET请求检查状态码及响应体
+type HttpChecker struct {
+	format    string
+	path      string
+	statusMin int
+	statusMax int
+	body      string
+	client    *http.Client
+}
+
+func NewHttpChecker(format string, conf *CheckConf) *HttpChecker {
+	statusMin, statusMax := ParseStatusRange(conf.HttpStatus)
+	client := &http.Client{Timeout: conf.timeout()}
+	if conf.TLSConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: conf.TLSConfig, DisableKeepAlives: true}
+	}
+	return &HttpChecker{
+		format:    format,
+		path:      conf.HttpPath,
+		statusMin: statusMin,
+		statusMax: statusMax,
+		body:      conf.HttpBody,
+		client:    client,
+	}
+}
+
+func (hc *HttpChecker) Check(addr string) error {
+	target := fmt.Sprintf(hc.format, addr)
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	path := hc.path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	resp, err := hc.client.Get(strings.TrimSuffix(target, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < hc.statusMin || resp.StatusCode > hc.statusMax {
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if hc.body == "" {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(payload), hc.body) {
+		return errors.New("response body not matched")
+	}
+	return nil
+}
+
+//grpc.health.v1 Check，服务名为空表示检查整体状态
+type GrpcChecker struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+func (gc *GrpcChecker) Check(addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gc.timeout)
+	defer cancel()
+	security := grpc.WithInsecure()
+	if gc.tlsConfig != nil {
+		security = grpc.WithTransportCredentials(credentials.NewTLS(gc.tlsConfig))
+	}
+	conn, err := grpc.DialContext(ctx, addr, security, grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status %v", resp.Status)
+	}
+	return nil
+}
+
+//解析状态码范围，支持 200 或 200-399，为空或格式错误时使用 200-399
+func ParseStatusRange(status string) (int, int) {
+	items := strings.Split(strings.TrimSpace(status), "-")
+	min, err := strconv.Atoi(strings.TrimSpace(items[0]))
+	if err != nil {
+		return 200, 399
+	}
+	if len(items) == 1 {
+		return min, min
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(items[1]))
+	if err != nil || max < min {
+		return 200, 399
+	}
+	return min, max
+}
@@ -0,0 +1,182 @@
+package load_balance
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+//获取一个已关闭的本地端口
+func closedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestParseStatusRange(t *testing.T) {
+	cases := []struct {
+		status string
+		min    int
+		max    int
+	}{
+		{"", 200, 399},
+		{"200", 200, 200},
+		{"200-299", 200, 299},
+		{" 200 - 204 ", 200, 204},
+		{"300-200", 200, 399},
+		{"abc", 200, 399},
+		{"200-abc", 200, 399},
+	}
+	for _, c := range cases {
+		min, max := ParseStatusRange(c.status)
+		if min != c.min || max != c.max {
+			t.Errorf("status %q expect %d-%d, got %d-%d", c.status, c.min, c.max, min, max)
+		}
+	}
+}
+
+func TestTcpChecker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	checker := NewChecker("%s", &CheckConf{Method: CheckMethodTCP, Timeout: time.Second})
+	if err := checker.Check(ln.Addr().String()); err != nil {
+		t.Fatalf("expect open port ok, got %v", err)
+	}
+	if err := checker.Check(closedAddr(t)); err == nil {
+		t.Fatal("expect closed port fail")
+	}
+}
+
+func TestHttpChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte("status ok"))
+		case "/redirect":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	cases := []struct {
+		name string
+		conf *CheckConf
+		addr string
+		ok   bool
+	}{
+		{"status ok", &CheckConf{HttpPath: "/health"}, addr, true},
+		{"path without slash", &CheckConf{HttpPath: "health"}, addr, true},
+		{"body matched", &CheckConf{HttpPath: "/health", HttpBody: "ok"}, addr, true},
+		{"body not matched", &CheckConf{HttpPath: "/health", HttpBody: "fail"}, addr, false},
+		{"status out of range", &CheckConf{HttpPath: "/error"}, addr, false},
+		{"status in custom range", &CheckConf{HttpPath: "/error", HttpStatus: "500-599"}, addr, true},
+		{"status exact", &CheckConf{HttpPath: "/redirect", HttpStatus: "200"}, addr, false},
+		{"closed port", &CheckConf{HttpPath: "/health"}, closedAddr(t), false},
+	}
+	for _, c := range cases {
+		c.conf.Method = CheckMethodHTTP
+		c.conf.Timeout = time.Second
+		err := NewChecker("%s", c.conf).Check(c.addr)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expect ok=%v, got err %v", c.name, c.ok, err)
+		}
+	}
+}
+
+func TestGrpcChecker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go s.Serve(ln)
+	defer s.Stop()
+
+	checker := NewChecker("%s", &CheckConf{Method: CheckMethodGRPC, Timeout: time.Second})
+	if err := checker.Check(ln.Addr().String()); err != nil {
+		t.Fatalf("expect serving ok, got %v", err)
+	}
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if err := checker.Check(ln.Addr().String()); err == nil {
+		t.Fatal("expect not serving fail")
+	}
+	if err := checker.Check(closedAddr(t)); err == nil {
+		t.Fatal("expect closed port fail")
+	}
+}
+
+func TestCheckStateRiseFall(t *testing.T) {
+	errCheck := errors.New("check fail")
+	//rise=2 fall=3：连续失败3次摘除，连续成功2次恢复，中途结果交替时计数重置
+	results := []struct {
+		err     error
+		healthy bool
+	}{
+		{errCheck, true},
+		{errCheck, true},
+		{nil, true},
+		{errCheck, true},
+		{errCheck, true},
+		{errCheck, false},
+		{nil, false},
+		{errCheck, false},
+		{nil, false},
+		{nil, true},
+	}
+	state := &checkState{healthy: true}
+	for i, r := range results {
+		if healthy := state.update(r.err, 2, 3); healthy != r.healthy {
+			t.Fatalf("step %d expect healthy=%v, got %v", i, r.healthy, healthy)
+		}
+	}
+}
+
+func TestLoadBalanceCheckConfEject(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	openAddr := ln.Addr().String()
+	downAddr := closedAddr(t)
+	conf, err := NewLoadBalanceCheckConf("%s", map[string]string{openAddr: "50", downAddr: "50"}, &CheckConf{
+		Timeout:  100 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		Fall:     2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.CloseWatch()
+
+	//连续失败fall次后关闭端口的节点被摘除
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		list := conf.GetConf()
+		if len(list) == 1 && list[0] == openAddr+",50" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect only %v active, got %v", openAddr, conf.GetConf())
+}
@@ -0,0 +1,256 @@
+package load_balance
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed = iota
+	BreakerStateOpen
+	BreakerStateHalfOpen
+)
+
+const (
+	//default breaker setting
+	DefaultBreakerWindow        = 10   //统计窗口，单位s
+	DefaultBreakerMinRequests   = 20   //窗口内请求数达到后才判断是否熔断
+	DefaultBreakerOpenTime      = 30   //熔断时长，单位s，之后进入半开
+	DefaultBreakerHalfOpenCalls = 5    //半开状态放行的探测请求数，也是恢复所需的成功次数
+	DefaultBreakerSlowTime      = 1000 //慢调用耗时，单位ms
+	breakerBucketNum            = 10   //窗口按桶滚动统计
+)
+
+var BreakerStateMap = map[int]string{
+	BreakerStateClosed:   "closed",
+	BreakerStateOpen:     "open",
+	BreakerStateHalfOpen: "half_open",
+}
+
+//熔断配置，ErrorPercent及SlowPercent为0时不按该条件熔断
+type CircuitBreakerConf struct {
+	ErrorPercent  int
+	SlowPercent   int
+	SlowTime      time.Duration
+	MinRequests   int
+	Window        time.Duration
+	OpenTime      time.Duration
+	HalfOpenCalls int
+}
+
+type breakerBucket struct {
+	id     int64
+	total  int64
+	errors int64
+	slow   int64
+}
+
+//熔断器：closed 时按滚动窗口统计错误率及慢调用比例，超过阈值进入 open 拒绝请求，
+//open 持续 OpenTime 后进入 half_open 放行少量探测请求，全部成功则恢复 closed，否则重新 open
+type CircuitBreaker struct {
+	Name          string
+	conf          CircuitBreakerConf
+	mu            sync.Mutex
+	state         int
+	stateAt       time.Time
+	buckets       [breakerBucketNum]breakerBucket
+	probes        int
+	probeSuccess  int
+	onStateChange func(name string, state int)
+}
+
+func NewCircuitBreaker(name string, conf *CircuitBreakerConf) *CircuitBreaker {
+	c := *conf
+	if c.Window <= 0 {
+		c.Window = DefaultBreakerWindow * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = DefaultBreakerMinRequests
+	}
+	if c.OpenTime <= 0 {
+		c.OpenTime = DefaultBreakerOpenTime * time.Second
+	}
+	if c.HalfOpenCalls <= 0 {
+		c.HalfOpenCalls = DefaultBreakerHalfOpenCalls
+	}
+	if c.SlowTime <= 0 {
+		c.SlowTime = DefaultBreakerSlowTime * time.Millisecond
+	}
+	return &CircuitBreaker{
+		Name:    name,
+		conf:    c,
+		stateAt: time.Now(),
+	}
+}
+
+//状态变化时回调，回调在锁外执行
+func (b *CircuitBreaker) SetOnStateChange(fn func(name string, state int)) {
+	b.onStateChange = fn
+}
+
+//当前状态，open超过熔断时长时视为half_open
+func (b *CircuitBreaker) State() int {
+	b.mu.Lock()
+	changed := b.checkOpenTimeout()
+	state := b.state
+	b.mu.Unlock()
+	if changed {
+		b.notify(state)
+	}
+	return state
+}
+
+//请求前调用，返回false时直接返回降级内容
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	changed := b.checkOpenTimeout()
+	allow := true
+	switch b.state {
+	case BreakerStateOpen:
+		allow = false
+	case BreakerStateHalfOpen:
+		//探测请求未回报(如未转发到下游)时，超过熔断时长重新放行
+		if b.probes >= b.conf.HalfOpenCalls && time.Since(b.stateAt) >= b.conf.OpenTime {
+			b.setState(BreakerStateHalfOpen)
+			changed = true
+		}
+		if b.probes >= b.conf.HalfOpenCalls {
+			allow = false
+		} else {
+			b.probes++
+		}
+	}
+	state := b.state
+	b.mu.Unlock()
+	if changed {
+		b.notify(state)
+	}
+	return allow
+}
+
+//请求结束时回调，err非空为失败，cost超过慢调用耗时为慢调用
+func (b *CircuitBreaker) Done(addr string, err error, cost time.Duration) {
+	failed := err != nil
+	slow := b.conf.SlowPercent > 0 && cost >= b.conf.SlowTime
+	b.mu.Lock()
+	changed := b.checkOpenTimeout()
+	switch b.state {
+	case BreakerStateOpen:
+	case BreakerStateHalfOpen:
+		if failed || slow {
+			b.setState(BreakerStateOpen)
+			changed = true
+			break
+		}
+		b.probeSuccess++
+		if b.probeSuccess >= b.conf.HalfOpenCalls {
+			b.setState(BreakerStateClosed)
+			changed = true
+		}
+	default:
+		if b.record(failed, slow) {
+			b.setState(BreakerStateOpen)
+			changed = true
+		}
+	}
+	state := b.state
+	b.mu.Unlock()
+	if changed {
+		b.notify(state)
+	}
+}
+
+//记录到当前桶，返回是否达到熔断条件
+func (b *CircuitBreaker) record(failed, slow bool) bool {
+	width := int64(b.conf.Window) / breakerBucketNum
+	if width <= 0 {
+		width = 1
+	}
+	id := time.Now().UnixNano() / width
+	bucket := &b.buckets[id%breakerBucketNum]
+	if bucket.id != id {
+		*bucket = breakerBucket{id: id}
+	}
+	bucket.total++
+	if failed {
+		bucket.errors++
+	}
+	if slow {
+		bucket.slow++
+	}
+
+	var total, errors, slows int64
+	for _, item := range b.buckets {
+		if id-item.id < breakerBucketNum {
+			total += item.total
+			errors += item.errors
+			slows += item.slow
+		}
+	}
+	if total < int64(b.conf.MinRequests) {
+		return false
+	}
+	if b.conf.ErrorPercent > 0 && errors*100 >= int64(b.conf.ErrorPercent)*total {
+		return true
+	}
+	if b.conf.SlowPercent > 0 && slows*100 >= int64(b.conf.SlowPercent)*total {
+		return true
+	}
+	return false
+}
+
+func (b *CircuitBreaker) checkOpenTimeout() bool {
+	if b.state == BreakerStateOpen && time.Since(b.stateAt) >= b.conf.OpenTime {
+		b.setState(BreakerStateHalfOpen)
+		return true
+	}
+	return false
+}
+
+func (b *CircuitBreaker) setState(state int) {
+	b.state = state
+	b.stateAt = time.Now()
+	b.probes = 0
+	b.probeSuccess = 0
+	b.buckets = [breakerBucketNum]breakerBucket{}
+}
+
+func (b *CircuitBreaker) notify(state int) {
+	if b.onStateChange != nil {
+		b.onStateChange(b.Name, state)
+	}
+}
+
+//开启节点熔断，熔断中的节点从可用列表中摘除，半开时重新加入
+//节点选择不经过Allow，半开时不限制探测请求数，连续HalfOpenCalls次成功后恢复，任一失败重新熔断
+func (s *LoadBalanceCheckConf) SetNodeBreaker(conf *CircuitBreakerConf, onStateChange func(name string, state int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakerConf = conf
+	s.onBreaker = onStateChange
+}
+
+func (s *LoadBalanceCheckConf) nodeBreaker(ip, addr string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.breakerConf == nil {
+		return nil
+	}
+	if breaker, ok := s.breakers[ip]; ok {
+		return breaker
+	}
+	breaker := NewCircuitBreaker(addr, s.breakerConf)
+	openTime := breaker.conf.OpenTime
+	onBreaker := s.onBreaker
+	breaker.SetOnStateChange(func(name string, state int) {
+		if onBreaker != nil {
+			onBreaker(name, state)
+		}
+		if state == BreakerStateOpen {
+			time.AfterFunc(openTime, s.NotifyAllObservers)
+			s.NotifyAllObservers()
+		}
+	})
+	s.breakers[ip] = breaker
+	return breaker
+}
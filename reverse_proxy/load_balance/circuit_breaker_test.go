@@ -0,0 +1,105 @@
+package load_balance
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker("test", &CircuitBreakerConf{
+		ErrorPercent:  50,
+		MinRequests:   4,
+		OpenTime:      50 * time.Millisecond,
+		HalfOpenCalls: 2,
+	})
+	upstreamErr := errors.New("upstream status code 502")
+
+	//请求数不足时不熔断
+	cb.Done("", upstreamErr, time.Millisecond)
+	cb.Done("", upstreamErr, time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("expect closed before min requests")
+	}
+
+	//错误率达到50%熔断
+	cb.Done("", nil, time.Millisecond)
+	cb.Done("", upstreamErr, time.Millisecond)
+	if cb.Allow() || cb.State() != BreakerStateOpen {
+		t.Fatal("expect open")
+	}
+
+	//熔断时长后半开，只放行探测请求数
+	time.Sleep(60 * time.Millisecond)
+	if !cb.Allow() || !cb.Allow() || cb.Allow() {
+		t.Fatal("expect 2 probes in half open")
+	}
+	cb.Done("", nil, time.Millisecond)
+	cb.Done("", nil, time.Millisecond)
+	if cb.State() != BreakerStateClosed {
+		t.Fatal("expect closed after probes succeed")
+	}
+}
+
+func TestCircuitBreakerSlowCall(t *testing.T) {
+	cb := NewCircuitBreaker("test", &CircuitBreakerConf{
+		SlowPercent: 50,
+		SlowTime:    10 * time.Millisecond,
+		MinRequests: 2,
+		OpenTime:    50 * time.Millisecond,
+	})
+	cb.Done("", nil, time.Millisecond)
+	cb.Done("", nil, 20*time.Millisecond)
+	if cb.State() != BreakerStateOpen {
+		t.Fatal("expect open on slow calls")
+	}
+
+	//半开时探测失败重新熔断
+	time.Sleep(60 * time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("expect probe allowed")
+	}
+	cb.Done("", nil, 20*time.Millisecond)
+	if cb.State() != BreakerStateOpen {
+		t.Fatal("expect open after probe failed")
+	}
+}
+
+func TestNodeBreaker(t *testing.T) {
+	conf := &LoadBalanceCheckConf{
+		format:       "http://%s",
+		formatIp:     map[string]string{"http://127.0.0.1:2001": "127.0.0.1:2001", "http://127.0.0.1:2002": "127.0.0.1:2002"},
+		activeList:   []string{"127.0.0.1:2001", "127.0.0.1:2002"},
+		confIpWeight: map[string]string{"127.0.0.1:2001": "50", "127.0.0.1:2002": "50"},
+		outliers:     map[string]*outlierState{},
+		breakers:     map[string]*CircuitBreaker{},
+	}
+	conf.SetNodeBreaker(&CircuitBreakerConf{
+		ErrorPercent:  50,
+		MinRequests:   2,
+		OpenTime:      50 * time.Millisecond,
+		HalfOpenCalls: 2,
+	}, nil)
+	upstreamErr := errors.New("upstream status code 502")
+
+	conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	if list := conf.GetConf(); len(list) != 1 || list[0] != "http://127.0.0.1:2002,50" {
+		t.Fatalf("expect open node removed, got %v", list)
+	}
+
+	//半开时节点重新加入，不限制探测请求数，连续成功HalfOpenCalls次后恢复
+	time.Sleep(60 * time.Millisecond)
+	if list := conf.GetConf(); len(list) != 2 {
+		t.Fatalf("expect half open node added, got %v", list)
+	}
+	breaker := conf.breakers["127.0.0.1:2001"]
+	conf.Done("http://127.0.0.1:2001", nil, time.Millisecond)
+	if breaker.State() != BreakerStateHalfOpen {
+		t.Fatal("expect half open before enough successes")
+	}
+	conf.Done("http://127.0.0.1:2001", nil, time.Millisecond)
+	if breaker.State() != BreakerStateClosed {
+		t.Fatal("expect closed after successes")
+	}
+}
@@ -2,9 +2,11 @@ package load_balance
 
 // 配置主题
 type LoadBalanceConf interface {
+	Feedback
 	Attach(o Observer)
 	GetConf() []string
 	WatchConf()
+	CloseWatch()
 	UpdateConf(conf []string)
 }
 
@@ -4,10 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	DefaultHashReplicas = 10 //默认虚拟节点数
 )
 
 type Hash func(data []byte) uint32
@@ -33,15 +39,26 @@ type ConsistentHashBanlance struct {
 	keys     UInt32Slice       //已排序的节点hash切片
 	hashMap  map[uint32]string //节点哈希和Key的map,键是hash值，值是节点key
 
+	//有界负载，节点进行中请求数不超过 平均值*loadFactor，为0时不限制
+	loadFactor float64
+	nodes      map[string]bool
+	loads      map[string]int64
+	totalLoad  int64
+
 	//观察主体
 	conf LoadBalanceConf
 }
 
 func NewConsistentHashBanlance(replicas int, fn Hash) *ConsistentHashBanlance {
+	if replicas <= 0 {
+		replicas = DefaultHashReplicas
+	}
 	m := &ConsistentHashBanlance{
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[uint32]string),
+		nodes:    map[string]bool{},
+		loads:    map[string]int64{},
 	}
 	if m.hash == nil {
 		//最多32位,保证是一个2^32-1环
@@ -50,6 +67,13 @@ func NewConsistentHashBanlance(replicas int, fn Hash) *ConsistentHashBanlance {
 	return m
 }
 
+//设置有界负载系数，如1.25表示节点负载最多为平均值的1.25倍
+func (c *ConsistentHashBanlance) SetLoadFactor(loadFactor float64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.loadFactor = loadFactor
+}
+
 // 验证是否为空
 func (c *ConsistentHashBanlance) IsEmpty() bool {
 	return len(c.keys) == 0
@@ -63,6 +87,7 @@ func (c *ConsistentHashBanlance) Add(params ...string) error {
 	addr := params[0]
 	c.mux.Lock()
 	defer c.mux.Unlock()
+	c.nodes[addr] = true
 	// 结合复制因子计算所有虚拟节点的hash值，并存入m.keys中，同时在m.hashMap中保存哈希值和key的映射
 	for i := 0; i < c.replicas; i++ {
 		hash := c.hash([]byte(strconv.Itoa(i) + addr))
@@ -76,6 +101,8 @@ func (c *ConsistentHashBanlance) Add(params ...string) error {
 
 // Get 方法根据给定的对象获取最靠近它的那个节点
 func (c *ConsistentHashBanlance) Get(key string) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 	if c.IsEmpty() {
 		return "", errors.New("node is empty")
 	}
@@ -88,9 +115,36 @@ func (c *ConsistentHashBanlance) Get(key string) (string, error) {
 	if idx == len(c.keys) {
 		idx = 0
 	}
-	c.mux.RLock()
-	defer c.mux.RUnlock()
-	return c.hashMap[c.keys[idx]], nil
+	addr := c.hashMap[c.keys[idx]]
+
+	// 有界负载：最优节点已满时沿环顺时针找第一个未满的节点
+	if c.loadFactor > 0 {
+		maxLoad := math.Ceil(float64(c.totalLoad+1) / float64(len(c.nodes)) * c.loadFactor)
+		for i := 0; i < len(c.keys); i++ {
+			node := c.hashMap[c.keys[(idx+i)%len(c.keys)]]
+			if float64(c.loads[node]+1) <= maxLoad {
+				addr = node
+				break
+			}
+		}
+		c.loads[addr]++
+		c.totalLoad++
+	}
+	return addr, nil
+}
+
+//请求结束时回调，释放有界负载计数
+func (c *ConsistentHashBanlance) Done(addr string, err error, cost time.Duration) {
+	c.Release(addr)
+}
+
+func (c *ConsistentHashBanlance) Release(addr string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.loads[addr] > 0 {
+		c.loads[addr]--
+		c.totalLoad--
+	}
 }
 
 func (c *ConsistentHashBanlance) SetConf(conf LoadBalanceConf) {
@@ -100,9 +154,11 @@ func (c *ConsistentHashBanlance) SetConf(conf LoadBalanceConf) {
 func (c *ConsistentHashBanlance) Update() {
 	if conf, ok := c.conf.(*LoadBalanceCheckConf); ok {
 		fmt.Println("Update get check conf:", conf.GetConf())
+		c.mux.Lock()
 		c.keys = nil
 		c.hashMap = map[uint32]string{}
-		fmt.Println("conf.GetConf()", conf.GetConf())
+		c.nodes = map[string]bool{}
+		c.mux.Unlock()
 		for _, ip := range conf.GetConf() {
 			c.Add(strings.Split(ip, ",")...)
 		}
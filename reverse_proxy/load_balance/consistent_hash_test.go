@@ -3,6 +3,7 @@ package load_balance
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestNewConsistentHashBanlance(t *testing.T) {
@@ -23,4 +24,33 @@ func TestNewConsistentHashBanlance(t *testing.T) {
 	fmt.Println(rb.Get("127.0.0.1"))
 	fmt.Println(rb.Get("192.168.0.1"))
 	fmt.Println(rb.Get("127.0.0.1"))
-}
\ No newline at end of file
+}
+func TestConsistentHashBoundedLoad(t *testing.T) {
+	rb := NewConsistentHashBanlance(100, nil)
+	rb.SetLoadFactor(1.25)
+	rb.Add("127.0.0.1:2003") //0
+	rb.Add("127.0.0.1:2004") //1
+	rb.Add("127.0.0.1:2005") //2
+	first, _ := rb.Get("192.168.0.1")
+	rb.Done(first, nil, time.Millisecond)
+
+	//同一个key持续请求时，单节点负载不超过 ceil(平均值*1.25)
+	for i := 0; i < 30; i++ {
+		rb.Get("127.0.0.1")
+	}
+	for addr, load := range rb.loads {
+		if load > 13 {
+			t.Fatalf("node %v overloaded: %v", addr, rb.loads)
+		}
+	}
+
+	//请求结束后负载释放，key重新落回原节点
+	for addr, load := range rb.loads {
+		for i := int64(0); i < load; i++ {
+			rb.Done(addr, nil, time.Millisecond)
+		}
+	}
+	if addr, _ := rb.Get("192.168.0.1"); addr != first {
+		t.Fatalf("expect %v, got %v", first, addr)
+	}
+}
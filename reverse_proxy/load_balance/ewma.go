@@ -0,0 +1,126 @@
+package load_balance
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultEwmaInitCost = 10 * time.Millisecond //新节点的初始耗时估计
+	DefaultEwmaDecay    = 10 * time.Second      //衰减时间常数，越大历史耗时影响越久
+	DefaultEwmaPenalty  = time.Second           //失败请求按此耗时计入
+)
+
+type ewmaNode struct {
+	cost    float64 //耗时估计，单位ns
+	stamp   time.Time
+	pending int64
+}
+
+//peak ewma，按 耗时估计*(进行中请求+1) 选择得分最低的节点
+//耗时变大时立即取峰值，变小时按时间衰减平滑
+type EwmaBalance struct {
+	mux   sync.Mutex
+	rss   []string
+	nodes map[string]*ewmaNode
+	//观察主体
+	conf LoadBalanceConf
+}
+
+func NewEwmaBalance() *EwmaBalance {
+	return &EwmaBalance{nodes: map[string]*ewmaNode{}}
+}
+
+func (r *EwmaBalance) Add(params ...string) error {
+	if len(params) == 0 {
+		return errors.New("param len 1 at least")
+	}
+	addr := params[0]
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.rss = append(r.rss, addr)
+	if _, ok := r.nodes[addr]; !ok {
+		r.nodes[addr] = &ewmaNode{cost: float64(DefaultEwmaInitCost), stamp: time.Now()}
+	}
+	return nil
+}
+
+func (r *EwmaBalance) Next() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	lens := len(r.rss)
+	if lens == 0 {
+		return ""
+	}
+	//随机起点，得分相同时避免总是命中第一个节点
+	offset := rand.Intn(lens)
+	best := ""
+	bestScore := math.MaxFloat64
+	for i := 0; i < lens; i++ {
+		addr := r.rss[(offset+i)%lens]
+		node := r.nodes[addr]
+		score := node.cost * float64(node.pending+1)
+		if score < bestScore {
+			best = addr
+			bestScore = score
+		}
+	}
+	r.nodes[best].pending++
+	return best
+}
+
+func (r *EwmaBalance) Get(key string) (string, error) {
+	return r.Next(), nil
+}
+
+//请求结束时回调，更新耗时估计并释放进行中计数
+func (r *EwmaBalance) Done(addr string, err error, cost time.Duration) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	node, ok := r.nodes[addr]
+	if !ok {
+		return
+	}
+	if node.pending > 0 {
+		node.pending--
+	}
+	if err != nil && cost < DefaultEwmaPenalty {
+		cost = DefaultEwmaPenalty
+	}
+	now := time.Now()
+	rtt := float64(cost)
+	if rtt > node.cost {
+		node.cost = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(node.stamp)) / float64(DefaultEwmaDecay))
+		node.cost = node.cost*w + rtt*(1-w)
+	}
+	node.stamp = now
+}
+
+func (r *EwmaBalance) Release(addr string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if node, ok := r.nodes[addr]; ok && node.pending > 0 {
+		node.pending--
+	}
+}
+
+func (r *EwmaBalance) SetConf(conf LoadBalanceConf) {
+	r.conf = conf
+}
+
+func (r *EwmaBalance) Update() {
+	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
+		r.mux.Lock()
+		r.rss = nil
+		r.mux.Unlock()
+		for _, ip := range conf.GetConf() {
+			r.Add(strings.Split(ip, ",")...)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package load_balance
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEwmaBalance(t *testing.T) {
+	rb := NewEwmaBalance()
+	rb.Add("127.0.0.1:2003") //0
+	rb.Add("127.0.0.1:2004") //1
+
+	//2004变慢后，请求应偏向2003
+	rb.Done("127.0.0.1:2003", nil, 5*time.Millisecond)
+	rb.Done("127.0.0.1:2004", nil, 200*time.Millisecond)
+	picks := map[string]int{}
+	for i := 0; i < 10; i++ {
+		addr := rb.Next()
+		picks[addr]++
+		rb.Done(addr, nil, time.Millisecond)
+	}
+	if picks["127.0.0.1:2003"] <= picks["127.0.0.1:2004"] {
+		t.Fatalf("expect fast node preferred, got %v", picks)
+	}
+
+	//进行中请求堆积时，分流到其他节点
+	rb2 := NewEwmaBalance()
+	rb2.Add("127.0.0.1:2003")
+	rb2.Add("127.0.0.1:2004")
+	used := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		used[rb2.Next()] = true
+	}
+	if len(used) != 2 {
+		t.Fatalf("expect 2 nodes used, got %v", used)
+	}
+
+	//失败按惩罚耗时计入
+	rb2.Done("127.0.0.1:2004", errors.New("upstream status code 502"), time.Millisecond)
+	if cost := rb2.nodes["127.0.0.1:2004"].cost; cost < float64(DefaultEwmaPenalty) {
+		t.Fatalf("expect penalty cost, got %v", time.Duration(cost))
+	}
+}
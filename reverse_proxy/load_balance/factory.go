@@ -7,24 +7,39 @@ const (
 	LbRoundRobin
 	LbWeightRoundRobin
 	LbConsistentHash
+	LbLeastConn
+	LbP2C
+	LbEwma
 )
 
+//一致性hash配置，Replicas为0时使用默认虚拟节点数，LoadFactor为0时不限制节点负载
+type HashConf struct {
+	Replicas   int
+	LoadFactor float64
+}
+
 func LoadBanlanceFactory(lbType LbType) LoadBalance {
 	switch lbType {
 	case LbRandom:
 		return &RandomBalance{}
 	case LbConsistentHash:
-		return NewConsistentHashBanlance(10, nil)
+		return NewConsistentHashBanlance(DefaultHashReplicas, nil)
 	case LbRoundRobin:
 		return &RoundRobinBalance{}
 	case LbWeightRoundRobin:
 		return &WeightRoundRobinBalance{}
+	case LbLeastConn:
+		return NewLeastConnBalance()
+	case LbP2C:
+		return NewP2CBalance()
+	case LbEwma:
+		return NewEwmaBalance()
 	default:
 		return &RandomBalance{}
 	}
 }
 
-func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalance {
+func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf, hashConf *HashConf) LoadBalance {
 	//观察者模式
 	switch lbType {
 	case LbRandom:
@@ -34,7 +49,11 @@ func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalanc
 		lb.Update()
 		return lb
 	case LbConsistentHash:
-		lb := NewConsistentHashBanlance(10, nil)
+		if hashConf == nil {
+			hashConf = &HashConf{}
+		}
+		lb := NewConsistentHashBanlance(hashConf.Replicas, nil)
+		lb.SetLoadFactor(hashConf.LoadFactor)
 		lb.SetConf(mConf)
 		mConf.Attach(lb)
 		lb.Update()
@@ -51,6 +70,24 @@ func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalanc
 		mConf.Attach(lb)
 		lb.Update()
 		return lb
+	case LbLeastConn:
+		lb := NewLeastConnBalance()
+		lb.SetConf(mConf)
+		mConf.Attach(lb)
+		lb.Update()
+		return lb
+	case LbP2C:
+		lb := NewP2CBalance()
+		lb.SetConf(mConf)
+		mConf.Attach(lb)
+		lb.Update()
+		return lb
+	case LbEwma:
+		lb := NewEwmaBalance()
+		lb.SetConf(mConf)
+		mConf.Attach(lb)
+		lb.Update()
+		return lb
 	default:
 		lb := &RandomBalance{}
 		lb.SetConf(mConf)
@@ -0,0 +1,85 @@
+package load_balance
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+//最少连接数，选择进行中请求最少的节点
+type LeastConnBalance struct {
+	mux      sync.Mutex
+	curIndex int
+	rss      []string
+	inflight map[string]int64
+	//观察主体
+	conf LoadBalanceConf
+}
+
+func NewLeastConnBalance() *LeastConnBalance {
+	return &LeastConnBalance{inflight: map[string]int64{}}
+}
+
+func (r *LeastConnBalance) Add(params ...string) error {
+	if len(params) == 0 {
+		return errors.New("param len 1 at least")
+	}
+	addr := params[0]
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.rss = append(r.rss, addr)
+	return nil
+}
+
+func (r *LeastConnBalance) Next() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	lens := len(r.rss)
+	if lens == 0 {
+		return ""
+	}
+	//从轮转位置开始比较，连接数相同时分散到不同节点
+	best := ""
+	for i := 0; i < lens; i++ {
+		addr := r.rss[(r.curIndex+i)%lens]
+		if best == "" || r.inflight[addr] < r.inflight[best] {
+			best = addr
+		}
+	}
+	r.curIndex = (r.curIndex + 1) % lens
+	r.inflight[best]++
+	return best
+}
+
+func (r *LeastConnBalance) Get(key string) (string, error) {
+	return r.Next(), nil
+}
+
+//请求结束时回调，释放进行中计数
+func (r *LeastConnBalance) Done(addr string, err error, cost time.Duration) {
+	r.Release(addr)
+}
+
+func (r *LeastConnBalance) Release(addr string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.inflight[addr] > 0 {
+		r.inflight[addr]--
+	}
+}
+
+func (r *LeastConnBalance) SetConf(conf LoadBalanceConf) {
+	r.conf = conf
+}
+
+func (r *LeastConnBalance) Update() {
+	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
+		r.mux.Lock()
+		r.rss = nil
+		r.mux.Unlock()
+		for _, ip := range conf.GetConf() {
+			r.Add(strings.Split(ip, ",")...)
+		}
+	}
+}
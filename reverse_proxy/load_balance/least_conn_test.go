@@ -0,0 +1,43 @@
+package load_balance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeastConnBalance(t *testing.T) {
+	rb := NewLeastConnBalance()
+	rb.Add("127.0.0.1:2003") //0
+	rb.Add("127.0.0.1:2004") //1
+	rb.Add("127.0.0.1:2005") //2
+
+	//三个请求分散到三个节点
+	used := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		used[rb.Next()] = true
+	}
+	if len(used) != 3 {
+		t.Fatalf("expect 3 nodes used, got %v", used)
+	}
+
+	//释放2004后，下一个请求应落到2004
+	rb.Done("127.0.0.1:2004", nil, time.Millisecond)
+	if addr := rb.Next(); addr != "127.0.0.1:2004" {
+		t.Fatalf("expect 127.0.0.1:2004, got %v", addr)
+	}
+}
+
+func TestP2CBalance(t *testing.T) {
+	rb := NewP2CBalance()
+	rb.Add("127.0.0.1:2003") //0
+	rb.Add("127.0.0.1:2004") //1
+
+	//两个节点时每次都比较全部节点，进行中请求数差值不超过1
+	for i := 0; i < 10; i++ {
+		rb.Next()
+	}
+	diff := rb.inflight["127.0.0.1:2003"] - rb.inflight["127.0.0.1:2004"]
+	if diff > 1 || diff < -1 {
+		t.Fatalf("inflight not balanced: %v", rb.inflight)
+	}
+}
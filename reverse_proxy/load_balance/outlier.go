@@ -0,0 +1,146 @@
+package load_balance
+
+import (
+	"time"
+)
+
+const (
+	//default outlier setting
+	DefaultOutlierErrNum       = 5   //连续失败多少次摘除
+	DefaultOutlierEjectTime    = 30  //摘除基础时长，单位s，多次摘除按倍数递增
+	DefaultOutlierMaxEjectTime = 300 //摘除最长时长，单位s
+	DefaultOutlierMaxPercent   = 50  //同时摘除节点数占比上限
+)
+
+//被动探活配置，零值字段使用默认值
+//摘除前要求 (已摘除数+1)*100 <= 节点数*MaxPercent，默认50%时单节点池永不摘除，双节点池最多摘除一个
+type OutlierConf struct {
+	ErrNum       int           //连续失败多少次摘除
+	EjectTime    time.Duration //摘除基础时长，多次摘除按倍数递增
+	MaxEjectTime time.Duration //摘除最长时长
+	MaxPercent   int           //同时摘除节点数占比上限，百分比
+}
+
+func (oc *OutlierConf) errNum() int {
+	if oc != nil && oc.ErrNum > 0 {
+		return oc.ErrNum
+	}
+	return DefaultOutlierErrNum
+}
+
+func (oc *OutlierConf) ejectTime() time.Duration {
+	if oc != nil && oc.EjectTime > 0 {
+		return oc.EjectTime
+	}
+	return time.Duration(DefaultOutlierEjectTime) * time.Second
+}
+
+func (oc *OutlierConf) maxEjectTime() time.Duration {
+	if oc != nil && oc.MaxEjectTime > 0 {
+		return oc.MaxEjectTime
+	}
+	return time.Duration(DefaultOutlierMaxEjectTime) * time.Second
+}
+
+func (oc *OutlierConf) maxPercent() int {
+	if oc != nil && oc.MaxPercent > 0 {
+		return oc.MaxPercent
+	}
+	return DefaultOutlierMaxPercent
+}
+
+//代理请求结束时回报结果，用于被动探活及按连接数、延迟负载均衡
+//cost为下游响应耗时：http为收到响应头耗时，tcp为建连耗时，grpc为stream耗时
+type Feedback interface {
+	Done(addr string, err error, cost time.Duration)
+}
+
+//Get返回的节点未被使用时归还，只释放进行中计数，不计入耗时及失败
+type Releaser interface {
+	Release(addr string)
+}
+
+//多个Feedback依次回调
+type FeedbackList []Feedback
+
+func (fl FeedbackList) Done(addr string, err error, cost time.Duration) {
+	for _, fb := range fl {
+		fb.Done(addr, err, cost)
+	}
+}
+
+type outlierState struct {
+	errNum   int
+	ejectNum int
+	ejected  bool
+	ejectAt  time.Time
+}
+
+//设置被动探活配置，未设置时使用默认值
+func (s *LoadBalanceCheckConf) SetOutlierConf(conf *OutlierConf) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outlierConf = conf
+}
+
+//addr为负载均衡返回的地址，err非空表示5xx、建连失败或超时
+func (s *LoadBalanceCheckConf) Done(addr string, err error, cost time.Duration) {
+	ip, ok := s.formatIp[addr]
+	if !ok {
+		return
+	}
+	if breaker := s.nodeBreaker(ip, addr); breaker != nil {
+		breaker.Done(addr, err, cost)
+	}
+	s.mu.Lock()
+	state, ok := s.outliers[ip]
+	if !ok {
+		state = &outlierState{}
+		s.outliers[ip] = state
+	}
+	if err == nil {
+		state.errNum = 0
+		s.mu.Unlock()
+		return
+	}
+	state.errNum++
+	if state.ejected || state.errNum < s.outlierConf.errNum() {
+		s.mu.Unlock()
+		return
+	}
+
+	//避免整个节点池被摘除，MaxPercent小于100时单节点池不会被摘除
+	ejectedNum := 0
+	for _, item := range s.outliers {
+		if item.ejected {
+			ejectedNum++
+		}
+	}
+	if (ejectedNum+1)*100 > len(s.confIpWeight)*s.outlierConf.maxPercent() {
+		s.mu.Unlock()
+		return
+	}
+
+	//距上次摘除足够久时重置退避倍数
+	maxEjectTime := s.outlierConf.maxEjectTime()
+	if time.Since(state.ejectAt) > maxEjectTime {
+		state.ejectNum = 0
+	}
+	state.ejectNum++
+	state.ejected = true
+	state.ejectAt = time.Now()
+	state.errNum = 0
+	ejectTime := s.outlierConf.ejectTime() * time.Duration(state.ejectNum)
+	if ejectTime > maxEjectTime {
+		ejectTime = maxEjectTime
+	}
+	s.mu.Unlock()
+
+	time.AfterFunc(ejectTime, func() {
+		s.mu.Lock()
+		state.ejected = false
+		s.mu.Unlock()
+		s.NotifyAllObservers()
+	})
+	s.NotifyAllObservers()
+}
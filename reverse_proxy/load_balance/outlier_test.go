@@ -0,0 +1,70 @@
+package load_balance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newOutlierCheckConf(ipList ...string) *LoadBalanceCheckConf {
+	conf := &LoadBalanceCheckConf{
+		format:       "http://%s",
+		formatIp:     map[string]string{},
+		confIpWeight: map[string]string{},
+		outliers:     map[string]*outlierState{},
+		breakers:     map[string]*CircuitBreaker{},
+	}
+	for _, ip := range ipList {
+		conf.formatIp[fmt.Sprintf(conf.format, ip)] = ip
+		conf.confIpWeight[ip] = "50"
+		conf.activeList = append(conf.activeList, ip)
+	}
+	return conf
+}
+
+func TestOutlierEject(t *testing.T) {
+	upstreamErr := errors.New("upstream status code 502")
+	conf := newOutlierCheckConf("127.0.0.1:2001", "127.0.0.1:2002")
+	conf.SetOutlierConf(&OutlierConf{ErrNum: 2, EjectTime: 50 * time.Millisecond})
+
+	conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	if len(conf.GetConf()) != 2 {
+		t.Fatal("expect node kept before err num reached")
+	}
+	conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	if list := conf.GetConf(); len(list) != 1 || list[0] != "http://127.0.0.1:2002,50" {
+		t.Fatalf("expect node ejected, got %v", list)
+	}
+	//默认50%上限，双节点池最多摘除一个
+	conf.Done("http://127.0.0.1:2002", upstreamErr, time.Millisecond)
+	conf.Done("http://127.0.0.1:2002", upstreamErr, time.Millisecond)
+	if list := conf.GetConf(); len(list) != 1 || list[0] != "http://127.0.0.1:2002,50" {
+		t.Fatalf("expect max percent respected, got %v", list)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(conf.GetConf()) != 2 {
+		t.Fatal("expect node recovered after eject time")
+	}
+}
+
+//MaxPercent小于100时，单节点池连续失败也不会被摘除
+func TestOutlierSingleNode(t *testing.T) {
+	upstreamErr := errors.New("dial tcp: connection refused")
+	conf := newOutlierCheckConf("127.0.0.1:2001")
+	for i := 0; i < DefaultOutlierErrNum*2; i++ {
+		conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	}
+	if list := conf.GetConf(); len(list) != 1 {
+		t.Fatalf("expect single node kept, got %v", list)
+	}
+
+	conf = newOutlierCheckConf("127.0.0.1:2001")
+	conf.SetOutlierConf(&OutlierConf{MaxPercent: 100, EjectTime: time.Minute})
+	for i := 0; i < DefaultOutlierErrNum; i++ {
+		conf.Done("http://127.0.0.1:2001", upstreamErr, time.Millisecond)
+	}
+	if list := conf.GetConf(); len(list) != 0 {
+		t.Fatalf("expect single node ejected with max percent 100, got %v", list)
+	}
+}
@@ -0,0 +1,88 @@
+package load_balance
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+//power of two choices，随机选两个节点，取进行中请求较少的一个
+type P2CBalance struct {
+	mux      sync.Mutex
+	rss      []string
+	inflight map[string]int64
+	//观察主体
+	conf LoadBalanceConf
+}
+
+func NewP2CBalance() *P2CBalance {
+	return &P2CBalance{inflight: map[string]int64{}}
+}
+
+func (r *P2CBalance) Add(params ...string) error {
+	if len(params) == 0 {
+		return errors.New("param len 1 at least")
+	}
+	addr := params[0]
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.rss = append(r.rss, addr)
+	return nil
+}
+
+func (r *P2CBalance) Next() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	lens := len(r.rss)
+	if lens == 0 {
+		return ""
+	}
+	best := r.rss[0]
+	if lens > 1 {
+		a := rand.Intn(lens)
+		b := rand.Intn(lens - 1)
+		if b >= a {
+			b++
+		}
+		best = r.rss[a]
+		if r.inflight[r.rss[b]] < r.inflight[best] {
+			best = r.rss[b]
+		}
+	}
+	r.inflight[best]++
+	return best
+}
+
+func (r *P2CBalance) Get(key string) (string, error) {
+	return r.Next(), nil
+}
+
+//请求结束时回调，释放进行中计数
+func (r *P2CBalance) Done(addr string, err error, cost time.Duration) {
+	r.Release(addr)
+}
+
+func (r *P2CBalance) Release(addr string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.inflight[addr] > 0 {
+		r.inflight[addr]--
+	}
+}
+
+func (r *P2CBalance) SetConf(conf LoadBalanceConf) {
+	r.conf = conf
+}
+
+func (r *P2CBalance) Update() {
+	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
+		r.mux.Lock()
+		r.rss = nil
+		r.mux.Unlock()
+		for _, ip := range conf.GetConf() {
+			r.Add(strings.Split(ip, ",")...)
+		}
+	}
+}
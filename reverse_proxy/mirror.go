@@ -0,0 +1,114 @@
+package reverse_proxy
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMirrorMaxBodySize = 64 * 1024
+	DefaultMirrorTimeout     = 5 * time.Second
+	DefaultMirrorMaxPending  = 100
+)
+
+//进行中的镜像请求数，超过上限时丢弃镜像，避免镜像下游变慢时堆积
+var mirrorPending int64
+
+//流量镜像配置，镜像请求异步发送，响应直接丢弃
+type MirrorConf struct {
+	Target      string //scheme://host:port
+	Percent     int
+	MaxBodySize int64
+	Timeout     time.Duration
+	MaxPending  int64
+}
+
+//target为空时不镜像，未带scheme时使用defaultScheme
+func NewMirrorConf(target string, percent int, defaultScheme string, maxBodySize int64, timeout time.Duration, maxPending int64) *MirrorConf {
+	if target == "" || percent <= 0 {
+		return nil
+	}
+	if !strings.Contains(target, "://") {
+		target = defaultScheme + "://" + target
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMirrorMaxBodySize
+	}
+	if timeout <= 0 {
+		timeout = DefaultMirrorTimeout
+	}
+	if maxPending <= 0 {
+		maxPending = DefaultMirrorMaxPending
+	}
+	return &MirrorConf{
+		Target:      target,
+		Percent:     percent,
+		MaxBodySize: maxBodySize,
+		Timeout:     timeout,
+		MaxPending:  maxPending,
+	}
+}
+
+//按比例抽样复制请求发往镜像地址，需在转发主请求前调用
+func (m *MirrorConf) Mirror(req *http.Request, trans http.RoundTripper) {
+	if m.Percent < 100 && rand.Intn(100) >= m.Percent {
+		return
+	}
+	//协议升级的请求无法复制
+	if req.Header.Get("Upgrade") != "" {
+		return
+	}
+	//请求体超过上限时不镜像，主请求不受影响
+	if !bufferRequestBody(req, m.MaxBodySize) {
+		return
+	}
+	if atomic.AddInt64(&mirrorPending, 1) > m.MaxPending {
+		atomic.AddInt64(&mirrorPending, -1)
+		return
+	}
+	mirrorReq, cancel, err := m.mirrorRequest(req)
+	if err != nil {
+		atomic.AddInt64(&mirrorPending, -1)
+		log.Printf(" [ERROR] mirror_request %v err:%v\n", m.Target, err)
+		return
+	}
+	go func() {
+		defer atomic.AddInt64(&mirrorPending, -1)
+		defer cancel()
+		resp, err := trans.RoundTrip(mirrorReq)
+		if err != nil {
+			log.Printf(" [WARN] mirror_request %v err:%v\n", m.Target, err)
+			return
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+//镜像请求不随客户端请求取消，单独设置超时
+func (m *MirrorConf) mirrorRequest(req *http.Request) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	mirrorReq := req.Clone(ctx)
+	mirrorReq.Body = nil
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		mirrorReq.Body = body
+	}
+	pos := strings.Index(m.Target, "://")
+	mirrorReq.URL.Scheme = m.Target[:pos]
+	mirrorReq.URL.Host = m.Target[pos+3:]
+	mirrorReq.RequestURI = ""
+	mirrorReq.Header.Set("X-Gateway-Mirror", "1")
+	return mirrorReq, cancel, nil
+}
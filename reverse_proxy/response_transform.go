@@ -0,0 +1,317 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultTransformMaxBufferSize = 1024 * 1024
+	JsonMaskValue                 = "******"
+)
+
+//响应改写：状态码映射、json字段脱敏、正则替换响应体，在ModifyResponse中执行
+//响应header统一由服务的结构化header规则(dao.HeaderRule)处理，在本改写之后、响应头写出前执行
+//需读取响应体的改写只处理不超过缓冲上限的普通响应，升级连接及流式响应原样透传
+type ResponseTransform struct {
+	statusMap     map[int]int
+	jsonMasks     [][]*jsonPathStep
+	replaces      []*bodyReplaceRule
+	MaxBufferSize int64
+	SkipBody      bool //流式请求不读取响应体
+}
+
+type bodyReplaceRule struct {
+	regex       *regexp.Regexp
+	replacement []byte
+}
+
+//配置均为每行一条规则，规则内容可包含逗号
+//statusMap格式: 原状态码 新状态码，bodyReplace格式: 正则 替换内容，jsonMask为JSONPath如$.data.password
+//均未配置时返回nil
+func NewResponseTransform(statusMap, jsonMask, bodyReplace string, maxBufferSize int64) (*ResponseTransform, error) {
+	if statusMap == "" && jsonMask == "" && bodyReplace == "" {
+		return nil, nil
+	}
+	if maxBufferSize <= 0 {
+		maxBufferSize = DefaultTransformMaxBufferSize
+	}
+	t := &ResponseTransform{statusMap: map[int]int{}, MaxBufferSize: maxBufferSize}
+	for _, item := range splitTransformConf(statusMap) {
+		items := strings.Split(item, " ")
+		if len(items) != 2 {
+			return nil, errors.New("状态码映射格式错误: " + item)
+		}
+		from, err := strconv.Atoi(items[0])
+		if err != nil {
+			return nil, errors.Wrap(err, item)
+		}
+		to, err := strconv.Atoi(items[1])
+		if err != nil || to < 100 || to > 999 {
+			return nil, errors.New("状态码映射格式错误: " + item)
+		}
+		t.statusMap[from] = to
+	}
+	for _, item := range splitTransformConf(jsonMask) {
+		steps, err := parseJsonPath(item)
+		if err != nil {
+			return nil, err
+		}
+		t.jsonMasks = append(t.jsonMasks, steps)
+	}
+	for _, item := range splitTransformConf(bodyReplace) {
+		items := strings.SplitN(item, " ", 2)
+		if len(items) != 2 {
+			return nil, errors.New("响应体替换格式错误: " + item)
+		}
+		regex, err := regexp.Compile(items[0])
+		if err != nil {
+			return nil, errors.Wrap(err, item)
+		}
+		t.replaces = append(t.replaces, &bodyReplaceRule{regex: regex, replacement: []byte(items[1])})
+	}
+	return t, nil
+}
+
+//规则按服务共享，流式请求使用不读取响应体的副本
+func (t *ResponseTransform) WithSkipBody() *ResponseTransform {
+	skip := *t
+	skip.SkipBody = true
+	return &skip
+}
+
+//按行拆分规则，忽略空行
+func splitTransformConf(conf string) []string {
+	items := []string{}
+	for _, item := range strings.Split(conf, "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+//在ModifyResponse中调用
+func (t *ResponseTransform) Modify(resp *http.Response) error {
+	if to, ok := t.statusMap[resp.StatusCode]; ok {
+		resp.StatusCode = to
+		resp.Status = fmt.Sprintf("%d %s", to, http.StatusText(to))
+	}
+	if len(t.jsonMasks) == 0 && len(t.replaces) == 0 {
+		return nil
+	}
+	return t.modifyBody(resp)
+}
+
+func (t *ResponseTransform) modifyBody(resp *http.Response) error {
+	if t.SkipBody || resp.Body == nil || resp.Body == http.NoBody || resp.Request.Method == http.MethodHead {
+		return nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "identity" && encoding != "gzip" {
+		return nil
+	}
+	if resp.ContentLength > t.MaxBufferSize {
+		return nil
+	}
+	//长度未知时最多读取上限+1字节，超过上限则把已读部分拼回原响应体继续透传
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, t.MaxBufferSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(raw)) > t.MaxBufferSize {
+		resp.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(raw), resp.Body), Closer: resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+	payload := raw
+	if encoding == "gzip" {
+		gr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return err
+		}
+		payload, err = ioutil.ReadAll(io.LimitReader(gr, t.MaxBufferSize+1))
+		if err != nil {
+			return err
+		}
+		//解压后超过上限时原样返回
+		if int64(len(payload)) > t.MaxBufferSize {
+			return nil
+		}
+	}
+	if len(t.jsonMasks) > 0 && strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if payload, err = t.maskJson(payload); err != nil {
+			return err
+		}
+	}
+	for _, rule := range t.replaces {
+		payload = rule.regex.ReplaceAll(payload, rule.replacement)
+	}
+	if encoding == "gzip" {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		if _, err := gw.Write(payload); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	resp.ContentLength = int64(len(payload))
+	resp.Header.Set("Content-Length", strconv.FormatInt(int64(len(payload)), 10))
+	resp.Header.Del("Transfer-Encoding")
+	return nil
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+//非json格式的响应体原样返回
+func (t *ResponseTransform) maskJson(payload []byte) ([]byte, error) {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return payload, nil
+	}
+	for _, steps := range t.jsonMasks {
+		data = maskJsonNode(data, steps)
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+//JSONPath的一段，支持 .name、['name']、[n]、.*、[*] 及 ..name 递归查找
+type jsonPathStep struct {
+	name      string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+func parseJsonPath(path string) ([]*jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.New("JSONPath需以$开头: " + path)
+	}
+	steps := []*jsonPathStep{}
+	rest := path[1:]
+	for rest != "" {
+		step := &jsonPathStep{}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, errors.New("JSONPath格式错误: " + path)
+			}
+			key := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case key == "*":
+				step.wildcard = true
+			case strings.HasPrefix(key, "'") && strings.HasSuffix(key, "'") && len(key) >= 2:
+				step.name = key[1 : len(key)-1]
+			default:
+				index, err := strconv.Atoi(key)
+				if err != nil {
+					return nil, errors.New("JSONPath格式错误: " + path)
+				}
+				step.index = index
+				step.isIndex = true
+			}
+			steps = append(steps, step)
+			continue
+		default:
+			return nil, errors.New("JSONPath格式错误: " + path)
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, errors.New("JSONPath格式错误: " + path)
+		}
+		if step.name = rest[:end]; step.name == "*" {
+			step.name = ""
+			step.wildcard = true
+		}
+		rest = rest[end:]
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("JSONPath缺少字段: " + path)
+	}
+	return steps, nil
+}
+
+func (s *jsonPathStep) matchKey(key string) bool {
+	return s.wildcard || (!s.isIndex && s.name == key)
+}
+
+func (s *jsonPathStep) matchIndex(index int) bool {
+	return s.wildcard || (s.isIndex && s.index == index)
+}
+
+//返回脱敏后的节点，路径最后一段命中的值替换为JsonMaskValue
+func maskJsonNode(node interface{}, steps []*jsonPathStep) interface{} {
+	step, rest := steps[0], steps[1:]
+	apply := func(value interface{}) interface{} {
+		if len(rest) == 0 {
+			return JsonMaskValue
+		}
+		return maskJsonNode(value, rest)
+	}
+	switch data := node.(type) {
+	case map[string]interface{}:
+		for key, value := range data {
+			if step.matchKey(key) {
+				data[key] = apply(value)
+				if step.recursive && len(rest) > 0 {
+					data[key] = maskJsonNode(data[key], steps)
+				}
+			} else if step.recursive {
+				data[key] = maskJsonNode(value, steps)
+			}
+		}
+	case []interface{}:
+		for i, value := range data {
+			if step.matchIndex(i) {
+				data[i] = apply(value)
+				if step.recursive && len(rest) > 0 {
+					data[i] = maskJsonNode(data[i], steps)
+				}
+			} else if step.recursive {
+				data[i] = maskJsonNode(value, steps)
+			}
+		}
+	}
+	return node
+}
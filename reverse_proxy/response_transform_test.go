@@ -0,0 +1,218 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseJsonPath(t *testing.T) {
+	cases := []struct {
+		path  string
+		steps int
+		ok    bool
+	}{
+		{"$.data.password", 2, true},
+		{"$..password", 1, true},
+		{"$.list[*].token", 3, true},
+		{"$.list[0]", 2, true},
+		{"$['data']['password']", 2, true},
+		{"$.data.*", 2, true},
+		{"$..user.phone", 2, true},
+		{"data.password", 0, false},
+		{"$", 0, false},
+		{"$.", 0, false},
+		{"$..", 0, false},
+		{"$.list[0", 0, false},
+		{"$.list[abc]", 0, false},
+		{"$data", 0, false},
+		{"$.data..", 0, false},
+	}
+	for _, c := range cases {
+		steps, err := parseJsonPath(c.path)
+		if (err == nil) != c.ok || len(steps) != c.steps {
+			t.Errorf("path %q: expect %d steps ok=%v, got %d %v", c.path, c.steps, c.ok, len(steps), err)
+		}
+	}
+}
+
+func TestMaskJsonNode(t *testing.T) {
+	payload := `{"data":{"password":"p1","user":{"phone":"138","name":"n1"}},"list":[{"token":"t1","id":1},{"token":"t2","id":2}],"password":"p0"}`
+	cases := []struct {
+		path   string
+		expect string
+	}{
+		{"$.data.password", `{"data":{"password":"******","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"p0"}`},
+		{"$..password", `{"data":{"password":"******","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"******"}`},
+		{"$.list[*].token", `{"data":{"password":"p1","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"******"},{"id":2,"token":"******"}],"password":"p0"}`},
+		{"$.list[1]", `{"data":{"password":"p1","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"t1"},"******"],"password":"p0"}`},
+		{"$['data']['user'].*", `{"data":{"password":"p1","user":{"name":"******","phone":"******"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"p0"}`},
+		{"$..user.phone", `{"data":{"password":"p1","user":{"name":"n1","phone":"******"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"p0"}`},
+		{"$.missing.password", `{"data":{"password":"p1","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"p0"}`},
+		{"$.list[5].token", `{"data":{"password":"p1","user":{"name":"n1","phone":"138"}},"list":[{"id":1,"token":"t1"},{"id":2,"token":"t2"}],"password":"p0"}`},
+	}
+	for _, c := range cases {
+		steps, err := parseJsonPath(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data interface{}
+		json.Unmarshal([]byte(payload), &data)
+		result, _ := json.Marshal(maskJsonNode(data, steps))
+		if string(result) != c.expect {
+			t.Errorf("path %q: expect %s, got %s", c.path, c.expect, result)
+		}
+	}
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func transformResponse(header map[string]string, body []byte) *http.Response {
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: -1,
+		Request:       httptest.NewRequest(http.MethodGet, "/", nil),
+	}
+	for name, value := range header {
+		resp.Header.Set(name, value)
+	}
+	return resp
+}
+
+func TestResponseTransformModify(t *testing.T) {
+	transform, err := NewResponseTransform("502 503", "$..password", "secret-[0-9]+ [hidden]", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := transformResponse(map[string]string{"Content-Type": "application/json"},
+		[]byte(`{"password":"p1","note":"secret-123"}`))
+	resp.StatusCode = http.StatusBadGateway
+	if err := transform.Modify(resp); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"note":"[hidden]","password":"******"}` || resp.ContentLength != int64(len(body)) {
+		t.Fatalf("unexpected body %s %d", body, resp.ContentLength)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Status != "503 Service Unavailable" {
+		t.Fatalf("unexpected status %v", resp.Status)
+	}
+
+	//gzip响应解压改写后重新压缩
+	resp = transformResponse(map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+		gzipBytes(t, []byte(`{"password":"p1"}`)))
+	if err := transform.Modify(resp); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(gr)
+	if string(body) != `{"password":"******"}` || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("unexpected gzip body %s", body)
+	}
+
+	//超过缓冲上限的响应体原样透传
+	oversized := `{"password":"p1","note":"` + strings.Repeat("secret-1", 20) + `"}`
+	resp = transformResponse(map[string]string{"Content-Type": "application/json"}, []byte(oversized))
+	if err := transform.Modify(resp); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = ioutil.ReadAll(resp.Body); string(body) != oversized {
+		t.Fatalf("expect oversized body unchanged, got %s", body)
+	}
+	resp = transformResponse(map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+		gzipBytes(t, []byte(oversized)))
+	compressed := gzipBytes(t, []byte(oversized))
+	if err := transform.Modify(resp); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = ioutil.ReadAll(resp.Body); !bytes.Equal(body, compressed) {
+		t.Fatal("expect oversized gzip body unchanged")
+	}
+
+	//流式请求及其它编码不改写响应体
+	streamBody := `{"password":"p1"}`
+	for _, item := range []struct {
+		transform *ResponseTransform
+		encoding  string
+	}{
+		{transform.WithSkipBody(), ""},
+		{transform, "br"},
+	} {
+		resp = transformResponse(map[string]string{"Content-Type": "application/json", "Content-Encoding": item.encoding}, []byte(streamBody))
+		if err := item.transform.Modify(resp); err != nil {
+			t.Fatal(err)
+		}
+		if body, _ = ioutil.ReadAll(resp.Body); string(body) != streamBody {
+			t.Fatalf("expect body unchanged, got %s", body)
+		}
+	}
+	if transform.SkipBody {
+		t.Fatal("expect shared transform unchanged")
+	}
+}
+
+//规则按行拆分，正则及JSONPath中可包含逗号
+func TestResponseTransformComma(t *testing.T) {
+	transform, err := NewResponseTransform("502 503\n\n504 503\r\n", "$['a,b']\n$.password", "[0-9]{1,3}-[0-9]{1,4} ***", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transform.statusMap) != 2 || len(transform.jsonMasks) != 2 || len(transform.replaces) != 1 {
+		t.Fatalf("unexpected rules %+v", transform)
+	}
+	resp := transformResponse(map[string]string{"Content-Type": "application/json"},
+		[]byte(`{"a,b":"v","password":"p1","tel":"010-1234"}`))
+	resp.StatusCode = http.StatusGatewayTimeout
+	if err := transform.Modify(resp); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"a,b":"******","password":"******","tel":"***"}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status %v", resp.StatusCode)
+	}
+}
+
+func TestNewResponseTransformInvalid(t *testing.T) {
+	cases := []struct {
+		statusMap   string
+		jsonMask    string
+		bodyReplace string
+	}{
+		{"502", "", ""},
+		{"502 abc", "", ""},
+		{"502 1000", "", ""},
+		{"502 503,504 503", "", ""},
+		{"", "data.password", ""},
+		{"", "", "secret-[0-9"},
+		{"", "", "secret"},
+	}
+	for _, c := range cases {
+		if _, err := NewResponseTransform(c.statusMap, c.jsonMask, c.bodyReplace, 0); err == nil {
+			t.Errorf("%+v: expect error", c)
+		}
+	}
+	if transform, err := NewResponseTransform("", "", "", 0); transform != nil || err != nil {
+		t.Fatal("expect nil transform without conf")
+	}
+}
@@ -0,0 +1,175 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/e421083458/go_gateway/public"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	RetryOnConnectFailure = "connect_failure"
+	RetryOnTimeout        = "timeout"
+
+	DefaultRetryMaxBodySize = 64 * 1024 //可重放的请求体上限，单位byte
+)
+
+//重试配置，RetryNum为换节点重试的次数
+type RetryConf struct {
+	RetryNum      int
+	RetryOn       map[string]bool //connect_failure、timeout 及状态码如 502
+	NonIdempotent bool            //是否重试非幂等请求
+	MaxBodySize   int64
+	Budget        *public.RetryBudget
+}
+
+//retryOn 为逗号分隔的重试条件，为空时只在建连失败时重试
+func NewRetryConf(retryNum int, retryOn string, nonIdempotent bool, maxBodySize int64, budget *public.RetryBudget) *RetryConf {
+	if retryOn == "" {
+		retryOn = RetryOnConnectFailure
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultRetryMaxBodySize
+	}
+	conf := &RetryConf{
+		RetryNum:      retryNum,
+		RetryOn:       map[string]bool{},
+		NonIdempotent: nonIdempotent,
+		MaxBodySize:   maxBodySize,
+		Budget:        budget,
+	}
+	for _, item := range strings.Split(retryOn, ",") {
+		conf.RetryOn[strings.TrimSpace(item)] = true
+	}
+	return conf
+}
+
+func (conf *RetryConf) methodRetryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return conf.NonIdempotent
+}
+
+//判断单次请求结果是否需要换节点重试
+func (conf *RetryConf) shouldRetry(resp *http.Response, err error) bool {
+	if err == nil {
+		return conf.RetryOn[strconv.Itoa(resp.StatusCode)]
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return conf.RetryOn[RetryOnTimeout]
+	}
+	var opErr *net.OpError
+	if (errors.As(err, &opErr) && opErr.Op == "dial") || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return conf.RetryOn[RetryOnConnectFailure]
+	}
+	return false
+}
+
+//按重试配置换节点重新发送请求
+type retryTransport struct {
+	base http.RoundTripper
+	conf *RetryConf
+	//选择未尝试过的节点，无可用节点时返回空
+	next func(tried map[string]bool) string
+	//归还已选择但未使用的节点
+	release func(addr string)
+	//失败的尝试在此回报，最后一次尝试由ModifyResponse或ErrorHandler回报
+	done func(addr string, err error, cost time.Duration)
+	//开始新的尝试
+	onRetry func(addr string)
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.conf.Budget != nil {
+		t.conf.Budget.Deposit()
+	}
+	if t.conf.RetryNum <= 0 || !t.conf.methodRetryable(req.Method) || !bufferRequestBody(req, t.conf.MaxBodySize) {
+		return t.base.RoundTrip(req)
+	}
+	tried := map[string]bool{}
+	for attempt := 0; ; attempt++ {
+		addr := upstreamAddr(req)
+		tried[addr] = true
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.conf.RetryNum || req.Context().Err() != nil || !t.conf.shouldRetry(resp, err) {
+			return resp, err
+		}
+		nextAddr := t.next(tried)
+		if nextAddr == "" {
+			return resp, err
+		}
+		if t.conf.Budget != nil && !t.conf.Budget.Withdraw() {
+			t.release(nextAddr)
+			return resp, err
+		}
+		if err == nil {
+			err = fmt.Errorf("upstream status code %d", resp.StatusCode)
+			resp.Body.Close()
+		}
+		t.done(addr, err, time.Since(start))
+
+		nextReq, err := retryRequest(req, nextAddr)
+		if err != nil {
+			return nil, err
+		}
+		t.onRetry(nextAddr)
+		req = nextReq
+	}
+}
+
+//请求体读入内存以便重放，超过上限时不重试
+func bufferRequestBody(req *http.Request, maxBodySize int64) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+	if req.ContentLength > maxBodySize {
+		return false
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+	if err != nil || int64(len(payload)) > maxBodySize {
+		//已读取部分拼回请求体，本次请求照常发送
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(payload), req.Body), req.Body}
+		return false
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(payload)), nil
+	}
+	return true
+}
+
+//负载均衡返回的地址只包含scheme及host，路径沿用首次请求
+func retryRequest(req *http.Request, nextAddr string) (*http.Request, error) {
+	nextReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		nextReq.Body = body
+	}
+	pos := strings.Index(nextAddr, "://")
+	if pos < 0 {
+		return nil, fmt.Errorf("invalid upstream addr %s", nextAddr)
+	}
+	nextReq.URL.Scheme = nextAddr[:pos]
+	nextReq.URL.Host = nextAddr[pos+3:]
+	nextReq.Host = nextReq.URL.Host
+	return nextReq, nil
+}
@@ -0,0 +1,155 @@
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"github.com/e421083458/go_gateway/public"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route to host")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("broken")}
+	refusedErr := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNREFUSED)}
+	resetErr := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	timeoutErr := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	cases := []struct {
+		name    string
+		retryOn string
+		status  int
+		err     error
+		retry   bool
+	}{
+		{"default dial error", "", 0, dialErr, true},
+		{"default refused", "", 0, refusedErr, true},
+		{"default reset", "", 0, resetErr, true},
+		{"default timeout", "", 0, timeoutErr, false},
+		{"default status", "", http.StatusBadGateway, nil, false},
+		{"timeout", "timeout", 0, timeoutErr, true},
+		{"deadline exceeded", "timeout", 0, context.DeadlineExceeded, true},
+		{"timeout without connect failure", "timeout", 0, dialErr, false},
+		{"status matched", "502, 503", http.StatusServiceUnavailable, nil, true},
+		{"status not matched", "502,503", http.StatusInternalServerError, nil, false},
+		{"status ok", "502,503", http.StatusOK, nil, false},
+		{"other error", "connect_failure,timeout", 0, readErr, false},
+		{"canceled", "connect_failure,timeout", 0, context.Canceled, false},
+	}
+	for _, c := range cases {
+		conf := NewRetryConf(1, c.retryOn, false, 0, nil)
+		var resp *http.Response
+		if c.err == nil {
+			resp = &http.Response{StatusCode: c.status}
+		}
+		if retry := conf.shouldRetry(resp, c.err); retry != c.retry {
+			t.Errorf("%s: expect retry=%v, got %v", c.name, c.retry, retry)
+		}
+	}
+}
+
+func TestMethodRetryable(t *testing.T) {
+	cases := []struct {
+		method        string
+		nonIdempotent bool
+		retryable     bool
+	}{
+		{http.MethodGet, false, true},
+		{http.MethodHead, false, true},
+		{http.MethodOptions, false, true},
+		{http.MethodTrace, false, true},
+		{http.MethodPut, false, true},
+		{http.MethodDelete, false, true},
+		{http.MethodPost, false, false},
+		{http.MethodPatch, false, false},
+		{http.MethodPost, true, true},
+		{http.MethodPatch, true, true},
+	}
+	for _, c := range cases {
+		conf := NewRetryConf(1, "", c.nonIdempotent, 0, nil)
+		if retryable := conf.methodRetryable(c.method); retryable != c.retryable {
+			t.Errorf("%s nonIdempotent=%v: expect %v, got %v", c.method, c.nonIdempotent, c.retryable, retryable)
+		}
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//按节点返回状态码的下游
+func statusTransport(status map[string]int, hosts *[]string) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		*hosts = append(*hosts, req.URL.Host)
+		return &http.Response{
+			StatusCode: status[req.URL.Host],
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	status := map[string]int{"127.0.0.1:2001": 502, "127.0.0.1:2002": 502, "127.0.0.1:2003": 200}
+	addrs := []string{"http://127.0.0.1:2001", "http://127.0.0.1:2002", "http://127.0.0.1:2003"}
+	cases := []struct {
+		name     string
+		retryNum int
+		budget   *public.RetryBudget
+		hosts    []string
+		released []string
+		status   int
+	}{
+		{"retry until ok", 2, nil, []string{"127.0.0.1:2001", "127.0.0.1:2002", "127.0.0.1:2003"}, nil, 200},
+		{"retry num limit", 1, nil, []string{"127.0.0.1:2001", "127.0.0.1:2002"}, nil, 502},
+		{"budget limit", 2, public.NewRetryBudgetManager().GetBudget("test", 0, 1), []string{"127.0.0.1:2001", "127.0.0.1:2002"}, []string{"http://127.0.0.1:2003"}, 502},
+		{"budget exhausted", 2, public.NewRetryBudgetManager().GetBudget("test", 0, 0), []string{"127.0.0.1:2001"}, []string{"http://127.0.0.1:2002"}, 502},
+	}
+	for _, c := range cases {
+		hosts := []string{}
+		released := []string{}
+		done := []string{}
+		transport := &retryTransport{
+			base: statusTransport(status, &hosts),
+			conf: NewRetryConf(c.retryNum, "502", false, 0, c.budget),
+			next: func(tried map[string]bool) string {
+				for _, addr := range addrs {
+					if !tried[addr] {
+						return addr
+					}
+				}
+				return ""
+			},
+			release: func(addr string) {
+				released = append(released, addr)
+			},
+			done: func(addr string, err error, cost time.Duration) {
+				done = append(done, addr)
+			},
+			onRetry: func(addr string) {},
+		}
+		req, _ := http.NewRequest(http.MethodGet, addrs[0]+"/ping", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil || resp.StatusCode != c.status {
+			t.Errorf("%s: expect status %d, got %v %v", c.name, c.status, resp, err)
+			continue
+		}
+		if strings.Join(hosts, ",") != strings.Join(c.hosts, ",") {
+			t.Errorf("%s: expect hosts %v, got %v", c.name, c.hosts, hosts)
+		}
+		//未使用的节点需归还，失败的尝试除最后一次外均已回报
+		if strings.Join(released, ",") != strings.Join(c.released, ",") {
+			t.Errorf("%s: expect released %v, got %v", c.name, c.released, released)
+		}
+		if len(done) != len(hosts)-1 {
+			t.Errorf("%s: expect %d done, got %v", c.name, len(hosts)-1, done)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package reverse_proxy
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
+	"net/http"
+	"strings"
+)
+
+//未配置cookie名时按服务区分，避免同一域名下的多个服务互相覆盖
+const DefaultStickyCookiePrefix = "gateway_sticky_"
+
+//cookie会话保持：首次响应下发签名后的下游地址，后续请求在该节点可用时固定转发
+type StickySession struct {
+	CookieName string
+	CookiePath string                       //前缀接入时限定为前缀路径
+	Conf       load_balance.LoadBalanceConf //可用节点列表，节点被摘除后回退到负载均衡
+}
+
+func NewStickySession(cookieName, serviceName, cookiePath string, conf load_balance.LoadBalanceConf) *StickySession {
+	if cookieName == "" {
+		cookieName = DefaultStickyCookiePrefix + serviceName
+	}
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+	return &StickySession{
+		CookieName: cookieName,
+		CookiePath: cookiePath,
+		Conf:       conf,
+	}
+}
+
+//返回cookie中仍可用的下游地址，签名无效或节点不可用时返回空
+func (s *StickySession) pinnedAddr(req *http.Request) string {
+	cookie, err := req.Cookie(s.CookieName)
+	if err != nil {
+		return ""
+	}
+	addr, ok := public.VerifySignedValue(cookie.Value)
+	if !ok {
+		return ""
+	}
+	for _, item := range s.Conf.GetConf() {
+		if strings.Split(item, ",")[0] == addr {
+			return addr
+		}
+	}
+	return ""
+}
+
+func (s *StickySession) cookie(addr string) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.CookieName,
+		Value:    public.SignValue(addr),
+		Path:     s.CookiePath,
+		HttpOnly: true,
+	}
+}
@@ -0,0 +1,70 @@
+package reverse_proxy
+
+import (
+	"github.com/e421083458/go_gateway/public"
+	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//固定节点列表
+type staticConf struct {
+	conf []string
+}
+
+func (s *staticConf) Done(addr string, err error, cost time.Duration) {}
+func (s *staticConf) Attach(o load_balance.Observer)                  {}
+func (s *staticConf) GetConf() []string                               { return s.conf }
+func (s *staticConf) WatchConf()                                      {}
+func (s *staticConf) CloseWatch()                                     {}
+func (s *staticConf) UpdateConf(conf []string)                        {}
+
+func TestStickySessionCookie(t *testing.T) {
+	sticky := NewStickySession("", "test_service", "", &staticConf{})
+	cookie := sticky.cookie("http://127.0.0.1:2003")
+	if cookie.Name != "gateway_sticky_test_service" || cookie.Path != "/" {
+		t.Fatalf("expect default name and path, got %v %v", cookie.Name, cookie.Path)
+	}
+	sticky = NewStickySession("my_cookie", "test_service", "/api", &staticConf{})
+	cookie = sticky.cookie("http://127.0.0.1:2003")
+	if cookie.Name != "my_cookie" || cookie.Path != "/api" {
+		t.Fatalf("expect custom name and prefix path, got %v %v", cookie.Name, cookie.Path)
+	}
+}
+
+func TestStickySessionPinnedAddr(t *testing.T) {
+	conf := &staticConf{conf: []string{"http://127.0.0.1:2003,50", "http://127.0.0.1:2004,50"}}
+	sticky := NewStickySession("", "test_service", "", conf)
+	signed := public.SignValue("http://127.0.0.1:2004")
+	cases := []struct {
+		name   string
+		cookie *http.Cookie
+		expect string
+	}{
+		{"no cookie", nil, ""},
+		{"pinned", &http.Cookie{Name: sticky.CookieName, Value: signed}, "http://127.0.0.1:2004"},
+		{"other service cookie", &http.Cookie{Name: "gateway_sticky_other", Value: signed}, ""},
+		{"tampered", &http.Cookie{Name: sticky.CookieName, Value: "aHR0cDovLzEyNy4wLjAuMToyMDAz" + signed[len(signed)-44:]}, ""},
+		{"unsigned", &http.Cookie{Name: sticky.CookieName, Value: "http://127.0.0.1:2004"}, ""},
+		{"wrong pool", &http.Cookie{Name: sticky.CookieName, Value: public.SignValue("http://127.0.0.1:2005")}, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		if addr := sticky.pinnedAddr(req); addr != c.expect {
+			t.Errorf("%s: expect %q, got %q", c.name, c.expect, addr)
+		}
+	}
+
+	//节点被摘除后不再固定
+	conf.conf = []string{"http://127.0.0.1:2003,50"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: sticky.CookieName, Value: signed})
+	if addr := sticky.pinnedAddr(req); addr != "" {
+		t.Fatalf("expect ejected node not pinned, got %q", addr)
+	}
+}
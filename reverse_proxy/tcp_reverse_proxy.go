@@ -2,6 +2,7 @@ package reverse_proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
 	"github.com/e421083458/go_gateway/tcp_proxy_middleware"
 	"io"
@@ -10,9 +11,11 @@ import (
 	"time"
 )
 
-func NewTcpLoadBalanceReverseProxy(c *tcp_proxy_middleware.TcpSliceRouterContext, lb load_balance.LoadBalance) *TcpReverseProxy {
+//tlsConfig为空时与下游明文通信
+func NewTcpLoadBalanceReverseProxy(c *tcp_proxy_middleware.TcpSliceRouterContext, lb load_balance.LoadBalance, fb load_balance.Feedback, tlsConfig *tls.Config) *TcpReverseProxy {
 	return func() *TcpReverseProxy {
-		nextAddr, err := lb.Get("")
+		//tcp无请求内容可取，一致性hash按客户端ip
+		nextAddr, err := lb.Get(c.ClientIP())
 		if err != nil {
 			log.Fatal("get next addr fail")
 		}
@@ -21,6 +24,8 @@ func NewTcpLoadBalanceReverseProxy(c *tcp_proxy_middleware.TcpSliceRouterContext
 			Addr:            nextAddr,
 			KeepAlivePeriod: time.Second,
 			DialTimeout:     time.Second,
+			Feedback:        fb,
+			TLSConfig:       tlsConfig,
 		}
 	}()
 }
@@ -33,7 +38,10 @@ type TcpReverseProxy struct {
 	DialTimeout          time.Duration //设置超时时间
 	DialContext          func(ctx context.Context, network, address string) (net.Conn, error)
 	OnDialError          func(src net.Conn, dstDialErr error)
+	Feedback             load_balance.Feedback //连接结束时回报，建连失败计入被动探活
+	TLSConfig            *tls.Config           //下游tls配置，为空时明文连接
 	ProxyProtocolVersion int
+	dialCost             time.Duration //建连耗时
 }
 
 func (dp *TcpReverseProxy) dialTimeout() time.Duration {
@@ -49,10 +57,15 @@ func (dp *TcpReverseProxy) dialContext() func(ctx context.Context, network, addr
 	if dp.DialContext != nil {
 		return dp.DialContext
 	}
-	return (&net.Dialer{
+	dialer := &net.Dialer{
 		Timeout:   dp.DialTimeout,     //连接超时
 		KeepAlive: dp.KeepAlivePeriod, //设置连接的检测时长
-	}).DialContext
+	}
+	//建连超时包含tls握手
+	if dp.TLSConfig != nil {
+		return (&tls.Dialer{NetDialer: dialer, Config: dp.TLSConfig}).DialContext
+	}
+	return dialer.DialContext
 }
 
 func (dp *TcpReverseProxy) keepAlivePeriod() time.Duration {
@@ -69,14 +82,18 @@ func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
 	if dp.DialTimeout >= 0 {
 		ctx, cancel = context.WithTimeout(ctx, dp.dialTimeout())
 	}
+	dialStart := time.Now()
 	dst, err := dp.dialContext()(ctx, "tcp", dp.Addr)
+	dp.dialCost = time.Since(dialStart)
 	if cancel != nil {
 		cancel()
 	}
 	if err != nil {
+		dp.done(err)
 		dp.onDialError()(src, err)
 		return
 	}
+	defer dp.done(nil)
 
 	defer func() { go dst.Close() }() //记得退出下游连接
 
@@ -103,6 +120,12 @@ func (dp *TcpReverseProxy) onDialError() func(src net.Conn, dstDialErr error) {
 	}
 }
 
+func (dp *TcpReverseProxy) done(err error) {
+	if dp.Feedback != nil {
+		dp.Feedback.Done(dp.Addr, err, dp.dialCost)
+	}
+}
+
 func (dp *TcpReverseProxy) proxyCopy(errc chan<- error, dst, src net.Conn) {
 	_, err := io.Copy(dst, src)
 	errc <- err
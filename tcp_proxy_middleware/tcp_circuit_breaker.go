@@ -0,0 +1,34 @@
+package tcp_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+)
+
+//服务熔断时返回降级内容并关闭连接
+func TCPCircuitBreakerMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		breaker, err := dao.LoadBalancerHandler.GetCircuitBreaker(serviceDetail)
+		if err != nil {
+			c.conn.Write([]byte(err.Error()))
+			c.Abort()
+			return
+		}
+		if breaker != nil && !breaker.Allow() {
+			body := serviceDetail.LoadBalance.BreakerFallbackBody
+			if body == "" {
+				body = "service circuit breaker open"
+			}
+			c.conn.Write([]byte(body))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -39,7 +39,8 @@ func TCPFlowLimitMiddleware() func(c *TcpSliceRouterContext) {
 			clientIP = splits[0]
 		}
 		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
-			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+			clientLimiter, err := public.FlowLimiterHandler.GetOwnedLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
 				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
 				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
 			if err != nil {
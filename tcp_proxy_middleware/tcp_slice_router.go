@@ -48,6 +48,12 @@ func (c *TcpSliceRouterContext) Set(key, val interface{}) {
 	c.Ctx = context.WithValue(c.Ctx, key, val)
 }
 
+//客户端ip，tcp服务一致性hash使用
+func (c *TcpSliceRouterContext) ClientIP() string {
+	clientIP, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	return clientIP
+}
+
 type TcpSliceRouterHandler struct {
 	coreFunc func(*TcpSliceRouterContext) tcp_server.TCPHandler
 	router   *TcpSliceRouter
@@ -0,0 +1,35 @@
+package tcp_proxy_middleware
+
+import (
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/public"
+)
+
+//灰度分流，tcp服务仅支持按客户端ip段及比例分流
+func TCPUpstreamGroupMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if len(serviceDetail.UpstreamGroups) == 0 {
+			c.Next()
+			return
+		}
+		groupDetail := serviceDetail.SelectUpstream(&dao.UpstreamMatchContext{ClientIP: c.ClientIP()})
+		if groupDetail.GroupName() != "" {
+			groupCounter, err := public.FlowCounterHandler.GetCounter(public.FlowGroupPrefix + serviceDetail.Info.ServiceName + "_" + groupDetail.GroupName())
+			if err != nil {
+				c.conn.Write([]byte(err.Error()))
+				c.Abort()
+				return
+			}
+			groupCounter.Increase()
+		}
+		c.Set("service", groupDetail)
+		c.Next()
+	}
+}
@@ -0,0 +1,146 @@
+package tcp_proxy_router
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/tcp_server"
+	"github.com/pkg/errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+var errClientHelloPeeked = errors.New("client hello peeked")
+
+//端口下的单个服务
+type tcpServiceHandler struct {
+	service *dao.ServiceDetail
+	handler tcp_server.TCPHandler
+}
+
+//开启tls的端口：读取ClientHello中的SNI选择服务，tls卸载的服务在网关完成握手后转发明文，SNI透传的服务原样转发
+type tcpSNIHandler struct {
+	hostMap        map[string]*tcpServiceHandler
+	defaultHandler *tcpServiceHandler
+}
+
+func newTcpSNIHandler(serviceHandlers []*tcpServiceHandler) (*tcpSNIHandler, error) {
+	h := &tcpSNIHandler{
+		hostMap: map[string]*tcpServiceHandler{},
+	}
+	for _, serviceHandler := range serviceHandlers {
+		rule := serviceHandler.service.TCPRule
+		if rule.TLSMode == dao.TcpTLSModeNone {
+			return nil, errors.New("port shared by non tls service: " + serviceHandler.service.Info.ServiceName)
+		}
+		hosts := rule.GetSNIHosts()
+		if len(hosts) == 0 {
+			if h.defaultHandler != nil {
+				return nil, errors.New("duplicate default service: " + serviceHandler.service.Info.ServiceName)
+			}
+			h.defaultHandler = serviceHandler
+		}
+		for _, host := range hosts {
+			if _, ok := h.hostMap[host]; ok {
+				return nil, errors.New("duplicate sni host: " + host)
+			}
+			h.hostMap[host] = serviceHandler
+		}
+	}
+	return h, nil
+}
+
+//先精确匹配域名，再匹配*.开头的通配域名(只匹配一级)，都未匹配时使用未配置域名的默认服务
+func (h *tcpSNIHandler) match(serverName string) *tcpServiceHandler {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if name != "" {
+		if serviceHandler, ok := h.hostMap[name]; ok {
+			return serviceHandler
+		}
+		if index := strings.Index(name, "."); index > 0 {
+			if serviceHandler, ok := h.hostMap["*"+name[index:]]; ok {
+				return serviceHandler
+			}
+		}
+	}
+	return h.defaultHandler
+}
+
+func (h *tcpSNIHandler) ServeTCP(ctx context.Context, conn net.Conn) {
+	if timeout := lib.GetIntConf("proxy.tcp.handshake_timeout"); timeout > 0 {
+		conn.SetDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+	}
+	serverName, conn, err := peekClientHello(conn)
+	if err != nil {
+		log.Printf(" [ERROR] tcp_sni %v read client hello err:%v\n", conn.RemoteAddr(), err)
+		return
+	}
+	serviceHandler := h.match(serverName)
+	if serviceHandler == nil {
+		log.Printf(" [ERROR] tcp_sni %v no service for server name:%v\n", conn.RemoteAddr(), serverName)
+		return
+	}
+	if serviceHandler.service.TCPRule.TLSMode == dao.TcpTLSModeTerminate {
+		tlsConn := tls.Server(conn, &tls.Config{
+			GetCertificate: dao.CertManagerHandler.GetTLSCertificate,
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf(" [ERROR] tcp_sni %v tls handshake err:%v\n", conn.RemoteAddr(), err)
+			return
+		}
+		conn = tlsConn
+	}
+	conn.SetDeadline(time.Time{})
+	ctx = context.WithValue(ctx, "service", serviceHandler.service)
+	serviceHandler.handler.ServeTCP(ctx, conn)
+}
+
+//预读的数据在后续读取时先行返回
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+//只读连接，用于解析ClientHello，不向客户端写入任何数据
+type readOnlyConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *readOnlyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *readOnlyConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func (c *readOnlyConn) Close() error {
+	return nil
+}
+
+//借助tls库解析ClientHello取得SNI，已读取的数据由返回的连接重放，不影响后续透传或握手
+func peekClientHello(conn net.Conn) (string, net.Conn, error) {
+	buf := &bytes.Buffer{}
+	var hello *tls.ClientHelloInfo
+	err := tls.Server(&readOnlyConn{Conn: conn, reader: io.TeeReader(conn, buf)}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = info
+			return nil, errClientHelloPeeked
+		},
+	}).Handshake()
+	peeked := &peekedConn{Conn: conn, reader: io.MultiReader(buf, conn)}
+	if hello == nil {
+		return "", peeked, err
+	}
+	return hello.ServerName, peeked, nil
+}
@@ -0,0 +1,244 @@
+package tcp_proxy_router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/spf13/viper"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//自签名证书，同时作为客户端信任的CA
+func newTestCert(t *testing.T, hosts ...string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		DNSNames:              hosts,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+//记录客户端写出的原始字节
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+	locker  sync.Mutex
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.locker.Lock()
+	c.written.Write(p)
+	c.locker.Unlock()
+	return c.Conn.Write(p)
+}
+
+func (c *recordConn) Written() []byte {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return append([]byte{}, c.written.Bytes()...)
+}
+
+func TestPeekClientHello(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	record := &recordConn{Conn: clientConn}
+	go tls.Client(record, &tls.Config{ServerName: "a.example.com", InsecureSkipVerify: true}).Handshake()
+
+	serverName, peeked, err := peekClientHello(serverConn)
+	if err != nil || serverName != "a.example.com" {
+		t.Fatalf("expect server name, got %v %v", serverName, err)
+	}
+	//预读的ClientHello原样重放
+	hello := record.Written()
+	replayed := make([]byte, len(hello))
+	if _, err := io.ReadFull(peeked, replayed); err != nil {
+		t.Fatal(err)
+	}
+	if len(hello) == 0 || !bytes.Equal(hello, replayed) {
+		t.Fatalf("expect client hello replayed, got %d bytes of %d", len(replayed), len(hello))
+	}
+}
+
+func TestPeekClientHelloNotTLS(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		clientConn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		clientConn.Close()
+	}()
+	if _, _, err := peekClientHello(serverConn); err == nil {
+		t.Fatal("expect error for non tls data")
+	}
+}
+
+//tls服务端，模拟SNI透传时的下游
+type tlsEchoHandler struct {
+	cert tls.Certificate
+}
+
+func (h *tlsEchoHandler) ServeTCP(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{h.cert}})
+	line, err := bufio.NewReader(tlsConn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	tlsConn.Write([]byte(ctx.Value("service").(*dao.ServiceDetail).Info.ServiceName + ":" + line))
+}
+
+func newTestServiceHandler(name string, tlsMode int, sniHosts string, handler *tlsEchoHandler) *tcpServiceHandler {
+	return &tcpServiceHandler{
+		service: &dao.ServiceDetail{
+			Info:    &dao.ServiceInfo{ServiceName: name},
+			TCPRule: &dao.TcpRule{TLSMode: tlsMode, SNIHosts: sniHosts},
+		},
+		handler: handler,
+	}
+}
+
+func TestTcpSNIHandlerPassthrough(t *testing.T) {
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": viper.New()}
+	defer func() {
+		lib.ViperConfMap = nil
+	}()
+	cert, pool := newTestCert(t, "a.example.com", "b.example.com")
+	handler := &tlsEchoHandler{cert: cert}
+	sniHandler, err := newTcpSNIHandler([]*tcpServiceHandler{
+		newTestServiceHandler("service_a", dao.TcpTLSModePassthrough, "a.example.com", handler),
+		newTestServiceHandler("service_default", dao.TcpTLSModePassthrough, "", handler),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		serverName string
+		expect     string
+	}{
+		{"a.example.com", "service_a:ping\n"},
+		{"b.example.com", "service_default:ping\n"},
+	}
+	for _, c := range cases {
+		clientConn, serverConn := net.Pipe()
+		go sniHandler.ServeTCP(context.Background(), serverConn)
+		//下游使用重放的ClientHello完成握手，客户端可正常校验下游证书
+		tlsConn := tls.Client(clientConn, &tls.Config{ServerName: c.serverName, RootCAs: pool})
+		if err := tlsConn.Handshake(); err != nil {
+			t.Fatalf("%s: handshake err %v", c.serverName, err)
+		}
+		tlsConn.Write([]byte("ping\n"))
+		reply, err := bufio.NewReader(tlsConn).ReadString('\n')
+		if err != nil || reply != c.expect {
+			t.Errorf("%s: expect %q, got %q %v", c.serverName, c.expect, reply, err)
+		}
+		tlsConn.Close()
+	}
+}
+
+func TestTcpSNIHandlerMatch(t *testing.T) {
+	sniHandler, err := newTcpSNIHandler([]*tcpServiceHandler{
+		newTestServiceHandler("exact", dao.TcpTLSModePassthrough, "a.example.com, API.example.com", nil),
+		newTestServiceHandler("wildcard", dao.TcpTLSModeTerminate, "*.example.com", nil),
+		newTestServiceHandler("sub_wildcard", dao.TcpTLSModePassthrough, "*.api.example.com", nil),
+		newTestServiceHandler("default", dao.TcpTLSModeTerminate, "", nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		serverName string
+		expect     string
+	}{
+		{"a.example.com", "exact"},
+		{"A.Example.COM.", "exact"},
+		{"api.example.com", "exact"},
+		{"b.example.com", "wildcard"},
+		{"v1.api.example.com", "sub_wildcard"},
+		{"x.v1.api.example.com", "default"},
+		{"example.com", "default"},
+		{"other.com", "default"},
+		{"", "default"},
+	}
+	for _, c := range cases {
+		serviceHandler := sniHandler.match(c.serverName)
+		if serviceHandler == nil || serviceHandler.service.Info.ServiceName != c.expect {
+			t.Errorf("%q: expect %s, got %v", c.serverName, c.expect, serviceHandler)
+		}
+	}
+
+	//无默认服务时未匹配返回nil
+	sniHandler, err = newTcpSNIHandler([]*tcpServiceHandler{
+		newTestServiceHandler("exact", dao.TcpTLSModePassthrough, "a.example.com", nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceHandler := sniHandler.match("b.example.com"); serviceHandler != nil {
+		t.Fatalf("expect nil, got %v", serviceHandler.service.Info.ServiceName)
+	}
+}
+
+func TestNewTcpSNIHandlerInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		handlers []*tcpServiceHandler
+		err      string
+	}{
+		{"duplicate host", []*tcpServiceHandler{
+			newTestServiceHandler("a", dao.TcpTLSModePassthrough, "a.example.com", nil),
+			newTestServiceHandler("b", dao.TcpTLSModeTerminate, "b.example.com,A.example.com", nil),
+		}, "duplicate sni host: a.example.com"},
+		{"duplicate wildcard", []*tcpServiceHandler{
+			newTestServiceHandler("a", dao.TcpTLSModePassthrough, "*.example.com", nil),
+			newTestServiceHandler("b", dao.TcpTLSModePassthrough, "*.example.com", nil),
+		}, "duplicate sni host: *.example.com"},
+		{"duplicate default", []*tcpServiceHandler{
+			newTestServiceHandler("a", dao.TcpTLSModePassthrough, "", nil),
+			newTestServiceHandler("b", dao.TcpTLSModeTerminate, " ", nil),
+		}, "duplicate default service: b"},
+		{"non tls service", []*tcpServiceHandler{
+			newTestServiceHandler("a", dao.TcpTLSModePassthrough, "a.example.com", nil),
+			newTestServiceHandler("b", dao.TcpTLSModeNone, "", nil),
+		}, "port shared by non tls service: b"},
+	}
+	for _, c := range cases {
+		_, err := newTcpSNIHandler(c.handlers)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expect %q, got %v", c.name, c.err, err)
+		}
+	}
+}
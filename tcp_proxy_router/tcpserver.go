@@ -4,14 +4,32 @@ import (
 	"context"
 	"fmt"
 	"github.com/e421083458/go_gateway/dao"
+	"github.com/e421083458/go_gateway/golang_common/lib"
+	"github.com/e421083458/go_gateway/public"
 	"github.com/e421083458/go_gateway/reverse_proxy"
+	"github.com/e421083458/go_gateway/reverse_proxy/load_balance"
 	"github.com/e421083458/go_gateway/tcp_proxy_middleware"
 	"github.com/e421083458/go_gateway/tcp_server"
 	"log"
 	"net"
+	"sort"
+	"sync"
+	"time"
 )
 
-var tcpServerList = []*tcp_server.TcpServer{}
+//停止监听后等待已建立连接结束的时长
+const DefaultTcpDrainTimeout = 10 * time.Second
+
+var (
+	tcpServerMap    = map[string]*tcpServerItem{}
+	tcpServerLocker sync.Mutex
+)
+
+//运行中的tcp监听，开启tls的服务可共用端口，serviceJson为启动时端口下服务配置快照
+type tcpServerItem struct {
+	serviceJson string
+	server      *tcp_server.TcpServer
+}
 
 type tcpHandler struct {
 }
@@ -20,51 +38,167 @@ func (t *tcpHandler) ServeTCP(ctx context.Context, src net.Conn) {
 	src.Write([]byte("tcpHandler\n"))
 }
 
+//服务reload后重新对齐监听
+type tcpServerObserver struct {
+}
+
+func (o *tcpServerObserver) Update() {
+	tcpServerReconcile()
+}
+
 func TcpServerRun() {
-	serviceList := dao.ServiceManagerHandler.GetTcpServiceList()
-	for _, serviceItem := range serviceList {
-		tempItem := serviceItem
-		go func(serviceDetail *dao.ServiceDetail) {
-			addr := fmt.Sprintf(":%d", serviceDetail.TCPRule.Port)
-			rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
-			if err != nil {
-				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
-				return
-			}
+	tcpServerReconcile()
+	dao.ServiceManagerHandler.Attach(&tcpServerObserver{})
+}
 
-			//构建路由及设置中间件
-			router := tcp_proxy_middleware.NewTcpSliceRouter()
-			router.Group("/").Use(
-				tcp_proxy_middleware.TCPFlowCountMiddleware(),
-				tcp_proxy_middleware.TCPFlowLimitMiddleware(),
-				tcp_proxy_middleware.TCPWhiteListMiddleware(),
-				tcp_proxy_middleware.TCPBlackListMiddleware(),
-			)
-
-			//构建回调handler
-			routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(
-				func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
-					return reverse_proxy.NewTcpLoadBalanceReverseProxy(c, rb)
-				}, router)
-
-			baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
-			tcpServer := &tcp_server.TcpServer{
-				Addr:    addr,
-				Handler: routerHandler,
-				BaseCtx: baseCtx,
-			}
-			tcpServerList = append(tcpServerList, tcpServer)
-			log.Printf(" [INFO] tcp_proxy_run %v\n", addr)
-			if err := tcpServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
-				log.Fatalf(" [INFO] tcp_proxy_run %v err:%v\n", addr, err)
+//按当前服务列表启停监听：新增端口启动，无服务的端口优雅关闭，服务配置变更的端口重新绑定
+func tcpServerReconcile() {
+	tcpServerLocker.Lock()
+	defer tcpServerLocker.Unlock()
+	portMap := map[string][]*dao.ServiceDetail{}
+	for _, serviceItem := range dao.ServiceManagerHandler.GetTcpServiceList() {
+		addr := fmt.Sprintf(":%d", serviceItem.TCPRule.Port)
+		portMap[addr] = append(portMap[addr], serviceItem)
+	}
+	for _, serviceList := range portMap {
+		sort.Slice(serviceList, func(i, j int) bool {
+			return serviceList[i].Info.ServiceName < serviceList[j].Info.ServiceName
+		})
+	}
+	for addr, serverItem := range tcpServerMap {
+		serviceList, ok := portMap[addr]
+		if ok && public.Obj2Json(serviceList) == serverItem.serviceJson {
+			continue
+		}
+		tcpServerShutdown(serverItem.server)
+		delete(tcpServerMap, addr)
+	}
+	for addr, serviceList := range portMap {
+		if _, ok := tcpServerMap[addr]; ok {
+			continue
+		}
+		tcpServer, err := tcpServerStart(addr, serviceList)
+		if err != nil {
+			log.Printf(" [ERROR] tcp_proxy_run %v err:%v\n", addr, err)
+			continue
+		}
+		tcpServerMap[addr] = &tcpServerItem{
+			serviceJson: public.Obj2Json(serviceList),
+			server:      tcpServer,
+		}
+	}
+}
+
+//端口只有一个未开启tls的服务时直接转发，否则按SNI选择服务
+func tcpServerStart(addr string, serviceList []*dao.ServiceDetail) (*tcp_server.TcpServer, error) {
+	serviceHandlers := []*tcpServiceHandler{}
+	for _, serviceDetail := range serviceList {
+		handler, err := newTcpServiceRouterHandler(serviceDetail)
+		if err != nil {
+			return nil, err
+		}
+		serviceHandlers = append(serviceHandlers, &tcpServiceHandler{service: serviceDetail, handler: handler})
+	}
+
+	var handler tcp_server.TCPHandler
+	baseCtx := context.Background()
+	if len(serviceList) == 1 && serviceList[0].TCPRule.TLSMode == dao.TcpTLSModeNone {
+		handler = serviceHandlers[0].handler
+		baseCtx = context.WithValue(baseCtx, "service", serviceList[0])
+	} else {
+		sniHandler, err := newTcpSNIHandler(serviceHandlers)
+		if err != nil {
+			return nil, err
+		}
+		handler = sniHandler
+	}
+	tcpServer := &tcp_server.TcpServer{
+		Addr:    addr,
+		Handler: handler,
+		BaseCtx: baseCtx,
+	}
+
+	//同步绑定端口，保证端口变更时旧监听先释放
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf(" [INFO] tcp_proxy_run %v\n", addr)
+	go func() {
+		if err := tcpServer.Serve(ln); err != nil && err != tcp_server.ErrServerClosed {
+			log.Printf(" [ERROR] tcp_proxy_run %v err:%v\n", addr, err)
+		}
+	}()
+	return tcpServer, nil
+}
+
+func newTcpServiceRouterHandler(serviceDetail *dao.ServiceDetail) (tcp_server.TCPHandler, error) {
+	rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	fb, err := dao.LoadBalancerHandler.GetFeedback(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := dao.UpstreamTLSHandler.GetTLSConfig(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+
+	//构建路由及设置中间件
+	router := tcp_proxy_middleware.NewTcpSliceRouter()
+	router.Group("/").Use(
+		tcp_proxy_middleware.TCPFlowCountMiddleware(),
+		tcp_proxy_middleware.TCPFlowLimitMiddleware(),
+		tcp_proxy_middleware.TCPWhiteListMiddleware(),
+		tcp_proxy_middleware.TCPBlackListMiddleware(),
+		tcp_proxy_middleware.TCPUpstreamGroupMiddleware(),
+		tcp_proxy_middleware.TCPCircuitBreakerMiddleware(),
+	)
+
+	//构建回调handler
+	routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(
+		func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
+			//命中灰度分组时使用分组的负载均衡器
+			if groupDetail, ok := c.Get("service").(*dao.ServiceDetail); ok && groupDetail.GroupName() != "" {
+				groupRb, err := dao.LoadBalancerHandler.GetLoadBalancer(groupDetail)
+				var groupFb load_balance.Feedback
+				if err == nil {
+					groupFb, err = dao.LoadBalancerHandler.GetFeedback(groupDetail)
+				}
+				if err == nil {
+					return reverse_proxy.NewTcpLoadBalanceReverseProxy(c, groupRb, groupFb, tlsConfig)
+				}
+				log.Printf(" [ERROR] tcp_proxy_group %v err:%v\n", groupDetail.GroupName(), err)
 			}
-		}(tempItem)
+			return reverse_proxy.NewTcpLoadBalanceReverseProxy(c, rb, fb, tlsConfig)
+		}, router)
+	return routerHandler, nil
+}
+
+//立即释放端口，已建立的连接在后台排空
+func tcpServerShutdown(tcpServer *tcp_server.TcpServer) {
+	tcpServer.Close()
+	log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", tcpServer.Addr)
+	drainTimeout := time.Duration(lib.GetIntConf("proxy.tcp.drain_timeout")) * time.Second
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultTcpDrainTimeout
 	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := tcpServer.Shutdown(ctx); err != nil {
+			log.Printf(" [ERROR] tcp_proxy_drain %v err:%v\n", tcpServer.Addr, err)
+		}
+	}()
 }
 
 func TcpServerStop() {
-	for _, tcpServer := range tcpServerList {
-		tcpServer.Close()
-		log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", tcpServer.Addr)
+	tcpServerLocker.Lock()
+	defer tcpServerLocker.Unlock()
+	for _, serverItem := range tcpServerMap {
+		serverItem.server.Close()
+		log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", serverItem.server.Addr)
 	}
 }
@@ -37,6 +37,7 @@ type conn struct {
 
 func (c *conn) close() {
 	c.rwc.Close()
+	c.server.trackConn(c, false)
 }
 
 func (c *conn) serve(ctx context.Context) {
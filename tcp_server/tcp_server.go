@@ -50,6 +50,7 @@ type TcpServer struct {
 	inShutdown int32
 	doneChan   chan struct{}
 	l          *onceCloseListener
+	activeConn map[*conn]struct{}
 }
 
 func (s *TcpServer) shuttingDown() bool {
@@ -75,16 +76,78 @@ func (srv *TcpServer) ListenAndServe() error {
 		ln.(*net.TCPListener)})
 }
 
+//关闭listener，不再接收新连接，已建立的连接不受影响
 func (srv *TcpServer) Close() error {
-	atomic.StoreInt32(&srv.inShutdown, 1)
+	if !atomic.CompareAndSwapInt32(&srv.inShutdown, 0, 1) {
+		return nil
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.doneChan == nil {
+		srv.doneChan = make(chan struct{})
+	}
 	close(srv.doneChan) //关闭channel
-	srv.l.Close()       //执行listener关闭
+	if srv.l != nil {
+		srv.l.Close() //执行listener关闭
+	}
 	return nil
 }
 
+var shutdownPollInterval = 500 * time.Millisecond
+
+//优雅关闭：先关闭listener，再等待已建立的连接退出，ctx超时后强制关闭剩余连接
+func (srv *TcpServer) Shutdown(ctx context.Context) error {
+	srv.Close()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.numActiveConn() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			srv.closeActiveConn()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *TcpServer) trackConn(c *conn, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.activeConn == nil {
+		srv.activeConn = make(map[*conn]struct{})
+	}
+	if add {
+		srv.activeConn[c] = struct{}{}
+	} else {
+		delete(srv.activeConn, c)
+	}
+}
+
+func (srv *TcpServer) numActiveConn() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.activeConn)
+}
+
+func (srv *TcpServer) closeActiveConn() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for c := range srv.activeConn {
+		c.rwc.Close()
+	}
+}
+
 func (srv *TcpServer) Serve(l net.Listener) error {
+	srv.mu.Lock()
 	srv.l = &onceCloseListener{Listener: l}
+	srv.mu.Unlock()
 	defer srv.l.Close() //执行listener关闭
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 	if srv.BaseCtx == nil {
 		srv.BaseCtx = context.Background()
 	}
@@ -102,9 +165,9 @@ func (srv *TcpServer) Serve(l net.Listener) error {
 			continue
 		}
 		c := srv.newConn(rw)
+		srv.trackConn(c, true)
 		go c.serve(ctx)
 	}
-	return nil
 }
 
 func (srv *TcpServer) newConn(rwc net.Conn) *conn {